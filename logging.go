@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+)
+
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+var logLevel = new(slog.LevelVar)
+
+func setupLogging(w io.Writer, format string) error {
+	var handler slog.Handler
+	switch format {
+	case logFormatText:
+		handler = slog.NewTextHandler(w, &slog.HandlerOptions{Level: logLevel})
+	case logFormatJSON:
+		handler = slog.NewJSONHandler(w, &slog.HandlerOptions{
+			Level: logLevel,
+			ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+				if len(groups) == 0 && a.Key == slog.TimeKey {
+					a.Key = "ts"
+				}
+				return a
+			},
+		})
+	default:
+		return fmt.Errorf("unknown log format %q, expected %q or %q", format, logFormatText, logFormatJSON)
+	}
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
@@ -4,190 +4,302 @@ import (
 	"context"
 	"flag"
 	"fmt"
-	"log"
-	"math/rand"
+	"log/slog"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
-	v1 "k8s.io/api/core/v1"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 
-	apierrs "k8s.io/apimachinery/pkg/api/errors"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/types"
-	"k8s.io/apimachinery/pkg/watch"
-	ref "k8s.io/client-go/tools/reference"
+	"github.com/smpio/kube-restart-monitor/monitor"
 )
 
-type WatchEvent struct {
-	Type watch.EventType
-	Pod  *v1.Pod
-}
-
+// set with -ldflags "-X main.version=... -X main.commit=... -X main.buildDate=..."
 var (
-	minWatchTimeout = 5 * time.Minute
-	eventReason     = "ContainerRestart"
-	clientset       *kubernetes.Clientset
+	version   = "dev"
+	commit    = "unknown"
+	buildDate = "unknown"
 )
 
 func main() {
+	opts := monitor.DefaultOptions()
+
 	masterURL := flag.String("master", "", "kubernetes api server url")
-	kubeconfigPath := flag.String("kubeconfig", "", "path to kubeconfig file")
-	flag.StringVar(&eventReason, "eventReason", "ContainerRestart", "event reason")
+	kubeconfigPath := flag.String("kubeconfig", "", "path to kubeconfig file (default in-cluster config, $KUBECONFIG or ~/.kube/config)")
+	kubeContext := flag.String("context", "", "kubeconfig context to use (default the current context)")
+	flag.StringVar(&opts.Namespaces, "namespaces", opts.Namespaces, "comma-separated list of namespaces to watch (default all namespaces)")
+	flag.StringVar(&opts.Namespace, "namespace", opts.Namespace, "watch only this namespace, so a namespaced Role is enough instead of a ClusterRole")
+	flag.StringVar(&opts.ExcludeNamespaces, "exclude-namespaces", opts.ExcludeNamespaces, "comma-separated list of namespaces whose restarts are ignored, unless listed in -namespaces (empty to disable)")
+	flag.StringVar(&opts.LabelSelector, "label-selector", opts.LabelSelector, "watch only pods matching this label selector (e.g. tier=production)")
+	flag.StringVar(&opts.PodNameRegexp, "pod-name-regexp", opts.PodNameRegexp, "report only restarts of pods whose name matches this regular expression, e.g. ^web- (unanchored)")
+	metricsAddr := flag.String("metrics-addr", ":9090", "address to serve prometheus metrics on (empty to disable)")
+	healthAddr := flag.String("health-addr", "", "address to serve /healthz and /readyz on (default is the metrics address)")
+	otelEndpoint := flag.String("otel-endpoint", "", "OTLP/HTTP endpoint (host:port) to export traces of restart handling to (default disabled)")
+	otelInsecure := flag.Bool("otel-insecure", false, "export traces over plain HTTP")
+	pprofAddr := flag.String("pprof-addr", "", "address to serve /debug/pprof/ profiling endpoints on (default disabled)")
+	flag.DurationVar(&opts.HealthStaleness, "health-staleness", opts.HealthStaleness, "/healthz fails if no watch activity was seen within this duration")
+	flag.StringVar(&opts.EventReason, "eventReason", opts.EventReason, "event reason")
+	flag.StringVar(&opts.EventAction, "event-action", opts.EventAction, "action of restart events (events.k8s.io events only)")
+	flag.StringVar(&opts.WebhookURL, "webhook-url", opts.WebhookURL, "URL to POST JSON restart notifications to")
+	flag.StringVar(&opts.WebhookClientCert, "webhook-client-cert", opts.WebhookClientCert, "PEM client certificate file to authenticate to the webhook with (mTLS)")
+	flag.StringVar(&opts.WebhookClientKey, "webhook-client-key", opts.WebhookClientKey, "PEM private key file of -webhook-client-cert")
+	flag.StringVar(&opts.WebhookCACert, "webhook-ca-cert", opts.WebhookCACert, "PEM CA bundle file to verify the webhook server with (default system roots)")
+	flag.StringVar(&opts.WebhookSecret, "webhook-secret", os.Getenv("WEBHOOK_SECRET"), "sign webhook requests with this secret in the X-Signature header (default $WEBHOOK_SECRET)")
+	flag.DurationVar(&opts.WebhookTimeout, "webhook-timeout", opts.WebhookTimeout, "timeout of a single webhook request (also used for chat, PagerDuty and Alertmanager sinks)")
+	flag.StringVar(&opts.Output, "output", opts.Output, "write restarts to stdout in this format, separately from logs: json (default disabled)")
+	flag.StringVar(&opts.SyslogAddr, "syslog-addr", opts.SyslogAddr, "syslog server address to send restart messages to, e.g. syslog:514")
+	flag.StringVar(&opts.SyslogProtocol, "syslog-protocol", opts.SyslogProtocol, "syslog protocol: udp or tcp")
+	flag.StringVar(&opts.OutputFile, "output-file", opts.OutputFile, "file to append restarts to as JSON lines")
+	flag.Int64Var(&opts.OutputFileMaxSize, "output-file-max-size", opts.OutputFileMaxSize, "rotate -output-file when it grows over this many bytes (0 to disable)")
+	flag.IntVar(&opts.OutputFileMaxBackups, "output-file-max-backups", opts.OutputFileMaxBackups, "number of rotated -output-file files to keep")
+	flag.StringVar(&opts.NATSURL, "nats-url", opts.NATSURL, "NATS server URL to publish restarts to as JSON, e.g. nats://nats:4222")
+	flag.StringVar(&opts.NATSSubject, "nats-subject", opts.NATSSubject, "NATS subject to publish restarts to")
+	flag.StringVar(&opts.GRPCSinkAddr, "grpc-sink-addr", opts.GRPCSinkAddr, "address of a RestartNotifier gRPC server (see restart_notifier.proto) to send restarts to")
+	flag.BoolVar(&opts.GRPCSinkTLS, "grpc-sink-tls", opts.GRPCSinkTLS, "connect to -grpc-sink-addr over TLS")
+	flag.StringVar(&opts.RedisAddr, "redis-addr", opts.RedisAddr, "Redis address (host:port or redis:// URL) to append restarts to a stream at")
+	flag.StringVar(&opts.RedisStream, "redis-stream", opts.RedisStream, "Redis stream to append restarts to")
+	flag.Int64Var(&opts.RedisStreamMaxLen, "redis-stream-max-len", opts.RedisStreamMaxLen, "approximate number of entries to trim the Redis stream to, 0 to disable trimming")
+	flag.StringVar(&opts.KafkaBrokers, "kafka-brokers", opts.KafkaBrokers, "comma-separated list of Kafka brokers to produce restarts to as JSON")
+	flag.StringVar(&opts.KafkaTopic, "kafka-topic", opts.KafkaTopic, "Kafka topic to produce restarts to")
+	flag.BoolVar(&opts.KafkaTLS, "kafka-tls", opts.KafkaTLS, "connect to Kafka brokers over TLS")
+	flag.StringVar(&opts.KafkaSASLMechanism, "kafka-sasl-mechanism", opts.KafkaSASLMechanism, "Kafka SASL mechanism: plain, scram-sha-256 or scram-sha-512 (default no SASL)")
+	flag.StringVar(&opts.KafkaSASLUsername, "kafka-sasl-username", opts.KafkaSASLUsername, "Kafka SASL username")
+	flag.StringVar(&opts.KafkaSASLPassword, "kafka-sasl-password", os.Getenv("KAFKA_SASL_PASSWORD"), "Kafka SASL password (default $KAFKA_SASL_PASSWORD)")
+	flag.StringVar(&opts.AlertmanagerURL, "alertmanager-url", opts.AlertmanagerURL, "Alertmanager base URL to post restart alerts to, e.g. http://alertmanager:9093")
+	flag.StringVar(&opts.PagerDutyRoutingKey, "pagerduty-routing-key", opts.PagerDutyRoutingKey, "PagerDuty Events API v2 routing key to trigger incidents with (resolved on -recovery-after)")
+	flag.StringVar(&opts.GoogleChatWebhookURL, "google-chat-webhook-url", opts.GoogleChatWebhookURL, "Google Chat space webhook URL to send restart notifications to")
+	flag.StringVar(&opts.DiscordWebhookURL, "discord-webhook-url", opts.DiscordWebhookURL, "Discord webhook URL to send restart notifications to")
+	flag.StringVar(&opts.TeamsWebhookURL, "teams-webhook-url", opts.TeamsWebhookURL, "Microsoft Teams incoming webhook URL to send restart notifications to")
+	flag.StringVar(&opts.SlackWebhookURL, "slack-webhook-url", opts.SlackWebhookURL, "Slack incoming webhook URL to send restart notifications to")
+	flag.StringVar(&opts.IgnoreExitCodes, "ignore-exit-codes", opts.IgnoreExitCodes, "comma-separated list of exit codes for which restarts are ignored")
+	flag.BoolVar(&opts.CrashLoopOnly, "crashloop-only", opts.CrashLoopOnly, "notify only about restarts of containers in CrashLoopBackOff (all restarts are still counted in metrics)")
+	flag.StringVar(&opts.InitEventReason, "init-event-reason", opts.InitEventReason, "event reason for init container restarts")
+	flag.StringVar(&opts.EphemeralEventReason, "ephemeral-event-reason", opts.EphemeralEventReason, "event reason for ephemeral container restarts")
+	flag.StringVar(&opts.OOMEventReason, "oom-event-reason", opts.OOMEventReason, "event reason for OOMKilled restarts")
+	flag.BoolVar(&opts.IncludeLogs, "include-logs", opts.IncludeLogs, "append last lines of the terminated container logs to the event message")
+	flag.BoolVar(&opts.IncludeNodeConditions, "include-node-conditions", opts.IncludeNodeConditions, "append active pressure conditions of the node to the event message (needs get permission on nodes)")
+	flag.IntVar(&opts.MaxMessageBytes, "max-message-bytes", opts.MaxMessageBytes, "truncate container termination messages to this many bytes (0 for no limit; whole event messages are always limited to 1024 bytes)")
+	flag.Int64Var(&opts.LogTailLines, "log-tail-lines", opts.LogTailLines, "number of log lines to include with -include-logs")
+	flag.IntVar(&opts.SampleRate, "sample-rate", opts.SampleRate, "notify only about every Nth restart of a container, starting with the first one (all restarts are still counted in metrics)")
+	flag.DurationVar(&opts.Cooldown, "cooldown", opts.Cooldown, "suppress notifications for a container for this duration after one was sent (0 to disable)")
+	flag.StringVar(&opts.MessageTemplate, "message-template", opts.MessageTemplate, "Go text/template for the restart message, e.g. '{{.Namespace}}/{{.Pod}}: {{.Container}} exited with {{.ExitCode}}' (default built-in message)")
+	flag.StringVar(&opts.IgnoreAnnotation, "ignore-annotation", opts.IgnoreAnnotation, "restarts of pods with this annotation set to \"true\" are ignored (empty to disable)")
+	flag.StringVar(&opts.IncludeContainers, "include-containers", opts.IncludeContainers, "comma-separated list of container name globs to monitor, e.g. 'app,web-*' (default all containers)")
+	flag.StringVar(&opts.ExcludeContainers, "exclude-containers", opts.ExcludeContainers, "comma-separated list of container name globs to ignore, e.g. 'istio-proxy,linkerd-*'")
+	flag.BoolVar(&opts.OptIn, "opt-in", opts.OptIn, "monitor only pods with the -opt-in-annotation set to \"true\"")
+	flag.StringVar(&opts.OptInAnnotation, "opt-in-annotation", opts.OptInAnnotation, "annotation enabling monitoring of a pod in -opt-in mode")
+	flag.DurationVar(&opts.StartupGrace, "startup-grace", opts.StartupGrace, "do not notify about restarts within this duration after the pod started")
+	var client clientOptions
+	client.addFlags(flag.CommandLine)
+	flag.DurationVar(&opts.APITimeout, "api-timeout", opts.APITimeout, "timeout of a single kubernetes api call, except watches (failed calls are retried)")
+	flag.BoolVar(&opts.ListFromCache, "list-from-cache", opts.ListFromCache, "allow the api server to serve pod lists from its watch cache")
+	flag.IntVar(&opts.MinRestartCount, "min-restart-count", opts.MinRestartCount, "notify only when container restart count reaches this threshold")
+	flag.StringVar(&opts.NodeName, "node-name", os.Getenv("NODE_NAME"), "watch only pods scheduled to this node, e.g. for DaemonSet deployment (default $NODE_NAME)")
+	flag.BoolVar(&opts.EnableLeaderElection, "enable-leader-election", opts.EnableLeaderElection, "run the monitor only in the elected leader replica")
+	flag.StringVar(&opts.LeaderElectionNamespace, "leader-election-namespace", envOrDefault("POD_NAMESPACE", opts.LeaderElectionNamespace), "namespace of the leader election lease (default $POD_NAMESPACE or \"default\")")
+	flag.StringVar(&opts.StateFile, "state-file", opts.StateFile, "file to persist seen restart counts and resourceVersions in, to resume without re-alerting after the monitor restarts")
+	flag.DurationVar(&opts.SinkTimeout, "sink-timeout", opts.SinkTimeout, "timeout of delivering a single notification to a sink, including retries")
+	flag.StringVar(&opts.EventSourceComponent, "event-source-component", opts.EventSourceComponent, "event source component (reporting controller of events.k8s.io events)")
+	flag.StringVar(&opts.EventSourceHost, "event-source-host", os.Getenv("POD_NAME"), "event source host (reporting instance of events.k8s.io events), e.g. the monitor pod name (default $POD_NAME or the hostname)")
+	flag.StringVar(&opts.PropagateLabels, "propagate-labels", opts.PropagateLabels, "comma-separated list of pod labels to copy to event annotations (restart-monitor.smpio/label.<key>) and Alertmanager labels, e.g. team,app")
+	flag.StringVar(&opts.Target, "target", opts.Target, "object to emit events on: pod, or owner (the top-level pod controller, e.g. Deployment, falling back to the pod)")
+	flag.StringVar(&opts.EventsAPI, "events-api", opts.EventsAPI, "API used to create events: core (core/v1) or events.k8s.io (events.k8s.io/v1)")
+	flag.StringVar(&opts.EventTypeMap, "event-type-map", opts.EventTypeMap, "comma-separated termination reasons or exit codes and the event type to use for them, e.g. 'Completed=Normal,143=Normal' (default Normal for exit code 0, Warning otherwise)")
+	flag.Float64Var(&opts.NamespaceRateLimit, "namespace-rate-limit", opts.NamespaceRateLimit, "maximum number of restarts reported per second in each namespace, on top of -cooldown (0 to disable)")
+	flag.IntVar(&opts.NamespaceRateBurst, "namespace-rate-burst", opts.NamespaceRateBurst, "number of restarts a namespace may report at once before -namespace-rate-limit applies")
+	flag.IntVar(&opts.StormThreshold, "storm-threshold", opts.StormThreshold, "emit a single RestartStorm event on the monitor's own pod instead of reporting restarts individually while at least this many would be reported within -storm-window (0 to disable)")
+	flag.DurationVar(&opts.StormWindow, "storm-window", opts.StormWindow, "sliding window of -storm-threshold")
+	flag.BoolVar(&opts.SelfEvent, "self-event", opts.SelfEvent, "on start, create a Normal event on the monitor's own pod ($POD_NAMESPACE/$POD_NAME) noting restarts may have been missed")
+	flag.IntVar(&opts.BatchSize, "batch-size", opts.BatchSize, "send restarts to the webhook and Kafka sinks in batches of up to this size (0 to send them one by one)")
+	flag.DurationVar(&opts.FlushInterval, "flush-interval", opts.FlushInterval, "send incomplete batches of -batch-size after this interval")
+	flag.StringVar(&opts.DeadletterDir, "deadletter-dir", opts.DeadletterDir, "directory to save notifications which sinks failed to deliver to, as JSON files (default disabled)")
+	flag.BoolVar(&opts.DeadletterReplay, "deadletter-replay", opts.DeadletterReplay, "on start, send the notifications saved in -deadletter-dir again and remove them")
+	flag.StringVar(&opts.MuteSchedule, "mute-schedule", opts.MuteSchedule, "semicolon-separated windows during which restarts and image pull errors are not reported (metrics and recoveries still are), e.g. 'Sat,Sun 22:00-06:00; Mon-Fri 02:00-02:30'")
+	flag.StringVar(&opts.MuteTimezone, "mute-timezone", opts.MuteTimezone, "IANA time zone of -mute-schedule, e.g. Europe/Berlin or Local")
+	flag.IntVar(&opts.ChannelBuffer, "channel-buffer", opts.ChannelBuffer, "number of watch events buffered between the watches and their processing")
+	flag.DurationVar(&opts.MinWatchTimeout, "min-watch-timeout", opts.MinWatchTimeout, "watches are closed by the api server and re-established after a random timeout between this duration and (1 + -watch-jitter-factor) times it")
+	flag.Float64Var(&opts.WatchJitterFactor, "watch-jitter-factor", opts.WatchJitterFactor, "spread of the random watch timeout above -min-watch-timeout, wider spreads reconnects of many replicas more evenly (0 to 10)")
+	flag.DurationVar(&opts.ResyncPeriod, "resync-period", opts.ResyncPeriod, "periodically list all pods to detect restarts missed by the watch (0 to disable)")
+	flag.DurationVar(&opts.TerminalPodGrace, "terminal-pod-grace", opts.TerminalPodGrace, "forget restart counts of Succeeded or Failed pods after this duration")
+	flag.DurationVar(&opts.RecoveryAfter, "recovery-after", opts.RecoveryAfter, "emit a Normal event when a reported container stays ready without restarts for this duration (0 to disable)")
+	flag.StringVar(&opts.RecoveryEventReason, "recovery-event-reason", opts.RecoveryEventReason, "event reason for -recovery-after events")
+	flag.IntVar(&opts.Workers, "workers", opts.Workers, "number of workers formatting and reporting restarts concurrently (restarts of one pod are reported in order)")
+	flag.BoolVar(&opts.WatchImagePullErrors, "watch-image-pull-errors", opts.WatchImagePullErrors, "also emit events for containers failing to pull their image (ImagePullBackOff, ErrImagePull)")
+	flag.StringVar(&opts.ImagePullEventReason, "image-pull-event-reason", opts.ImagePullEventReason, "event reason for -watch-image-pull-errors events")
+	flag.BoolVar(&opts.DryRun, "dry-run", opts.DryRun, "log restarts and what would be emitted without creating events or sending notifications")
+	printVersion := flag.Bool("version", false, "print version and exit")
+	logFormat := flag.String("log-format", logFormatText, "log format: text or json")
+	logLevelStr := flag.String("log-level", "info", "minimum log level: debug, info, warn or error")
 	flag.Parse()
 
-	config, err := clientcmd.BuildConfigFromFlags(*masterURL, *kubeconfigPath)
-	if err != nil {
-		log.Fatalln(err)
+	if *printVersion {
+		fmt.Println(versionString())
+		return
 	}
 
-	clientset, err = kubernetes.NewForConfig(config)
-	if err != nil {
-		log.Fatalln(err)
+	if err := logLevel.UnmarshalText([]byte(*logLevelStr)); err != nil {
+		fatal("Invalid log level", "err", err)
 	}
+	if err := setupLogging(os.Stderr, *logFormat); err != nil {
+		fatal("Invalid log format", "err", err)
+	}
+	slog.Info("Starting kube-restart-monitor", "version", version, "commit", commit, "buildDate", buildDate)
 
-	pods := make(map[types.UID]*v1.Pod, 1000)
-	watchEventCh := make(chan WatchEvent, 128)
-	go podWatcher(watchEventCh)
-
-	for watchEvent := range watchEventCh {
-		pod := watchEvent.Pod
-		if watchEvent.Type == watch.Deleted {
-			delete(pods, pod.UID)
-		} else {
-			prevPod, prevExist := pods[pod.UID]
-			pods[pod.UID] = pod
-
-			if prevExist {
-				handlePodUpdate(pod, prevPod)
-			}
-		}
+	config, err := buildConfig(*masterURL, *kubeconfigPath, *kubeContext)
+	if err != nil {
+		fatal("Unable to build client config", "err", err)
 	}
-}
 
-func podWatcher(c chan WatchEvent) {
-	for {
-		err := internalPodWatcher(c)
-		if statusErr, ok := err.(*apierrs.StatusError); ok {
-			if statusErr.ErrStatus.Reason == metav1.StatusReasonExpired {
-				log.Println("podWatcher:", err, "Restarting watch")
-				continue
-			}
-		}
+	client.configure(config)
 
-		log.Fatalln(err)
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		fatal("Unable to create clientset", "err", err)
 	}
-}
 
-func internalPodWatcher(c chan WatchEvent) error {
-	list, err := clientset.CoreV1().Pods("").List(context.TODO(), metav1.ListOptions{})
+	opts.Version = version
+	m, err := monitor.New(clientset, opts)
 	if err != nil {
-		return err
+		fatal("Invalid configuration", "err", err)
 	}
 
-	for _, pod := range list.Items {
-		c <- WatchEvent{
-			Type: watch.Added,
-			Pod:  pod.DeepCopy(),
-		}
+	monitor.RegisterMetrics()
+	metricsMux := newMetricsMux()
+	if *metricsAddr != "" {
+		go serveHTTP(*metricsAddr, metricsMux)
 	}
 
-	resourceVersion := list.ResourceVersion
+	healthMux := metricsMux
+	if *healthAddr != "" {
+		healthMux = http.NewServeMux()
+		go serveHTTP(*healthAddr, healthMux)
+	}
+	m.HandleHealth(healthMux)
 
-	for {
-		log.Println("podWatcher: watching since", resourceVersion)
+	if *pprofAddr != "" {
+		go serveHTTP(*pprofAddr, newPprofMux())
+	}
 
-		timeoutSeconds := int64(minWatchTimeout.Seconds() * (rand.Float64() + 1.0))
-		watcher, err := clientset.CoreV1().Pods("").Watch(context.TODO(), metav1.ListOptions{
-			ResourceVersion: resourceVersion,
-			TimeoutSeconds:  &timeoutSeconds,
-		})
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if *otelEndpoint != "" {
+		shutdownTracing, err := setupTracing(ctx, *otelEndpoint, *otelInsecure)
 		if err != nil {
-			return err
+			fatal("Unable to set up tracing", "err", err)
 		}
-
-		for watchEvent := range watcher.ResultChan() {
-			if watchEvent.Type == watch.Error {
-				return apierrs.FromObject(watchEvent.Object)
-			}
-
-			pod, ok := watchEvent.Object.(*v1.Pod)
-			if !ok {
-				log.Println("podWatcher: unexpected kind:", watchEvent.Object.GetObjectKind().GroupVersionKind())
-				continue
+		// deferred first, so it flushes spans of reports still finishing on exit
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := shutdownTracing(ctx); err != nil {
+				slog.Warn("Unable to flush traces", "err", err)
 			}
+		}()
+	}
 
-			resourceVersion = pod.ResourceVersion
-			c <- WatchEvent{
-				Type: watchEvent.Type,
-				Pod:  pod,
-			}
-		}
+	if err := m.Run(ctx); err != nil {
+		fatal("Monitor failed", "err", err)
 	}
+	slog.Info("Shutting down")
+}
+
+func newMetricsMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	return mux
+}
+
+// newPprofMux serves the profiling endpoints, which net/http/pprof only registers on the default mux.
+func newPprofMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return mux
+}
+
+func serveHTTP(addr string, handler http.Handler) {
+	fatal("HTTP server failed", "addr", addr, "err", http.ListenAndServe(addr, handler))
 }
 
-func handlePodUpdate(pod *v1.Pod, prevPod *v1.Pod) {
-	handleContainersUpdate(pod, pod.Status.ContainerStatuses, prevPod.Status.ContainerStatuses)
-	handleContainersUpdate(pod, pod.Status.InitContainerStatuses, prevPod.Status.InitContainerStatuses)
+// clientOptions are the flags of the kubernetes client.
+type clientOptions struct {
+	qps               float64
+	burst             int
+	impersonateUser   string
+	impersonateGroups string
 }
 
-func handleContainersUpdate(pod *v1.Pod, containerStatuses []v1.ContainerStatus, prevContainerStatuses []v1.ContainerStatus) {
-	prevContainerStatusesMap := make(map[string]*v1.ContainerStatus, len(prevContainerStatuses))
-	for _, containerStatus := range prevContainerStatuses {
-		prevContainerStatusesMap[containerStatus.Name] = &containerStatus
+func (o *clientOptions) addFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.impersonateUser, "as", "", "username to impersonate in kubernetes api calls")
+	fs.StringVar(&o.impersonateGroups, "as-group", "", "comma-separated list of groups to impersonate in kubernetes api calls")
+	fs.Float64Var(&o.qps, "kube-qps", 5, "maximum QPS to the kubernetes api server")
+	fs.IntVar(&o.burst, "kube-burst", 10, "maximum burst of requests to the kubernetes api server")
+}
+
+// configure sets the rate limits, user agent and impersonation of the config.
+func (o *clientOptions) configure(config *rest.Config) {
+	config.QPS = float32(o.qps)
+	config.Burst = o.burst
+	config.UserAgent = "kube-restart-monitor/" + version
+	// bearer tokens of BearerTokenFile (in-cluster and kubeconfig tokenFile) are periodically re-read by client-go
+	config.Impersonate = rest.ImpersonationConfig{
+		UserName: o.impersonateUser,
+		Groups:   splitList(o.impersonateGroups),
 	}
+}
 
-	for _, containerStatus := range containerStatuses {
-		prevContainerStatus, ok := prevContainerStatusesMap[containerStatus.Name]
-		if !ok {
-			continue
+// buildConfig uses the in-cluster config if no flags are given and the monitor runs in a pod,
+// otherwise -kubeconfig or the $KUBECONFIG / ~/.kube/config chain, with kubeContext instead of its current context.
+func buildConfig(masterURL, kubeconfigPath, kubeContext string) (*rest.Config, error) {
+	if masterURL == "" && kubeconfigPath == "" && kubeContext == "" {
+		config, err := rest.InClusterConfig()
+		if err == nil {
+			return config, nil
 		}
-		if containerStatus.RestartCount > prevContainerStatus.RestartCount {
-			handleContainerRestart(pod, &containerStatus)
+		if err != rest.ErrNotInCluster {
+			return nil, err
 		}
 	}
+
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = kubeconfigPath
+	overrides := &clientcmd.ConfigOverrides{
+		ClusterInfo:    clientcmdapi.Cluster{Server: masterURL},
+		CurrentContext: kubeContext,
+	}
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides).ClientConfig()
 }
 
-func handleContainerRestart(pod *v1.Pod, containerStatus *v1.ContainerStatus) {
-	t := containerStatus.LastTerminationState.Terminated.FinishedAt
-	ref, err := ref.GetReference(scheme.Scheme, pod)
-	if err != nil {
-		log.Printf("Could not construct reference to: '%#v' due to: '%v'", pod, err)
-	}
-
-	msg := formatMessage(pod, containerStatus)
-	log.Println(msg)
-
-	event := &v1.Event{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      fmt.Sprintf("%v.%x", pod.Name, time.Now().UnixNano()),
-			Namespace: pod.Namespace,
-		},
-		InvolvedObject: *ref,
-		Reason:         eventReason,
-		Message:        msg,
-		FirstTimestamp: t,
-		LastTimestamp:  t,
-		Count:          1,
-		Type:           "Warning",
-		Source: v1.EventSource{
-			Component: "kube-restart-monitor",
-		},
-	}
-
-	_, err = clientset.CoreV1().Events(pod.Namespace).Create(context.TODO(), event, metav1.CreateOptions{})
-	if err != nil {
-		log.Printf("Unable to write event: '%v'", err)
+func versionString() string {
+	return fmt.Sprintf("kube-restart-monitor %s (commit %s, built %s)", version, commit, buildDate)
+}
+
+func envOrDefault(key, def string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
 	}
+	return def
 }
 
-func formatMessage(pod *v1.Pod, containerStatus *v1.ContainerStatus) string {
-	t := containerStatus.LastTerminationState.Terminated
-	msg := fmt.Sprintf("Container %s in pod %s/%s restarted.\nReason: %s, exit code: %d.",
-		containerStatus.Name, pod.Namespace, pod.Name, t.Reason, t.ExitCode)
-	if t.Message != "" {
-		msg += "\nMessage: " + t.Message
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
 	}
-	return msg
+	return items
 }
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/client-go/rest"
+
+	"github.com/smpio/kube-restart-monitor/monitor"
+	"github.com/smpio/kube-restart-monitor/monitor/monitortest"
+)
+
+func parseClientFlags(t *testing.T, args ...string) clientOptions {
+	t.Helper()
+	var client clientOptions
+	fs := flag.NewFlagSet("kube-restart-monitor", flag.ContinueOnError)
+	client.addFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestClientRateLimitsAndUserAgent(t *testing.T) {
+	client := parseClientFlags(t, "-kube-qps", "20", "-kube-burst", "40")
+	config := &rest.Config{}
+	client.configure(config)
+	if config.QPS != 20 || config.Burst != 40 {
+		t.Errorf("QPS = %v, burst = %d, want 20 and 40", config.QPS, config.Burst)
+	}
+	if expected := "kube-restart-monitor/" + version; config.UserAgent != expected {
+		t.Errorf("user agent = %q, want %q", config.UserAgent, expected)
+	}
+
+	client = parseClientFlags(t)
+	client.configure(config)
+	if config.QPS != 5 || config.Burst != 10 {
+		t.Errorf("default QPS = %v, burst = %d, want 5 and 10", config.QPS, config.Burst)
+	}
+}
+
+func TestJSONRestartLog(t *testing.T) {
+	defaultLogger := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(defaultLogger) })
+	var buf bytes.Buffer
+	if err := setupLogging(&buf, logFormatJSON); err != nil {
+		t.Fatal(err)
+	}
+
+	h := monitortest.NewHarness(t, monitortest.NewPod("default", "web", monitortest.Container("app", 0)))
+	h.Start(monitor.DefaultOptions())
+	h.Modify(monitortest.NewPod("default", "web", monitortest.Crashed(monitortest.Container("app", 1), 1)))
+	h.WaitForEvents(1, 5*time.Second)
+	h.Stop()
+
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var record map[string]any
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("invalid JSON log line %q: %v", line, err)
+		}
+		if record["pod"] != "web" {
+			continue
+		}
+		for key, value := range map[string]any{
+			"level":     "INFO",
+			"namespace": "default",
+			"container": "app",
+			"exitCode":  1.0,
+			"reason":    "Error",
+		} {
+			if record[key] != value {
+				t.Errorf("%s = %v, want %v in %s", key, record[key], value, line)
+			}
+		}
+		if _, err := time.Parse(time.RFC3339, fmt.Sprint(record["ts"])); err != nil {
+			t.Errorf("invalid ts in %s: %v", line, err)
+		}
+		if record["msg"] == "" {
+			t.Errorf("no msg in %s", line)
+		}
+		return
+	}
+	t.Errorf("no restart record in %q", buf.String())
+}
+
+func TestSetupLoggingRejectsUnknownFormat(t *testing.T) {
+	if err := setupLogging(io.Discard, "xml"); err == nil {
+		t.Error("unknown log format accepted")
+	}
+}
+
+func TestLogLevel(t *testing.T) {
+	defaultLogger := slog.Default()
+	t.Cleanup(func() {
+		slog.SetDefault(defaultLogger)
+		logLevel.Set(slog.LevelInfo)
+	})
+	var buf bytes.Buffer
+	if err := setupLogging(&buf, logFormatText); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		level         string
+		debugExpected bool
+	}{
+		{"info", false},
+		{"debug", true},
+	} {
+		buf.Reset()
+		if err := logLevel.UnmarshalText([]byte(tc.level)); err != nil {
+			t.Fatal(err)
+		}
+		slog.Debug("Watching pods")
+		slog.Warn("Watch failed")
+		if strings.Contains(buf.String(), "Watching pods") != tc.debugExpected {
+			t.Errorf("debug message logged at %s level: %t, want %t", tc.level, !tc.debugExpected, tc.debugExpected)
+		}
+		if !strings.Contains(buf.String(), "Watch failed") {
+			t.Errorf("warning not logged at %s level", tc.level)
+		}
+	}
+}
+
+func TestVersionString(t *testing.T) {
+	defer func(v, c, d string) { version, commit, buildDate = v, c, d }(version, commit, buildDate)
+	version, commit, buildDate = "v1.2.0", "0123abc", "2024-05-01T10:00:00Z"
+
+	expected := "kube-restart-monitor v1.2.0 (commit 0123abc, built 2024-05-01T10:00:00Z)"
+	if actual := versionString(); actual != expected {
+		t.Errorf("version = %q, want %q", actual, expected)
+	}
+}
+
+const testKubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: prod
+  cluster:
+    server: https://prod.example.com
+- name: staging
+  cluster:
+    server: https://staging.example.com
+contexts:
+- name: prod
+  context:
+    cluster: prod
+- name: staging
+  context:
+    cluster: staging
+current-context: prod
+`
+
+func writeKubeconfig(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "kubeconfig")
+	if err := os.WriteFile(path, []byte(testKubeconfig), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestBuildConfig(t *testing.T) {
+	kubeconfig := writeKubeconfig(t)
+	t.Setenv("KUBERNETES_SERVICE_HOST", "")
+	t.Setenv("KUBERNETES_SERVICE_PORT", "")
+	t.Setenv("KUBECONFIG", kubeconfig)
+
+	for _, tc := range []struct {
+		name           string
+		masterURL      string
+		kubeconfigPath string
+		context        string
+		expectedHost   string
+	}{
+		{"KUBECONFIG outside of a cluster", "", "", "", "https://prod.example.com"},
+		{"explicit kubeconfig", "", kubeconfig, "", "https://prod.example.com"},
+		{"master URL", "https://master.example.com", "", "", "https://master.example.com"},
+		{"context", "", "", "staging", "https://staging.example.com"},
+		{"context of explicit kubeconfig", "", kubeconfig, "staging", "https://staging.example.com"},
+	} {
+		config, err := buildConfig(tc.masterURL, tc.kubeconfigPath, tc.context)
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if config.Host != tc.expectedHost {
+			t.Errorf("%s: host = %q, want %q", tc.name, config.Host, tc.expectedHost)
+		}
+	}
+}
+
+func TestBuildConfigUnknownContext(t *testing.T) {
+	if _, err := buildConfig("", writeKubeconfig(t), "dev"); err == nil || !strings.Contains(err.Error(), "dev") {
+		t.Errorf("error = %v, want the unknown context to be reported", err)
+	}
+}
+
+func TestBuildConfigInCluster(t *testing.T) {
+	if _, err := os.Stat("/var/run/secrets/kubernetes.io/serviceaccount/token"); err == nil {
+		t.Skip("running in a pod")
+	}
+	t.Setenv("KUBERNETES_SERVICE_HOST", "10.0.0.1")
+	t.Setenv("KUBERNETES_SERVICE_PORT", "443")
+	t.Setenv("KUBECONFIG", writeKubeconfig(t))
+
+	// the service account token is missing outside of a pod, so the in-cluster config fails
+	if _, err := buildConfig("", "", ""); err == nil || !strings.Contains(err.Error(), "serviceaccount") {
+		t.Errorf("error = %v, want the in-cluster config to be used", err)
+	}
+	// flags take precedence over the in-cluster config
+	config, err := buildConfig("https://master.example.com", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Host != "https://master.example.com" {
+		t.Errorf("host = %q, want the master URL", config.Host)
+	}
+}
+
+func TestClientImpersonation(t *testing.T) {
+	client := parseClientFlags(t, "-as", "system:serviceaccount:monitoring:restart-monitor", "-as-group", "viewers, auditors")
+	config := &rest.Config{}
+	client.configure(config)
+	expected := rest.ImpersonationConfig{
+		UserName: "system:serviceaccount:monitoring:restart-monitor",
+		Groups:   []string{"viewers", "auditors"},
+	}
+	if !reflect.DeepEqual(config.Impersonate, expected) {
+		t.Errorf("impersonation = %+v, want %+v", config.Impersonate, expected)
+	}
+
+	client = parseClientFlags(t)
+	config = &rest.Config{}
+	client.configure(config)
+	if !reflect.DeepEqual(config.Impersonate, rest.ImpersonationConfig{}) {
+		t.Errorf("impersonation = %+v without flags, want none", config.Impersonate)
+	}
+}
+
+func TestPprofEndpoints(t *testing.T) {
+	get := func(handler http.Handler, path string) int {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		return recorder.Code
+	}
+
+	pprofMux := newPprofMux()
+	for _, path := range []string{"/debug/pprof/", "/debug/pprof/heap", "/debug/pprof/goroutine", "/debug/pprof/cmdline"} {
+		if code := get(pprofMux, path); code != http.StatusOK {
+			t.Errorf("GET %s = %d, want %d", path, code, http.StatusOK)
+		}
+	}
+
+	// without -pprof-addr nothing else serves the profiles
+	metricsMux := newMetricsMux()
+	for _, path := range []string{"/debug/pprof/", "/debug/pprof/heap"} {
+		if code := get(metricsMux, path); code != http.StatusNotFound {
+			t.Errorf("GET %s from the metrics mux = %d, want %d", path, code, http.StatusNotFound)
+		}
+	}
+	if code := get(metricsMux, "/metrics"); code != http.StatusOK {
+		t.Errorf("GET /metrics = %d, want %d", code, http.StatusOK)
+	}
+}
+
+func TestStartsCounted(t *testing.T) {
+	monitor.RegisterMetrics()
+	starts := func() float64 {
+		families, err := prometheus.DefaultGatherer.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, family := range families {
+			if family.GetName() == "restart_monitor_starts_total" {
+				return family.GetMetric()[0].GetCounter().GetValue()
+			}
+		}
+		t.Fatal("restart_monitor_starts_total is not registered")
+		return 0
+	}
+	if count := starts(); count != 1 {
+		t.Errorf("starts = %v, want 1", count)
+	}
+
+	// running the monitor does not count again
+	h := monitortest.NewHarness(t)
+	h.Start(monitor.DefaultOptions())
+	h.Stop()
+	if count := starts(); count != 1 {
+		t.Errorf("starts = %v after running, want 1", count)
+	}
+}
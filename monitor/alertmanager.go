@@ -0,0 +1,96 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// alerts of containers that stop restarting resolve after this duration
+const alertmanagerResolveTimeout = 15 * time.Minute
+
+type alertmanagerAlert struct {
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+	StartsAt    time.Time         `json:"startsAt"`
+	EndsAt      time.Time         `json:"endsAt"`
+}
+
+// AlertmanagerSink posts restarts as alerts to the Alertmanager v2 API. Alertmanager groups and routes them.
+// Alerts are named alertname, or the event reason for image pull errors, and labeled with the propagated pod labels.
+type AlertmanagerSink struct {
+	url             string
+	alertname       string
+	propagateLabels []string
+	client          *http.Client
+}
+
+func NewAlertmanagerSink(url, alertname string, propagateLabels []string, timeout time.Duration) *AlertmanagerSink {
+	return &AlertmanagerSink{
+		url:             strings.TrimSuffix(url, "/") + "/api/v2/alerts",
+		alertname:       alertname,
+		propagateLabels: propagateLabels,
+		client:          &http.Client{Timeout: timeout},
+	}
+}
+
+func (s *AlertmanagerSink) Notify(ctx context.Context, info *RestartInfo) error {
+	startsAt := info.Timestamp.Time
+	if startsAt.IsZero() {
+		startsAt = time.Now()
+	}
+	return s.send(ctx, info, startsAt, time.Now().Add(alertmanagerResolveTimeout))
+}
+
+func (s *AlertmanagerSink) NotifyRecovery(ctx context.Context, info *RestartInfo) error {
+	now := time.Now()
+	return s.send(ctx, info, now, now)
+}
+
+func (s *AlertmanagerSink) send(ctx context.Context, info *RestartInfo, startsAt, endsAt time.Time) error {
+	// the alert identity, so that the alerts of a container are merged and resolved together. Image pull errors are
+	// separate alerts, not resolved by recoveries.
+	alertname := s.alertname
+	if info.isImagePullError() {
+		alertname = info.EventReason
+	}
+	labels := map[string]string{
+		"alertname": alertname,
+		"namespace": info.Namespace,
+		"pod":       info.PodName,
+		"container": info.Container,
+	}
+	for key, value := range propagatedLabels(info.Labels, s.propagateLabels) {
+		if name := alertmanagerLabelName(key); labels[name] == "" {
+			labels[name] = value
+		}
+	}
+
+	body, err := json.Marshal([]*alertmanagerAlert{{
+		Labels: labels,
+		Annotations: map[string]string{
+			"message":  info.Message,
+			"exitCode": fmt.Sprint(info.ExitCode),
+			"reason":   info.TerminationReason,
+		},
+		StartsAt: startsAt,
+		EndsAt:   endsAt,
+	}})
+	if err != nil {
+		return err
+	}
+	return postJSON(ctx, s.client, s.url, body)
+}
+
+// alertmanagerLabelName converts a kubernetes label key to a valid Prometheus label name.
+func alertmanagerLabelName(key string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' {
+			return r
+		}
+		return '_'
+	}, key)
+}
@@ -0,0 +1,104 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAlertmanagerSink(t *testing.T) {
+	receiver := newTestReceiver(t, http.StatusOK)
+	s := NewAlertmanagerSink(receiver.URL+"/", "ContainerRestart", []string{"team", "app.kubernetes.io/name"}, time.Second)
+	info := newTestRestartInfo()
+	info.Labels = map[string]string{"team": "payments", "app.kubernetes.io/name": "web", "other": "ignored"}
+	if err := s.Notify(context.Background(), info); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.NotifyRecovery(context.Background(), info); err != nil {
+		t.Fatal(err)
+	}
+
+	requests, bodies := receiver.received()
+	if len(requests) != 2 {
+		t.Fatalf("%d requests, want the alert and its resolution", len(requests))
+	}
+	if requests[0].URL.Path != "/api/v2/alerts" {
+		t.Errorf("alerts posted to %s, want /api/v2/alerts", requests[0].URL.Path)
+	}
+	var alerts, resolved []alertmanagerAlert
+	if err := json.Unmarshal(bodies[0], &alerts); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(bodies[1], &resolved); err != nil {
+		t.Fatal(err)
+	}
+	if len(alerts) != 1 || len(resolved) != 1 {
+		t.Fatalf("%d and %d alerts, want 1 each", len(alerts), len(resolved))
+	}
+
+	alert := alerts[0]
+	expectedLabels := map[string]string{
+		"alertname":              "ContainerRestart",
+		"namespace":              "default",
+		"pod":                    "web",
+		"container":              "app",
+		"team":                   "payments",
+		"app_kubernetes_io_name": "web",
+	}
+	if !reflect.DeepEqual(alert.Labels, expectedLabels) {
+		t.Errorf("labels %v, want %v", alert.Labels, expectedLabels)
+	}
+	expectedAnnotations := map[string]string{
+		"message":  "Container app in pod default/web restarted.",
+		"exitCode": "137",
+		"reason":   oomKilledReason,
+	}
+	if !reflect.DeepEqual(alert.Annotations, expectedAnnotations) {
+		t.Errorf("annotations %v, want %v", alert.Annotations, expectedAnnotations)
+	}
+	if !alert.StartsAt.Equal(info.Timestamp.Time) {
+		t.Errorf("startsAt %v, want the restart time %v", alert.StartsAt, info.Timestamp)
+	}
+	if endsIn := time.Until(alert.EndsAt); endsIn <= 0 || endsIn > alertmanagerResolveTimeout {
+		t.Errorf("alert ends in %v, want within the %v resolve timeout", endsIn, alertmanagerResolveTimeout)
+	}
+
+	// the same identity resolves the alert
+	if !reflect.DeepEqual(resolved[0].Labels, expectedLabels) || time.Until(resolved[0].EndsAt) > 0 {
+		t.Errorf("resolution %+v, want the alert labels ending now", resolved[0])
+	}
+}
+
+func TestAlertmanagerImagePullAlert(t *testing.T) {
+	receiver := newTestReceiver(t, http.StatusOK)
+	s := NewAlertmanagerSink(receiver.URL, "ContainerRestart", nil, time.Second)
+	info := &RestartInfo{
+		Namespace:   "default",
+		PodName:     "web",
+		Container:   "app",
+		EventReason: "ContainerImagePullError",
+		EventAction: imagePullEventAction,
+	}
+	if err := s.Notify(context.Background(), info); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Notify(context.Background(), newTestRestartInfo()); err != nil {
+		t.Fatal(err)
+	}
+
+	_, bodies := receiver.received()
+	var names []string
+	for _, body := range bodies {
+		var alerts []alertmanagerAlert
+		if err := json.Unmarshal(body, &alerts); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, alerts[0].Labels["alertname"])
+	}
+	if expected := []string{"ContainerImagePullError", "ContainerRestart"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("alert names %v, want %v", names, expected)
+	}
+}
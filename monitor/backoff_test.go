@@ -0,0 +1,24 @@
+package monitor
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWatchBackoffIncreases(t *testing.T) {
+	backoff := newWatchBackoff()
+	base := time.Second
+	var last time.Duration
+	for i := 0; i < 20; i++ {
+		delay := backoff.Step()
+		// jitter adds up to half of the delay
+		if delay < base || delay >= base*3/2 {
+			t.Fatalf("delay %d = %v, want between %v and %v", i, delay, base, base*3/2)
+		}
+		if base < maxWatchBackoff && delay <= last {
+			t.Fatalf("delay %d = %v, want more than the previous %v", i, delay, last)
+		}
+		last = delay
+		base = min(2*base, maxWatchBackoff)
+	}
+}
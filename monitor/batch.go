@@ -0,0 +1,111 @@
+package monitor
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// BatchSink is implemented by sinks which can deliver several restarts in one request.
+type BatchSink interface {
+	Sink
+	NotifyBatch(ctx context.Context, infos []*RestartInfo) error
+}
+
+// batchingSink collects restarts and hands them to the wrapped sink once size of them are pending,
+// or every interval. Failed batches are logged and saved to the deadletter directory, item by item.
+// The dispatcher stops it only after its queue is drained, so the last batch is complete.
+type batchingSink struct {
+	name     string
+	sink     BatchSink
+	size     int
+	interval time.Duration
+	timeout  time.Duration
+	// saves undelivered restarts
+	deadletter func(sink string, item sinkItem, err error)
+
+	mu      sync.Mutex
+	pending []*RestartInfo
+	stopped chan struct{}
+}
+
+func (s *batchingSink) Notify(ctx context.Context, info *RestartInfo) error {
+	s.mu.Lock()
+	s.pending = append(s.pending, info)
+	var batch []*RestartInfo
+	if len(s.pending) >= s.size {
+		batch = s.take()
+	}
+	s.mu.Unlock()
+
+	if batch != nil {
+		s.flush(ctx, batch)
+	}
+	return nil
+}
+
+// take must be called with mu held.
+func (s *batchingSink) take() []*RestartInfo {
+	batch := s.pending
+	s.pending = nil
+	return batch
+}
+
+func (s *batchingSink) flush(ctx context.Context, batch []*RestartInfo) {
+	if len(batch) == 0 {
+		return
+	}
+	if err := s.sink.NotifyBatch(ctx, batch); err != nil {
+		slog.Warn("Unable to notify sink", "sink", s.name, "restarts", len(batch), "err", err)
+		for _, info := range batch {
+			s.deadletter(s.name, sinkItem{info: info}, err)
+		}
+	}
+}
+
+// run flushes pending restarts every interval until stop, and once more then.
+func (s *batchingSink) run() {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopped:
+			s.flushPending()
+			return
+		case <-ticker.C:
+			s.flushPending()
+		}
+	}
+}
+
+func (s *batchingSink) stop() {
+	close(s.stopped)
+}
+
+func (s *batchingSink) flushPending() {
+	s.mu.Lock()
+	batch := s.take()
+	s.mu.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	s.flush(ctx, batch)
+}
+
+// batch wraps the already added sinks implementing BatchSink. It must be called before start.
+func (d *sinkDispatcher) batch(size int, interval time.Duration) {
+	for _, runner := range d.sinks {
+		if sink, ok := runner.sink.(BatchSink); ok {
+			runner.sink = &batchingSink{
+				name:       runner.name,
+				sink:       sink,
+				size:       size,
+				interval:   interval,
+				timeout:    d.timeout,
+				deadletter: d.writeDeadletter,
+				stopped:    make(chan struct{}),
+			}
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fakeBatchSink records the pods of the batches, failing with err.
+type fakeBatchSink struct {
+	fakeSink
+	// guarded by the mutex of fakeSink
+	batches [][]string
+}
+
+func (s *fakeBatchSink) NotifyBatch(ctx context.Context, infos []*RestartInfo) error {
+	var pods []string
+	for _, info := range infos {
+		pods = append(pods, info.PodName)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches = append(s.batches, pods)
+	return s.err
+}
+
+func (s *fakeBatchSink) sent() [][]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([][]string(nil), s.batches...)
+}
+
+func TestBatchFlushedBySize(t *testing.T) {
+	batched := &fakeBatchSink{}
+	single := &fakeSink{}
+	d := &sinkDispatcher{timeout: time.Second}
+	d.add("batched", batched)
+	d.add("single", single)
+	d.batch(3, time.Hour)
+	d.start()
+
+	for _, pod := range []string{"a", "b", "c", "d", "e", "f", "g"} {
+		d.dispatch(&RestartInfo{Namespace: "default", PodName: pod, Container: "app"})
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for len(batched.sent()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if expected := [][]string{{"a", "b", "c"}, {"d", "e", "f"}}; !reflect.DeepEqual(batched.sent(), expected) {
+		t.Errorf("batches before close %v, want %v", batched.sent(), expected)
+	}
+
+	// the rest is flushed on close
+	d.close()
+	if expected := [][]string{{"a", "b", "c"}, {"d", "e", "f"}, {"g"}}; !reflect.DeepEqual(batched.sent(), expected) {
+		t.Errorf("batches %v, want %v", batched.sent(), expected)
+	}
+	if len(batched.infos()) != 0 {
+		t.Errorf("batched sink notified one by one of %d restarts", len(batched.infos()))
+	}
+	// sinks without batch support are notified one by one
+	if len(single.infos()) != 7 {
+		t.Errorf("%d single notifications, want 7", len(single.infos()))
+	}
+}
+
+func TestBatchFlushedByInterval(t *testing.T) {
+	batched := &fakeBatchSink{}
+	d := &sinkDispatcher{timeout: time.Second}
+	d.add("batched", batched)
+	d.batch(100, 50*time.Millisecond)
+	d.start()
+	defer d.close()
+
+	for _, pod := range []string{"a", "b"} {
+		d.dispatch(&RestartInfo{Namespace: "default", PodName: pod, Container: "app"})
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for len(batched.sent()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if expected := [][]string{{"a", "b"}}; !reflect.DeepEqual(batched.sent(), expected) {
+		t.Errorf("batches %v, want %v", batched.sent(), expected)
+	}
+}
+
+func TestFailedBatchSavedToDeadletter(t *testing.T) {
+	dir := t.TempDir()
+	batched := &fakeBatchSink{fakeSink: fakeSink{err: errors.New("broker unavailable")}}
+	d := &sinkDispatcher{timeout: time.Second, deadletterDir: dir}
+	d.add("kafka", batched)
+	d.batch(2, time.Hour)
+	d.start()
+	for _, pod := range []string{"a", "b"} {
+		d.dispatch(&RestartInfo{Namespace: "default", PodName: pod, Container: "app"})
+	}
+	d.close()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("%d deadletters, want one per restart of the batch", len(entries))
+	}
+}
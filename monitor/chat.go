@@ -0,0 +1,125 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	chatQueueSize      = 100
+	chatCoalesceWindow = 10 * time.Second
+)
+
+type chatNotification struct {
+	info *RestartInfo
+	// the sum of the restart deltas
+	restarts int
+	timer    *time.Timer
+}
+
+// coalescingSink sends chat messages from a bounded queue in the background.
+// Restarts of the same container with the same event reason within chatCoalesceWindow are coalesced into one
+// message to stay within chat service rate limits. When stopped, the pending messages are sent right away.
+type coalescingSink struct {
+	name  string
+	send  func(ctx context.Context, notification *chatNotification) error
+	queue chan *chatNotification
+	// saves undelivered messages
+	deadletter func(sink string, item sinkItem, err error)
+
+	mu      sync.Mutex
+	pending map[string]*chatNotification
+	stopped bool
+}
+
+// chatTitle is the headline of the chat messages of the notification.
+func chatTitle(info *RestartInfo) string {
+	if info.isImagePullError() {
+		return fmt.Sprintf("Container %s in pod %s/%s cannot pull image %s", info.Container, info.Namespace, info.PodName, info.Image)
+	}
+	return fmt.Sprintf("Container %s in pod %s/%s restarted", info.Container, info.Namespace, info.PodName)
+}
+
+func newCoalescingSink(name string, send func(ctx context.Context, notification *chatNotification) error) *coalescingSink {
+	return &coalescingSink{
+		name:    name,
+		send:    send,
+		queue:   make(chan *chatNotification, chatQueueSize),
+		pending: make(map[string]*chatNotification),
+	}
+}
+
+func (s *coalescingSink) Notify(ctx context.Context, info *RestartInfo) error {
+	key := info.Namespace + "/" + info.PodName + "/" + info.Container + "/" + info.EventReason
+	// image pull errors have no delta
+	restarts := max(int(info.Delta), 1)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if notification, ok := s.pending[key]; ok {
+		notification.info = info
+		notification.restarts += restarts
+		return nil
+	}
+
+	s.pending[key] = &chatNotification{
+		info:     info,
+		restarts: restarts,
+		timer: time.AfterFunc(chatCoalesceWindow, func() {
+			s.flush(key)
+		}),
+	}
+	return nil
+}
+
+func (s *coalescingSink) flush(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	notification, ok := s.pending[key]
+	if !ok {
+		// sent by stop
+		return
+	}
+	delete(s.pending, key)
+	select {
+	case s.queue <- notification:
+	default:
+		slog.Warn("Queue is full, dropping notification", "sink", s.name, "container", key)
+		s.deadletter(s.name, sinkItem{info: notification.info}, errors.New("queue is full"))
+	}
+}
+
+// run sends the queued messages until stop, saving undelivered ones to the deadletter directory.
+func (s *coalescingSink) run() {
+	for notification := range s.queue {
+		if err := s.send(context.Background(), notification); err != nil {
+			slog.Warn("Unable to send message", "sink", s.name, "err", err)
+			s.deadletter(s.name, sinkItem{info: notification.info}, err)
+		}
+	}
+}
+
+// stop queues the pending messages without waiting for their coalescing windows and ends run once they are sent.
+// Notify must not be called afterwards.
+func (s *coalescingSink) stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stopped {
+		return
+	}
+	s.stopped = true
+	for key, notification := range s.pending {
+		notification.timer.Stop()
+		delete(s.pending, key)
+		// run keeps draining the queue, so this only waits for the messages ahead
+		s.queue <- notification
+	}
+	close(s.queue)
+}
@@ -0,0 +1,96 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// replayed deadletters of older versions have no ContainerStatus
+func TestChatMessagesWithoutContainerStatus(t *testing.T) {
+	info := &RestartInfo{
+		Namespace:         "default",
+		PodName:           "web",
+		Container:         "app",
+		ExitCode:          137,
+		TerminationReason: oomKilledReason,
+		Message:           "Container app in pod default/web restarted.",
+	}
+	notification := &chatNotification{info: info, restarts: 1}
+
+	for name, message := range map[string]func() interface{}{
+		"slack":       func() interface{} { return newSlackMessage(notification) },
+		"teams":       func() interface{} { return newTeamsMessageCard(notification) },
+		"discord":     func() interface{} { return newDiscordMessage(notification) },
+		"google chat": func() interface{} { return newGoogleChatMessage(notification) },
+	} {
+		body, err := json.Marshal(message())
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !strings.Contains(string(body), "137 (SIGKILL)") || !strings.Contains(string(body), oomKilledReason) {
+			t.Errorf("%s: message %s has no exit code and reason", name, body)
+		}
+	}
+}
+
+func TestChatMessagesOfImagePullErrors(t *testing.T) {
+	info := &RestartInfo{
+		Namespace:   "default",
+		PodName:     "web",
+		Container:   "app",
+		Image:       "app:missing",
+		EventReason: "ContainerImagePullError",
+		EventAction: imagePullEventAction,
+		Message:     "Container app in pod default/web cannot pull image app:missing: ImagePullBackOff.",
+	}
+	notification := &chatNotification{info: info, restarts: 1}
+
+	for name, message := range map[string]func() interface{}{
+		"slack":       func() interface{} { return newSlackMessage(notification) },
+		"teams":       func() interface{} { return newTeamsMessageCard(notification) },
+		"discord":     func() interface{} { return newDiscordMessage(notification) },
+		"google chat": func() interface{} { return newGoogleChatMessage(notification) },
+	} {
+		body, err := json.Marshal(message())
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !strings.Contains(string(body), `"Container app in pod default/web cannot pull image app:missing"`) {
+			t.Errorf("%s: message %s has no image pull error title", name, body)
+		}
+		if strings.Contains(string(body), "restarted") {
+			t.Errorf("%s: message %s announces a restart", name, body)
+		}
+	}
+}
+
+func TestCoalescingSinkCountsRestarts(t *testing.T) {
+	var mu sync.Mutex
+	restarts := map[string]int{}
+	s := newCoalescingSink("test", func(ctx context.Context, notification *chatNotification) error {
+		mu.Lock()
+		defer mu.Unlock()
+		restarts[notification.info.EventReason] += notification.restarts
+		return nil
+	})
+	stop := runChatSink(t, s)
+
+	jump := newTestRestartInfo()
+	jump.Delta = 3
+	imagePullError := &RestartInfo{Namespace: "default", PodName: "web", Container: "app", EventReason: "ContainerImagePullError", EventAction: imagePullEventAction}
+	for _, info := range []*RestartInfo{jump, newTestRestartInfo(), imagePullError} {
+		if err := s.Notify(context.Background(), info); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stop()
+
+	// the image pull error of the container is not merged into its restarts
+	if expected := map[string]int{"ContainerOOMKilled": 4, "ContainerImagePullError": 1}; !reflect.DeepEqual(restarts, expected) {
+		t.Errorf("restarts by event reason %v, want %v", restarts, expected)
+	}
+}
@@ -0,0 +1,38 @@
+package monitor
+
+import (
+	v1 "k8s.io/api/core/v1"
+)
+
+const lastAppliedConfigAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+// compactPod drops the pod fields the monitor never reads, so that the informer cache
+// holds only pod identity, scheduling, container images and resources, and status.
+func compactPod(pod *v1.Pod) {
+	pod.ManagedFields = nil
+	delete(pod.Annotations, lastAppliedConfigAnnotation)
+
+	pod.Spec.Volumes = nil
+	pod.Spec.Affinity = nil
+	pod.Spec.Tolerations = nil
+	pod.Spec.TopologySpreadConstraints = nil
+	pod.Spec.NodeSelector = nil
+	pod.Spec.ImagePullSecrets = nil
+	for i := range pod.Spec.Containers {
+		compactContainer(&pod.Spec.Containers[i])
+	}
+	for i := range pod.Spec.InitContainers {
+		compactContainer(&pod.Spec.InitContainers[i])
+	}
+	for i := range pod.Spec.EphemeralContainers {
+		compactContainer((*v1.Container)(&pod.Spec.EphemeralContainers[i].EphemeralContainerCommon))
+	}
+}
+
+func compactContainer(container *v1.Container) {
+	*container = v1.Container{
+		Name:      container.Name,
+		Image:     container.Image,
+		Resources: container.Resources,
+	}
+}
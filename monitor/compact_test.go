@@ -0,0 +1,138 @@
+package monitor
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func fullPod() *v1.Pod {
+	container := func(name string) v1.Container {
+		return v1.Container{
+			Name:    name,
+			Image:   name + ":1.0",
+			Command: []string{"/bin/" + name, "--config", "/etc/" + name + "/config.yaml"},
+			Env:     []v1.EnvVar{{Name: "LOG_LEVEL", Value: "debug"}, {Name: "PORT", Value: "8080"}},
+			Ports:   []v1.ContainerPort{{Name: "http", ContainerPort: 8080}},
+			Resources: v1.ResourceRequirements{
+				Limits:   v1.ResourceList{v1.ResourceMemory: resource.MustParse("256Mi")},
+				Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("100m")},
+			},
+			VolumeMounts:   []v1.VolumeMount{{Name: "config", MountPath: "/etc/" + name}},
+			LivenessProbe:  &v1.Probe{Handler: v1.Handler{HTTPGet: &v1.HTTPGetAction{Path: "/healthz"}}},
+			ReadinessProbe: &v1.Probe{Handler: v1.Handler{HTTPGet: &v1.HTTPGetAction{Path: "/ready"}}},
+		}
+	}
+	containerStatus := func(name string) v1.ContainerStatus {
+		return v1.ContainerStatus{
+			Name:         name,
+			Image:        name + ":1.0",
+			ImageID:      "docker-pullable://" + name + "@sha256:0123456789abcdef",
+			ContainerID:  "containerd://0123456789abcdef",
+			Ready:        true,
+			RestartCount: 3,
+			State:        v1.ContainerState{Running: &v1.ContainerStateRunning{StartedAt: metav1.NewTime(time.Now())}},
+			LastTerminationState: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{
+				ExitCode: 137,
+				Reason:   "OOMKilled",
+				Message:  "out of memory",
+			}},
+		}
+	}
+
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       "default",
+			Name:            "web-7d4b9c8f6-x2k9p",
+			UID:             "0b6f0e6c-1c3a-4d5e-9f8a-7b6c5d4e3f2a",
+			ResourceVersion: "12345",
+			Labels:          map[string]string{"app": "web", "pod-template-hash": "7d4b9c8f6"},
+			Annotations: map[string]string{
+				"prometheus.io/scrape":      "true",
+				lastAppliedConfigAnnotation: `{"apiVersion":"v1","kind":"Pod","metadata":{"name":"web"},"spec":{}}`,
+			},
+			OwnerReferences:   []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "web-7d4b9c8f6", UID: "owner"}},
+			CreationTimestamp: metav1.NewTime(time.Now().Add(-time.Hour)),
+			ManagedFields: []metav1.ManagedFieldsEntry{
+				{Manager: "kubelet", Operation: metav1.ManagedFieldsOperationUpdate, FieldsV1: &metav1.FieldsV1{Raw: make([]byte, 2048)}},
+			},
+		},
+		Spec: v1.PodSpec{
+			NodeName:         "node-1",
+			Containers:       []v1.Container{container("app"), container("sidecar")},
+			InitContainers:   []v1.Container{container("init")},
+			Volumes:          []v1.Volume{{Name: "config", VolumeSource: v1.VolumeSource{ConfigMap: &v1.ConfigMapVolumeSource{}}}},
+			Affinity:         &v1.Affinity{NodeAffinity: &v1.NodeAffinity{}},
+			Tolerations:      []v1.Toleration{{Key: "dedicated", Operator: v1.TolerationOpExists}},
+			NodeSelector:     map[string]string{"pool": "default"},
+			ImagePullSecrets: []v1.LocalObjectReference{{Name: "registry"}},
+		},
+		Status: v1.PodStatus{
+			Phase:                 v1.PodRunning,
+			PodIP:                 "10.0.0.1",
+			Conditions:            []v1.PodCondition{{Type: v1.PodReady, Status: v1.ConditionTrue}},
+			ContainerStatuses:     []v1.ContainerStatus{containerStatus("app"), containerStatus("sidecar")},
+			InitContainerStatuses: []v1.ContainerStatus{containerStatus("init")},
+		},
+	}
+}
+
+func TestCompactPodKeepsReadFields(t *testing.T) {
+	pod := fullPod()
+	compacted := pod.DeepCopy()
+	compactPod(compacted)
+
+	if compacted.ManagedFields != nil {
+		t.Error("managed fields are kept")
+	}
+	if _, ok := compacted.Annotations[lastAppliedConfigAnnotation]; ok {
+		t.Error("last applied configuration annotation is kept")
+	}
+
+	expectedMeta := pod.ObjectMeta.DeepCopy()
+	expectedMeta.ManagedFields = nil
+	delete(expectedMeta.Annotations, lastAppliedConfigAnnotation)
+	if !reflect.DeepEqual(compacted.ObjectMeta, *expectedMeta) {
+		t.Errorf("metadata = %+v, want %+v", compacted.ObjectMeta, *expectedMeta)
+	}
+	if !reflect.DeepEqual(compacted.Status, pod.Status) {
+		t.Errorf("status = %+v, want %+v", compacted.Status, pod.Status)
+	}
+	if compacted.Spec.NodeName != pod.Spec.NodeName {
+		t.Errorf("node name = %q, want %q", compacted.Spec.NodeName, pod.Spec.NodeName)
+	}
+
+	for _, containers := range [][2][]v1.Container{
+		{compacted.Spec.Containers, pod.Spec.Containers},
+		{compacted.Spec.InitContainers, pod.Spec.InitContainers},
+	} {
+		if len(containers[0]) != len(containers[1]) {
+			t.Fatalf("%d containers, want %d", len(containers[0]), len(containers[1]))
+		}
+		for i, container := range containers[0] {
+			want := containers[1][i]
+			if container.Name != want.Name || container.Image != want.Image || !reflect.DeepEqual(container.Resources, want.Resources) {
+				t.Errorf("container = %+v, want name %q, image %q and resources %+v", container, want.Name, want.Image, want.Resources)
+			}
+		}
+	}
+}
+
+func BenchmarkCompactPod(b *testing.B) {
+	pod := fullPod()
+	compacted := pod.DeepCopy()
+	compactPod(compacted)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		compactPod(pod.DeepCopy())
+	}
+	// the serialized size approximates the memory held per cached pod
+	b.ReportMetric(float64(pod.Size()), "bytes/pod")
+	b.ReportMetric(float64(compacted.Size()), "compacted-bytes/pod")
+}
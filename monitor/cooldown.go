@@ -0,0 +1,157 @@
+package monitor
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+type containerKey struct {
+	podUID    types.UID
+	container string
+}
+
+type cooldownEntry struct {
+	timer *time.Timer
+	cooldownSummary
+}
+
+// cooldownSummary describes the restarts suppressed during a cooldown.
+type cooldownSummary struct {
+	reason          string
+	suppressed      int32
+	pod             *v1.Pod
+	containerStatus *v1.ContainerStatus
+}
+
+// cooldownTracker suppresses notifications for a container for the cooldown period after one was sent.
+// When the period expires, a summary with the number of suppressed restarts is sent to summaries, for the main
+// loop to report it like a restart.
+type cooldownTracker struct {
+	sync.Mutex
+	period    time.Duration
+	entries   map[containerKey]*cooldownEntry
+	summaries chan *cooldownSummary
+	stopped   chan struct{}
+}
+
+func newCooldownTracker(period time.Duration) *cooldownTracker {
+	return &cooldownTracker{
+		period:    period,
+		entries:   make(map[containerKey]*cooldownEntry),
+		summaries: make(chan *cooldownSummary),
+		stopped:   make(chan struct{}),
+	}
+}
+
+// allow reports whether a notification of the delta restarts should be sent, otherwise they are counted
+// as suppressed.
+func (t *cooldownTracker) allow(pod *v1.Pod, containerStatus *v1.ContainerStatus, delta int32, reason string) bool {
+	if t.period <= 0 {
+		return true
+	}
+
+	key := containerKey{pod.UID, containerStatus.Name}
+
+	t.Lock()
+	defer t.Unlock()
+
+	if entry, ok := t.entries[key]; ok {
+		entry.suppressed += delta
+		entry.pod = pod
+		entry.containerStatus = containerStatus
+		return false
+	}
+
+	t.entries[key] = &cooldownEntry{
+		cooldownSummary: cooldownSummary{reason: reason},
+		timer: time.AfterFunc(t.period, func() {
+			t.expire(key)
+		}),
+	}
+	return true
+}
+
+func (t *cooldownTracker) expire(key containerKey) {
+	t.Lock()
+	entry, ok := t.entries[key]
+	delete(t.entries, key)
+	t.Unlock()
+
+	if !ok || entry.suppressed == 0 {
+		return
+	}
+
+	select {
+	case t.summaries <- &entry.cooldownSummary:
+	case <-t.stopped:
+	}
+}
+
+// stop cancels the running cooldowns, their suppressed restarts are not summarized.
+func (t *cooldownTracker) stop() {
+	t.Lock()
+	defer t.Unlock()
+
+	select {
+	case <-t.stopped:
+		return
+	default:
+	}
+	close(t.stopped)
+	for key, entry := range t.entries {
+		entry.timer.Stop()
+		delete(t.entries, key)
+	}
+}
+
+// message describes the restarts suppressed during the cooldown.
+func (t *cooldownTracker) message(summary *cooldownSummary) string {
+	containerStatus, pod := summary.containerStatus, summary.pod
+	return fmt.Sprintf("Container %s in pod %s/%s restarted %d more times during %v cooldown, last restart count: %d.",
+		containerStatus.Name, pod.Namespace, pod.Name, summary.suppressed, t.period, containerStatus.RestartCount)
+}
+
+// handleCooldownSummary reports the suppressed restarts like a restart, unless a restart storm is going on,
+// notifications are muted or the namespace rate limit is exceeded. It is called from the main loop.
+func (m *Monitor) handleCooldownSummary(summary *cooldownSummary) {
+	pod, containerStatus := summary.pod, summary.containerStatus
+	now := time.Now()
+	m.storms.expire(now)
+	if m.storms.active {
+		slog.Debug("Cooldown summary suppressed by restart storm", "namespace", pod.Namespace, "pod", pod.Name, "container", containerStatus.Name)
+		return
+	}
+	if m.muteSchedule.muted(now) {
+		slog.Debug("Cooldown summary muted by -mute-schedule", "namespace", pod.Namespace, "pod", pod.Name, "container", containerStatus.Name)
+		return
+	}
+	if !m.namespaceLimits.allow(pod.Namespace) {
+		slog.Debug("Cooldown summary dropped by -namespace-rate-limit", "namespace", pod.Namespace, "pod", pod.Name, "container", containerStatus.Name)
+		return
+	}
+
+	msg := m.cooldowns.message(summary)
+	m.restartWorkers.submit(pod.UID, func() {
+		logRestart(msg, pod, containerStatus)
+		info := m.newRestartInfo(pod, containerStatus, summary.suppressed, summary.reason)
+		info.Message = msg
+		m.sinks.dispatch(info)
+	})
+}
+
+func (t *cooldownTracker) forget(podUID types.UID) {
+	t.Lock()
+	defer t.Unlock()
+
+	for key, entry := range t.entries {
+		if key.podUID == podUID {
+			entry.timer.Stop()
+			delete(t.entries, key)
+		}
+	}
+}
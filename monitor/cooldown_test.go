@@ -0,0 +1,53 @@
+package monitor
+
+import (
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCooldownTracker(t *testing.T) {
+	tracker := newCooldownTracker(100 * time.Millisecond)
+	defer tracker.stop()
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web", UID: "uid"}}
+
+	if !tracker.allow(pod, &v1.ContainerStatus{Name: "app", RestartCount: 1}, 1, "ContainerRestart") {
+		t.Fatal("first restart is suppressed")
+	}
+	if !tracker.allow(pod, &v1.ContainerStatus{Name: "other", RestartCount: 1}, 1, "ContainerRestart") {
+		t.Error("restart of another container is suppressed")
+	}
+	if tracker.allow(pod, &v1.ContainerStatus{Name: "app", RestartCount: 3}, 2, "ContainerRestart") {
+		t.Error("restart during cooldown is allowed")
+	}
+	if tracker.allow(pod, &v1.ContainerStatus{Name: "app", RestartCount: 6}, 3, "ContainerRestart") {
+		t.Error("restart during cooldown is allowed")
+	}
+
+	select {
+	case summary := <-tracker.summaries:
+		if summary.suppressed != 5 || summary.containerStatus.Name != "app" || summary.reason != "ContainerRestart" {
+			t.Errorf("summary of %d suppressed restarts of %s with reason %s, want 5 of app with ContainerRestart",
+				summary.suppressed, summary.containerStatus.Name, summary.reason)
+		}
+		expected := "Container app in pod default/web restarted 5 more times during 100ms cooldown, last restart count: 6."
+		if msg := tracker.message(summary); msg != expected {
+			t.Errorf("message = %q, want %q", msg, expected)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no summary after the cooldown")
+	}
+
+	// without suppressed restarts, e.g. of the other container, there is no summary
+	select {
+	case summary := <-tracker.summaries:
+		t.Errorf("summary of %s without suppressed restarts", summary.containerStatus.Name)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	if !tracker.allow(pod, &v1.ContainerStatus{Name: "app", RestartCount: 7}, 1, "ContainerRestart") {
+		t.Error("restart after the cooldown is suppressed")
+	}
+}
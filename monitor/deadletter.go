@@ -0,0 +1,107 @@
+package monitor
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// deadletterRecord is the file content. Pod and container status are kept as well,
+// as some sinks need them and RestartInfo does not serialize them.
+type deadletterRecord struct {
+	Sink            string              `json:"sink"`
+	Recovered       bool                `json:"recovered,omitempty"`
+	Time            time.Time           `json:"time"`
+	Error           string              `json:"error"`
+	Info            *RestartInfo        `json:"info"`
+	Pod             *v1.Pod             `json:"podObject,omitempty"`
+	ContainerStatus *v1.ContainerStatus `json:"containerStatus,omitempty"`
+}
+
+// writeDeadletter saves a notification the sink failed to deliver. Errors are only logged.
+func (d *sinkDispatcher) writeDeadletter(sink string, item sinkItem, deliveryErr error) {
+	if d.deadletterDir == "" {
+		return
+	}
+	record := deadletterRecord{
+		Sink:            sink,
+		Recovered:       item.recovered,
+		Time:            time.Now(),
+		Error:           deliveryErr.Error(),
+		Info:            item.info,
+		Pod:             item.info.Pod,
+		ContainerStatus: item.info.ContainerStatus,
+	}
+	body, err := json.Marshal(record)
+	if err != nil {
+		slog.Warn("Unable to write deadletter", "sink", sink, "err", err)
+		return
+	}
+
+	name := fmt.Sprintf("%s-%d-%s.json", record.Time.UTC().Format("20060102T150405.000000000"), d.deadletterSeq.Add(1),
+		strings.ReplaceAll(sink, " ", "_"))
+	path := filepath.Join(d.deadletterDir, name)
+	// written under a temporary name, so replay never sees partial files
+	if err := os.WriteFile(path+".tmp", body, 0o600); err != nil {
+		slog.Warn("Unable to write deadletter", "sink", sink, "err", err)
+		return
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		slog.Warn("Unable to write deadletter", "sink", sink, "err", err)
+		return
+	}
+	slog.Info("Notification saved to deadletter", "sink", sink, "path", path)
+}
+
+// replayDeadletters queues all saved notifications to their sinks again and removes their files.
+// Notifications failing again are saved anew.
+func (d *sinkDispatcher) replayDeadletters() error {
+	paths, err := filepath.Glob(filepath.Join(d.deadletterDir, "*.json"))
+	if err != nil {
+		return err
+	}
+
+	runners := make(map[string]*sinkRunner, len(d.sinks))
+	for _, runner := range d.sinks {
+		runners[runner.name] = runner
+	}
+
+	replayed := 0
+	for _, path := range paths {
+		body, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var record deadletterRecord
+		if err := json.Unmarshal(body, &record); err != nil || record.Info == nil {
+			slog.Warn("Skipping invalid deadletter", "path", path, "err", err)
+			continue
+		}
+		runner, ok := runners[record.Sink]
+		if !ok {
+			slog.Warn("Skipping deadletter of a disabled sink", "path", path, "sink", record.Sink)
+			continue
+		}
+		record.Info.Pod = record.Pod
+		record.Info.ContainerStatus = record.ContainerStatus
+
+		select {
+		case runner.queue <- sinkItem{info: record.Info, recovered: record.Recovered}:
+		default:
+			slog.Warn("Sink queue is full, keeping deadletter for the next replay", "path", path, "sink", runner.name)
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		replayed++
+	}
+	slog.Info("Replayed deadletters", "count", replayed)
+	return nil
+}
@@ -0,0 +1,126 @@
+package monitor
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func newDeadletterInfo(podName string) *RestartInfo {
+	info := newTestRestartInfo()
+	info.PodName = podName
+	info.Pod = &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: podName, UID: types.UID(podName)}}
+	info.ContainerStatus = &v1.ContainerStatus{Name: "app", RestartCount: 3}
+	return info
+}
+
+// writeDeadletters dispatches the notifications to a sink failing to deliver them.
+func writeDeadletters(t *testing.T, dir, sink string, infos ...*RestartInfo) {
+	t.Helper()
+	d := &sinkDispatcher{timeout: time.Second, deadletterDir: dir}
+	d.add(sink, &fakeSink{err: errors.New("service unavailable")})
+	d.start()
+	for _, info := range infos {
+		d.dispatch(info)
+	}
+	d.close()
+}
+
+func TestDeadletterWrittenOnFailure(t *testing.T) {
+	dir := t.TempDir()
+	writeDeadletters(t, dir, "slack", newDeadletterInfo("web"))
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || filepath.Ext(entries[0].Name()) != ".json" {
+		t.Fatalf("deadletter directory has %v, want one JSON file", entries)
+	}
+	info, err := entries[0].Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0o600 {
+		t.Errorf("deadletter mode %v, want 0600", mode)
+	}
+
+	body, err := os.ReadFile(filepath.Join(dir, entries[0].Name()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var record deadletterRecord
+	if err := json.Unmarshal(body, &record); err != nil {
+		t.Fatal(err)
+	}
+	if record.Sink != "slack" || record.Error != "service unavailable" || record.Recovered {
+		t.Errorf("deadletter of sink %q with error %q, recovered %v", record.Sink, record.Error, record.Recovered)
+	}
+	if record.Info == nil || record.Info.PodName != "web" || record.Info.EventReason != "ContainerOOMKilled" {
+		t.Errorf("deadletter info = %+v", record.Info)
+	}
+	if record.Pod == nil || record.Pod.Name != "web" || record.ContainerStatus == nil || record.ContainerStatus.RestartCount != 3 {
+		t.Errorf("deadletter pod %+v, container status %+v", record.Pod, record.ContainerStatus)
+	}
+}
+
+func TestDeadletterReplay(t *testing.T) {
+	dir := t.TempDir()
+	writeDeadletters(t, dir, "slack", newDeadletterInfo("web"), newDeadletterInfo("worker"))
+	writeDeadletters(t, dir, "teams", newDeadletterInfo("db"))
+	invalid := filepath.Join(dir, "invalid.json")
+	if err := os.WriteFile(invalid, []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	slack := &fakeSink{}
+	d := &sinkDispatcher{timeout: time.Second, deadletterDir: dir}
+	d.add("slack", slack)
+	d.start()
+	if err := d.replayDeadletters(); err != nil {
+		t.Fatal(err)
+	}
+	d.close()
+
+	infos := slack.infos()
+	if len(infos) != 2 || infos[0].PodName != "web" || infos[1].PodName != "worker" {
+		t.Fatalf("replayed %+v, want web and worker in order", infos)
+	}
+	if infos[0].Pod == nil || infos[0].Pod.Name != "web" || infos[0].ContainerStatus == nil {
+		t.Errorf("replayed pod %+v and container status %+v, want them restored", infos[0].Pod, infos[0].ContainerStatus)
+	}
+
+	// deadletters of disabled sinks and invalid ones are kept
+	remaining, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(remaining) != 2 {
+		t.Fatalf("%d deadletters remaining, want 2", len(remaining))
+	}
+	var kept []string
+	for _, path := range remaining {
+		if path == invalid {
+			continue
+		}
+		body, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var record deadletterRecord
+		if err := json.Unmarshal(body, &record); err != nil {
+			t.Fatal(err)
+		}
+		kept = append(kept, record.Sink+"/"+record.Info.PodName)
+	}
+	if len(kept) != 1 || kept[0] != "teams/db" {
+		t.Errorf("kept deadletters %v, want teams/db", kept)
+	}
+}
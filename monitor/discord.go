@@ -0,0 +1,89 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const discordMaxDescriptionLength = 2048
+
+var discordColors = map[severity]int{
+	severityCritical: 0x992D22,
+	severityError:    0xE74C3C,
+	severityWarning:  0xF1C40F,
+}
+
+type discordMessage struct {
+	Embeds []discordEmbed `json:"embeds"`
+}
+
+type discordEmbed struct {
+	Title       string         `json:"title"`
+	Description string         `json:"description"`
+	Color       int            `json:"color"`
+	Timestamp   string         `json:"timestamp,omitempty"`
+	Fields      []discordField `json:"fields"`
+}
+
+type discordField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline"`
+}
+
+// DiscordSink sends embeds to a Discord webhook, coalescing restarts of the same container.
+type DiscordSink struct {
+	*coalescingSink
+	url    string
+	client *http.Client
+}
+
+func NewDiscordSink(url string, timeout time.Duration) *DiscordSink {
+	s := &DiscordSink{
+		url:    url,
+		client: &http.Client{Timeout: timeout},
+	}
+	s.coalescingSink = newCoalescingSink("discord", s.send)
+	return s
+}
+
+func (s *DiscordSink) send(ctx context.Context, notification *chatNotification) error {
+	body, err := json.Marshal(newDiscordMessage(notification))
+	if err != nil {
+		return err
+	}
+	return postJSON(ctx, s.client, s.url, body)
+}
+
+func newDiscordMessage(notification *chatNotification) *discordMessage {
+	info := notification.info
+
+	description := "```\n" + truncateText(info.Message, discordMaxDescriptionLength-32) + "\n```"
+	if notification.restarts > 1 {
+		description += fmt.Sprintf("\n%d restarts within %v", notification.restarts, chatCoalesceWindow)
+	}
+
+	embed := discordEmbed{
+		Title:       chatTitle(info),
+		Description: description,
+		Color:       discordColors[restartSeverity(info)],
+		Fields: []discordField{
+			{Name: "Namespace", Value: info.Namespace, Inline: true},
+			{Name: "Pod", Value: info.PodName, Inline: true},
+			{Name: "Container", Value: info.Container, Inline: true},
+		},
+	}
+	if !info.Timestamp.IsZero() {
+		embed.Timestamp = info.Timestamp.UTC().Format(time.RFC3339)
+	}
+	if info.terminated() {
+		embed.Fields = append(embed.Fields,
+			discordField{Name: "Exit code", Value: formatExitCode(info.ExitCode), Inline: true},
+			discordField{Name: "Reason", Value: info.TerminationReason, Inline: true},
+		)
+	}
+	return &discordMessage{Embeds: []discordEmbed{embed}}
+}
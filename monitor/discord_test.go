@@ -0,0 +1,100 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDiscordSink(t *testing.T) {
+	receiver := newTestReceiver(t, http.StatusNoContent)
+	s := NewDiscordSink(receiver.URL, time.Second)
+	stop := runChatSink(t, s.coalescingSink)
+
+	if err := s.Notify(context.Background(), newTestRestartInfo()); err != nil {
+		t.Fatal(err)
+	}
+	stop()
+
+	_, bodies := receiver.received()
+	if len(bodies) != 1 {
+		t.Fatalf("%d messages, want 1", len(bodies))
+	}
+	var message discordMessage
+	if err := json.Unmarshal(bodies[0], &message); err != nil {
+		t.Fatal(err)
+	}
+	if len(message.Embeds) != 1 {
+		t.Fatalf("%d embeds, want 1", len(message.Embeds))
+	}
+	embed := message.Embeds[0]
+	if embed.Title != "Container app in pod default/web restarted" {
+		t.Errorf("title = %q", embed.Title)
+	}
+	if expected := "```\nContainer app in pod default/web restarted.\n```"; embed.Description != expected {
+		t.Errorf("description = %q, want %q", embed.Description, expected)
+	}
+	if embed.Color != 0x992D22 {
+		t.Errorf("color = %#x, want %#x of an OOM kill", embed.Color, 0x992D22)
+	}
+	if embed.Timestamp != "2021-05-01T12:00:00Z" {
+		t.Errorf("timestamp = %q", embed.Timestamp)
+	}
+	expectedFields := []discordField{
+		{Name: "Namespace", Value: "default", Inline: true},
+		{Name: "Pod", Value: "web", Inline: true},
+		{Name: "Container", Value: "app", Inline: true},
+		{Name: "Exit code", Value: "137 (SIGKILL)", Inline: true},
+		{Name: "Reason", Value: oomKilledReason, Inline: true},
+	}
+	if !reflect.DeepEqual(embed.Fields, expectedFields) {
+		t.Errorf("fields = %+v, want %+v", embed.Fields, expectedFields)
+	}
+}
+
+func TestDiscordSinkRateLimited(t *testing.T) {
+	var mu sync.Mutex
+	var attempts []time.Time
+	var bodies [][]byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		attempts = append(attempts, time.Now())
+		bodies = append(bodies, body)
+		if len(attempts) == 1 {
+			// Discord sends the delay in fractional seconds
+			w.Header().Set("Retry-After", "0.2")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(server.Close)
+
+	s := NewDiscordSink(server.URL, time.Second)
+	stop := runChatSink(t, s.coalescingSink)
+	if err := s.Notify(context.Background(), newTestRestartInfo()); err != nil {
+		t.Fatal(err)
+	}
+	stop()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(attempts) != 2 {
+		t.Fatalf("%d attempts, want 2", len(attempts))
+	}
+	// waits for the Retry-After delay rather than the default backoff of a second
+	if delay := attempts[1].Sub(attempts[0]); delay < 200*time.Millisecond || delay >= time.Second {
+		t.Errorf("retried after %v, want the Retry-After delay of 200ms", delay)
+	}
+	if string(bodies[1]) != string(bodies[0]) {
+		t.Errorf("retried with %s, want %s", bodies[1], bodies[0])
+	}
+}
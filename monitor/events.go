@@ -0,0 +1,111 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	eventsAPICore   = "core"
+	eventsAPIEvents = "events.k8s.io"
+
+	monitorStartedEventReason = "RestartMonitorStarted"
+	monitorStartedEventAction = "Started"
+
+	annotationPrefix = "restart-monitor.smpio/"
+
+	eventTargetPod   = "pod"
+	eventTargetOwner = "owner"
+)
+
+// eventObject returns the object to emit events of the pod on: the pod itself or, with -target=owner,
+// its top-level owner, so that events are kept after the pod is deleted.
+func (m *Monitor) eventObject(pod *v1.Pod) runtime.Object {
+	if m.opts.Target != eventTargetOwner {
+		return pod
+	}
+	owner := m.owners.ownerOf(pod)
+	if owner.Kind == "" {
+		return pod
+	}
+	return &v1.ObjectReference{
+		APIVersion: owner.APIVersion,
+		Kind:       owner.Kind,
+		Namespace:  pod.Namespace,
+		Name:       owner.Name,
+		UID:        owner.UID,
+	}
+}
+
+// relatedObject returns the object related to events of the pod: its top-level owner or, with -target=owner,
+// the pod itself. It is nil for pods without owner and for core events, which are recorded without it.
+func (m *Monitor) relatedObject(pod *v1.Pod) runtime.Object {
+	if m.opts.EventsAPI != eventsAPIEvents {
+		return nil
+	}
+	if m.opts.Target == eventTargetOwner {
+		if _, ok := m.eventObject(pod).(*v1.ObjectReference); ok {
+			return pod
+		}
+		return nil
+	}
+	owner := m.owners.ownerOf(pod)
+	if owner.Kind == "" {
+		return nil
+	}
+	return &v1.ObjectReference{
+		APIVersion: owner.APIVersion,
+		Kind:       owner.Kind,
+		Namespace:  pod.Namespace,
+		Name:       owner.Name,
+		UID:        owner.UID,
+	}
+}
+
+// resolveSelfObject sets selfObject. Without get permission on the pod, events refer to it by name only.
+func (m *Monitor) resolveSelfObject(ctx context.Context, namespace, name string) {
+	if namespace == "" || name == "" {
+		return
+	}
+	m.selfObject = &v1.ObjectReference{APIVersion: "v1", Kind: "Pod", Namespace: namespace, Name: name}
+	ctx, cancel := m.withAPITimeout(ctx)
+	defer cancel()
+	if pod, err := m.client.CoreV1().Pods(namespace).Get(ctx, name, metav1.GetOptions{}); err == nil {
+		m.selfObject = pod
+	}
+}
+
+// emitStartedEvent records on the monitor's own pod that it (re)started.
+func (m *Monitor) emitStartedEvent(ctx context.Context) {
+	if m.selfObject == nil {
+		slog.Warn("Unable to emit start event: $POD_NAMESPACE or $POD_NAME is not set")
+		return
+	}
+	m.writeSelfEvent(ctx, v1.EventTypeNormal, monitorStartedEventReason, monitorStartedEventAction,
+		fmt.Sprintf("kube-restart-monitor %s started, container restarts while it was not running may have been missed", m.opts.Version))
+}
+
+// writeSelfEvent writes an event on the monitor's own pod, which must be known.
+func (m *Monitor) writeSelfEvent(ctx context.Context, eventType, reason, action, note string) {
+	if m.opts.DryRun {
+		slog.Info("Dry run, not creating event", "type", eventType, "reason", reason, "note", note)
+		return
+	}
+	// failures are logged and counted by the writer
+	m.eventWriter.write(ctx, m.selfObject, nil, nil, eventType, reason, action, note, 1)
+}
+
+func countEventWrite(err error) error {
+	if err != nil {
+		eventErrorsTotal.Inc()
+		slog.Warn("Unable to write event", "err", err)
+	} else {
+		eventsEmittedTotal.Inc()
+	}
+	return err
+}
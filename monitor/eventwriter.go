@@ -0,0 +1,228 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	v1 "k8s.io/api/core/v1"
+	eventsv1 "k8s.io/api/events/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/reference"
+)
+
+const (
+	// like the LRU cache of the client-go event correlator
+	eventSeriesCacheSize = 4096
+	// like the spam filter of the client-go event correlator: a burst of 25 writes per object, then one every 5 minutes
+	eventSpamBurst = 25
+	eventSpamQPS   = 1. / 300
+)
+
+type eventKey struct {
+	regarding v1.ObjectReference
+	eventType string
+	reason    string
+	action    string
+	note      string
+}
+
+// eventSeries is an event being aggregated. Its lock is held while the event is written, so that concurrent
+// writes of the same event do not create it twice.
+type eventSeries struct {
+	mu        sync.Mutex
+	namespace string
+	// empty until the event is created
+	name  string
+	count int32
+	// occurrences throttled since the last write, added to the next one
+	unwritten int32
+}
+
+// eventWriter writes events with one api call per report, whatever the number of restarts: repeated events of the
+// same object with the same reason and note are aggregated into one event, whose count (the EventSeries count in
+// events.k8s.io/v1) grows by the number of restarts. Like the event recorder, it throttles the writes per object.
+type eventWriter struct {
+	client    kubernetes.Interface
+	timeout   time.Duration
+	api       string
+	component string
+	host      string
+
+	// guards series and limiters, not the series themselves
+	mu       sync.Mutex
+	series   map[eventKey]*eventSeries
+	limiters map[v1.ObjectReference]*rate.Limiter
+}
+
+// write records count occurrences of the event regarding the object. The related object and action are only set
+// on events.k8s.io events, annotations only on core events.
+func (w *eventWriter) write(ctx context.Context, regarding, related runtime.Object, annotations map[string]string, eventType, reason, action, note string, count int32) error {
+	ref, err := reference.GetReference(scheme.Scheme, regarding)
+	if err != nil {
+		return fmt.Errorf("unable to get event object reference: %w", err)
+	}
+	note = truncateText(note, maxEventMessageBytes)
+	// the object is the same, whatever its version
+	object := *ref
+	object.ResourceVersion = ""
+	object.FieldPath = ""
+	key := eventKey{regarding: object, eventType: eventType, reason: reason, action: action, note: note}
+	series := w.lockSeries(key)
+	defer series.mu.Unlock()
+
+	if !w.allow(object) {
+		if series.name != "" {
+			series.unwritten += count
+		}
+		slog.Debug("Throttled event", "namespace", ref.Namespace, "kind", ref.Kind, "name", ref.Name, "reason", reason)
+		return nil
+	}
+	count += series.unwritten
+	series.unwritten = 0
+
+	ctx, cancel := context.WithTimeout(ctx, w.timeout)
+	defer cancel()
+	now := time.Now()
+
+	if series.name != "" {
+		err := w.patch(ctx, series, series.count+count, now)
+		if err == nil {
+			series.count += count
+		}
+		if !apierrs.IsNotFound(err) {
+			return countEventWrite(err)
+		}
+		// the event expired, start over
+	}
+
+	series.namespace = ref.Namespace
+	if series.namespace == "" {
+		series.namespace = metav1.NamespaceDefault
+	}
+	series.name = fmt.Sprintf("%v.%x", ref.Name, now.UnixNano())
+	series.count = count
+	if w.api == eventsAPIEvents {
+		err = w.createEvent(ctx, series, ref, related, eventType, reason, action, note, now)
+	} else {
+		err = w.createCoreEvent(ctx, series, ref, annotations, eventType, reason, note, now)
+	}
+	if err != nil {
+		series.name = ""
+	}
+	return countEventWrite(err)
+}
+
+// allow reports whether an event regarding the object may be written now.
+func (w *eventWriter) allow(object v1.ObjectReference) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	limiter, ok := w.limiters[object]
+	if !ok {
+		if len(w.limiters) >= eventSeriesCacheSize {
+			w.limiters = make(map[v1.ObjectReference]*rate.Limiter)
+		}
+		limiter = rate.NewLimiter(eventSpamQPS, eventSpamBurst)
+		w.limiters[object] = limiter
+	}
+	return limiter.Allow()
+}
+
+// lockSeries returns the locked series of the event, adding it if it is not cached.
+func (w *eventWriter) lockSeries(key eventKey) *eventSeries {
+	w.mu.Lock()
+	series, ok := w.series[key]
+	if !ok {
+		if len(w.series) >= eventSeriesCacheSize {
+			w.series = make(map[eventKey]*eventSeries)
+		}
+		series = &eventSeries{}
+		w.series[key] = series
+	}
+	w.mu.Unlock()
+	series.mu.Lock()
+	return series
+}
+
+func (w *eventWriter) createCoreEvent(ctx context.Context, series *eventSeries, ref *v1.ObjectReference, annotations map[string]string, eventType, reason, note string, now time.Time) error {
+	event := &v1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        series.name,
+			Namespace:   series.namespace,
+			Annotations: annotations,
+		},
+		InvolvedObject: *ref,
+		Reason:         reason,
+		Message:        note,
+		Source:         v1.EventSource{Component: w.component, Host: w.host},
+		FirstTimestamp: metav1.NewTime(now),
+		LastTimestamp:  metav1.NewTime(now),
+		Count:          series.count,
+		Type:           eventType,
+	}
+	_, err := w.client.CoreV1().Events(series.namespace).Create(ctx, event, metav1.CreateOptions{})
+	return err
+}
+
+func (w *eventWriter) createEvent(ctx context.Context, series *eventSeries, ref *v1.ObjectReference, related runtime.Object, eventType, reason, action, note string, now time.Time) error {
+	event := &eventsv1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      series.name,
+			Namespace: series.namespace,
+		},
+		EventTime:           metav1.NewMicroTime(now),
+		ReportingController: w.component,
+		ReportingInstance:   w.host,
+		Action:              action,
+		Reason:              reason,
+		Regarding:           *ref,
+		Note:                note,
+		Type:                eventType,
+	}
+	if related != nil {
+		relatedRef, err := reference.GetReference(scheme.Scheme, related)
+		if err != nil {
+			return fmt.Errorf("unable to get related object reference: %w", err)
+		}
+		event.Related = relatedRef
+	}
+	// a series has at least 2 occurrences
+	if series.count > 1 {
+		event.Series = &eventsv1.EventSeries{Count: series.count, LastObservedTime: metav1.NewMicroTime(now)}
+	}
+	_, err := w.client.EventsV1().Events(series.namespace).Create(ctx, event, metav1.CreateOptions{})
+	return err
+}
+
+func (w *eventWriter) patch(ctx context.Context, series *eventSeries, count int32, now time.Time) error {
+	var patch interface{}
+	if w.api == eventsAPIEvents {
+		patch = map[string]interface{}{
+			"series": &eventsv1.EventSeries{Count: count, LastObservedTime: metav1.NewMicroTime(now)},
+		}
+	} else {
+		patch = map[string]interface{}{
+			"count":         count,
+			"lastTimestamp": metav1.NewTime(now),
+		}
+	}
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+	if w.api == eventsAPIEvents {
+		_, err = w.client.EventsV1().Events(series.namespace).Patch(ctx, series.name, types.StrategicMergePatchType, data, metav1.PatchOptions{})
+	} else {
+		_, err = w.client.CoreV1().Events(series.namespace).Patch(ctx, series.name, types.StrategicMergePatchType, data, metav1.PatchOptions{})
+	}
+	return err
+}
@@ -0,0 +1,254 @@
+package monitor
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+)
+
+func newTestEventWriter(api string) (*eventWriter, *fake.Clientset) {
+	client := fake.NewSimpleClientset()
+	return &eventWriter{
+		client:    client,
+		timeout:   time.Minute,
+		api:       api,
+		component: "kube-restart-monitor",
+		host:      "monitor-0",
+		series:    make(map[eventKey]*eventSeries),
+		limiters:  make(map[v1.ObjectReference]*rate.Limiter),
+	}, client
+}
+
+func newTestPod() *v1.Pod {
+	return &v1.Pod{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web", UID: "uid"},
+	}
+}
+
+func TestEventWriterEventsAPIFields(t *testing.T) {
+	w, client := newTestEventWriter(eventsAPIEvents)
+	pod := newTestPod()
+	owner := &v1.ObjectReference{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "default", Name: "web"}
+	err := w.write(context.Background(), pod, owner, map[string]string{"ignored": "on events.k8s.io"},
+		v1.EventTypeWarning, "ContainerRestart", "Restarted", "Container app in pod default/web restarted.", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := client.EventsV1().Events("default").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events.Items) != 1 {
+		t.Fatalf("%d events, want 1", len(events.Items))
+	}
+	event := events.Items[0]
+	if event.Regarding.Kind != "Pod" || event.Regarding.Name != "web" || event.Regarding.UID != "uid" {
+		t.Errorf("regarding = %+v, want pod default/web", event.Regarding)
+	}
+	if event.Related == nil || event.Related.Kind != "Deployment" || event.Related.Name != "web" {
+		t.Errorf("related = %+v, want deployment default/web", event.Related)
+	}
+	if event.Type != v1.EventTypeWarning || event.Reason != "ContainerRestart" || event.Action != "Restarted" ||
+		event.Note != "Container app in pod default/web restarted." {
+		t.Errorf("type, reason, action, note = %q, %q, %q, %q", event.Type, event.Reason, event.Action, event.Note)
+	}
+	if event.ReportingController != "kube-restart-monitor" || event.ReportingInstance != "monitor-0" {
+		t.Errorf("reporting controller, instance = %q, %q", event.ReportingController, event.ReportingInstance)
+	}
+	if event.EventTime.IsZero() {
+		t.Error("event time is not set")
+	}
+	// a single occurrence is not a series
+	if event.Series != nil {
+		t.Errorf("series = %+v, want nil", event.Series)
+	}
+	if len(event.Annotations) != 0 {
+		t.Errorf("annotations = %v, want none", event.Annotations)
+	}
+}
+
+func TestEventWriterCoreFields(t *testing.T) {
+	w, client := newTestEventWriter(eventsAPICore)
+	err := w.write(context.Background(), newTestPod(), nil, map[string]string{"restart-monitor.smpio/image": "app:latest"},
+		v1.EventTypeWarning, "ContainerRestart", "Restarted", "Container app in pod default/web restarted.", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := client.CoreV1().Events("default").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events.Items) != 1 {
+		t.Fatalf("%d events, want 1", len(events.Items))
+	}
+	event := events.Items[0]
+	if event.InvolvedObject.Kind != "Pod" || event.InvolvedObject.Name != "web" {
+		t.Errorf("involved object = %+v, want pod default/web", event.InvolvedObject)
+	}
+	if event.Type != v1.EventTypeWarning || event.Reason != "ContainerRestart" || event.Message != "Container app in pod default/web restarted." {
+		t.Errorf("type, reason, message = %q, %q, %q", event.Type, event.Reason, event.Message)
+	}
+	if event.Source.Component != "kube-restart-monitor" || event.Source.Host != "monitor-0" {
+		t.Errorf("source = %+v", event.Source)
+	}
+	if event.Count != 1 || event.FirstTimestamp.IsZero() || event.LastTimestamp.IsZero() {
+		t.Errorf("count %d, first %v, last %v", event.Count, event.FirstTimestamp, event.LastTimestamp)
+	}
+	if event.Annotations["restart-monitor.smpio/image"] != "app:latest" {
+		t.Errorf("annotations = %v", event.Annotations)
+	}
+}
+
+func TestNewFailsOnEmptyEventAction(t *testing.T) {
+	opts := DefaultOptions()
+	opts.EventsAPI = eventsAPIEvents
+	opts.EventAction = ""
+	if _, err := New(fake.NewSimpleClientset(), opts); err == nil || !strings.Contains(err.Error(), "-event-action") {
+		t.Errorf("error = %v, want -event-action to be required", err)
+	}
+	opts.EventsAPI = eventsAPICore
+	if _, err := New(fake.NewSimpleClientset(), opts); err != nil {
+		t.Errorf("core events without action: %v", err)
+	}
+}
+
+func TestEventWriterDoesNotBlockOtherEvents(t *testing.T) {
+	// an api server, since the fake clientset serializes the calls
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if strings.Contains(string(body), `"name":"slow"`) {
+			<-release
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write(body)
+	}))
+	defer server.Close()
+	defer close(release)
+	client, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, _ := newTestEventWriter(eventsAPICore)
+	w.client = client
+
+	slow := newTestPod()
+	slow.Name = "slow"
+	go w.write(context.Background(), slow, nil, nil, v1.EventTypeWarning, "ContainerRestart", "Restarted", "slow", 1)
+	// while the api call of the other event hangs
+	time.Sleep(50 * time.Millisecond)
+	written := make(chan error, 1)
+	go func() {
+		written <- w.write(context.Background(), newTestPod(), nil, nil, v1.EventTypeWarning, "ContainerRestart", "Restarted", "fast", 1)
+	}()
+	select {
+	case err := <-written:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("event is not written while another one is")
+	}
+}
+
+func TestEventWriterConcurrentWritesOfOneEvent(t *testing.T) {
+	w, client := newTestEventWriter(eventsAPICore)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := w.write(context.Background(), newTestPod(), nil, nil, v1.EventTypeWarning, "ContainerRestart", "Restarted", "restarted", 1); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	events, err := client.CoreV1().Events("default").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events.Items) != 1 || events.Items[0].Count != 10 {
+		t.Errorf("%d events, want one with count 10: %+v", len(events.Items), events.Items)
+	}
+}
+
+func TestEventWriterAggregatesVersionsOfAnObject(t *testing.T) {
+	w, client := newTestEventWriter(eventsAPICore)
+	for _, resourceVersion := range []string{"1", "2"} {
+		pod := newTestPod()
+		pod.ResourceVersion = resourceVersion
+		if err := w.write(context.Background(), pod, nil, nil, v1.EventTypeWarning, "ContainerRestart", "Restarted", "restarted", 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	events, err := client.CoreV1().Events("default").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events.Items) != 1 || events.Items[0].Count != 2 {
+		t.Errorf("%d events, want one with count 2: %+v", len(events.Items), events.Items)
+	}
+}
+
+func TestEventWriterThrottlesPerObject(t *testing.T) {
+	w, client := newTestEventWriter(eventsAPICore)
+	write := func(pod *v1.Pod, note string) {
+		t.Helper()
+		if err := w.write(context.Background(), pod, nil, nil, v1.EventTypeWarning, "ContainerRestart", "Restarted", note, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pod := newTestPod()
+	for i := 0; i < eventSpamBurst+5; i++ {
+		write(pod, "restarted")
+	}
+	other := newTestPod()
+	other.Name, other.UID = "other", "other"
+	write(other, "restarted")
+
+	countOf := func(name string) int32 {
+		t.Helper()
+		events, err := client.CoreV1().Events("default").List(context.Background(), metav1.ListOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, event := range events.Items {
+			if event.InvolvedObject.Name == name {
+				return event.Count
+			}
+		}
+		return 0
+	}
+	if count := countOf("web"); count != eventSpamBurst {
+		t.Errorf("count = %d, want the burst of %d", count, eventSpamBurst)
+	}
+	if count := countOf("other"); count != 1 {
+		t.Errorf("count of another object = %d, want 1", count)
+	}
+
+	// once writes are allowed again, the throttled occurrences are added
+	ref := v1.ObjectReference{Kind: "Pod", APIVersion: "v1", Namespace: "default", Name: "web", UID: "uid"}
+	w.limiters[ref] = rate.NewLimiter(rate.Inf, 1)
+	write(pod, "restarted")
+	if count := countOf("web"); count != eventSpamBurst+6 {
+		t.Errorf("count = %d, want %d", count, eventSpamBurst+6)
+	}
+}
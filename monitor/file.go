@@ -0,0 +1,142 @@
+package monitor
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+const fileFlushInterval = time.Second
+
+// FileSink appends restarts as JSON lines to a file, rotating it when it grows over maxSize.
+// Rotated files are renamed to path.1 (the newest) up to path.<maxBackups>.
+type FileSink struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	mu     sync.Mutex
+	file   *os.File
+	writer *bufio.Writer
+	size   int64
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+func NewFileSink(path string, maxSize int64, maxBackups int) (*FileSink, error) {
+	s := &FileSink{
+		path:       path,
+		maxSize:    maxSize,
+		maxBackups: maxBackups,
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	go s.flushPeriodically()
+	return s, nil
+}
+
+func (s *FileSink) Notify(ctx context.Context, info *RestartInfo) error {
+	line, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.maxSize > 0 && s.size > 0 && s.size+int64(len(line)) > s.maxSize {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := s.writer.Write(line)
+	s.size += int64(n)
+	return err
+}
+
+// Close flushes buffered records and closes the file.
+func (s *FileSink) Close() {
+	close(s.stop)
+	<-s.done
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.close(); err != nil {
+		slog.Warn("Unable to close output file", "path", s.path, "err", err)
+	}
+}
+
+func (s *FileSink) flushPeriodically() {
+	defer close(s.done)
+	ticker := time.NewTicker(fileFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+			err := s.writer.Flush()
+			s.mu.Unlock()
+			if err != nil {
+				slog.Warn("Unable to write output file", "path", s.path, "err", err)
+			}
+		}
+	}
+}
+
+func (s *FileSink) open() error {
+	file, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	s.file = file
+	s.writer = bufio.NewWriter(file)
+	s.size = stat.Size()
+	return nil
+}
+
+func (s *FileSink) close() error {
+	err := s.writer.Flush()
+	if closeErr := s.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (s *FileSink) rotate() error {
+	if err := s.close(); err != nil {
+		return err
+	}
+	if s.maxBackups > 0 {
+		os.Remove(s.backupPath(s.maxBackups))
+		for i := s.maxBackups - 1; i >= 1; i-- {
+			os.Rename(s.backupPath(i), s.backupPath(i+1))
+		}
+		if err := os.Rename(s.path, s.backupPath(1)); err != nil {
+			return err
+		}
+	} else if err := os.Remove(s.path); err != nil {
+		return err
+	}
+	return s.open()
+}
+
+func (s *FileSink) backupPath(i int) string {
+	return fmt.Sprintf("%s.%d", s.path, i)
+}
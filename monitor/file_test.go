@@ -0,0 +1,103 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// readRecords returns the pod names of the records in the file.
+func readRecords(t *testing.T, path string) []string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pods []string
+	for _, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+		var info RestartInfo
+		if err := json.Unmarshal([]byte(line), &info); err != nil {
+			t.Fatalf("invalid record %q: %v", line, err)
+		}
+		pods = append(pods, info.PodName)
+	}
+	return pods
+}
+
+func testRecord(pod string) *RestartInfo {
+	info := newTestRestartInfo()
+	info.PodName = pod
+	return info
+}
+
+func TestFileSinkRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "restarts.jsonl")
+	line, err := json.Marshal(testRecord("web-0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// two records per file
+	s, err := NewFileSink(path, int64(2*(len(line)+1)), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 7; i++ {
+		if err := s.Notify(context.Background(), testRecord(fmt.Sprintf("web-%d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.Close()
+
+	for file, expected := range map[string][]string{
+		path:        {"web-6"},
+		path + ".1": {"web-4", "web-5"},
+		path + ".2": {"web-2", "web-3"},
+	} {
+		if records := readRecords(t, file); !reflect.DeepEqual(records, expected) {
+			t.Errorf("%s has records %v, want %v", filepath.Base(file), records, expected)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("more than 2 backups are kept: %v", err)
+	}
+}
+
+func TestFileSinkConcurrentWrites(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "restarts.jsonl")
+	s, err := NewFileSink(path, 4096, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := s.Notify(context.Background(), testRecord(fmt.Sprintf("web-%d", i))); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	s.Close()
+
+	seen := make(map[string]bool)
+	files, err := filepath.Glob(path + "*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		for _, pod := range readRecords(t, file) {
+			seen[pod] = true
+		}
+	}
+	if len(files) < 2 || len(seen) != 100 {
+		t.Errorf("%d distinct records in %d files, want 100 in rotated files", len(seen), len(files))
+	}
+}
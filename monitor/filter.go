@@ -0,0 +1,75 @@
+package monitor
+
+import (
+	"fmt"
+	"path"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// isMonitored reports whether restarts of the pod containers should be reported.
+func (m *Monitor) isMonitored(pod *v1.Pod) bool {
+	if m.excludeNamespaces[pod.Namespace] {
+		return false
+	}
+	if m.podNameRegexp != nil && !m.podNameRegexp.MatchString(pod.Name) {
+		return false
+	}
+	if m.opts.IgnoreAnnotation != "" && pod.Annotations[m.opts.IgnoreAnnotation] == "true" {
+		return false
+	}
+	if m.opts.OptIn && pod.Annotations[m.opts.OptInAnnotation] != "true" {
+		return false
+	}
+	return true
+}
+
+// isContainerMonitored reports whether the container name matches -include-containers (if set)
+// and does not match -exclude-containers.
+func (m *Monitor) isContainerMonitored(name string) bool {
+	if len(m.includeContainers) > 0 && !matchesAny(m.includeContainers, name) {
+		return false
+	}
+	return !matchesAny(m.excludeContainers, name)
+}
+
+func matchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func validatePatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("%q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// inStartupGrace reports whether the container restarted within -startup-grace after the pod started.
+func (m *Monitor) inStartupGrace(pod *v1.Pod, containerStatus *v1.ContainerStatus) bool {
+	startupGrace := m.opts.StartupGrace
+	if startupGrace <= 0 {
+		return false
+	}
+
+	started := pod.CreationTimestamp.Time
+	if pod.Status.StartTime != nil {
+		started = pod.Status.StartTime.Time
+	}
+	if started.IsZero() {
+		return false
+	}
+
+	restarted := time.Now()
+	if t := containerStatus.LastTerminationState.Terminated; t != nil && !t.FinishedAt.IsZero() {
+		restarted = t.FinishedAt.Time
+	}
+	return restarted.Sub(started) < startupGrace
+}
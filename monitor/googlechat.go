@@ -0,0 +1,101 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"time"
+)
+
+type googleChatMessage struct {
+	Text  string           `json:"text"`
+	Cards []googleChatCard `json:"cards"`
+}
+
+type googleChatCard struct {
+	Header   googleChatHeader    `json:"header"`
+	Sections []googleChatSection `json:"sections"`
+}
+
+type googleChatHeader struct {
+	Title    string `json:"title"`
+	Subtitle string `json:"subtitle"`
+}
+
+type googleChatSection struct {
+	Widgets []googleChatWidget `json:"widgets"`
+}
+
+type googleChatWidget struct {
+	KeyValue      *googleChatKeyValue      `json:"keyValue,omitempty"`
+	TextParagraph *googleChatTextParagraph `json:"textParagraph,omitempty"`
+}
+
+type googleChatKeyValue struct {
+	TopLabel string `json:"topLabel"`
+	Content  string `json:"content"`
+}
+
+type googleChatTextParagraph struct {
+	Text string `json:"text"`
+}
+
+// GoogleChatSink posts card messages to a Google Chat space webhook, coalescing restarts of the same container.
+type GoogleChatSink struct {
+	*coalescingSink
+	url    string
+	client *http.Client
+}
+
+func NewGoogleChatSink(url string, timeout time.Duration) *GoogleChatSink {
+	s := &GoogleChatSink{
+		url:    url,
+		client: &http.Client{Timeout: timeout},
+	}
+	s.coalescingSink = newCoalescingSink("google chat", s.send)
+	return s
+}
+
+func (s *GoogleChatSink) send(ctx context.Context, notification *chatNotification) error {
+	body, err := json.Marshal(newGoogleChatMessage(notification))
+	if err != nil {
+		return err
+	}
+	return postJSON(ctx, s.client, s.url, body)
+}
+
+func newGoogleChatMessage(notification *chatNotification) *googleChatMessage {
+	info := notification.info
+	title := chatTitle(info)
+
+	subtitle := info.EventReason
+	if notification.restarts > 1 {
+		subtitle += fmt.Sprintf(", %d restarts within %v", notification.restarts, chatCoalesceWindow)
+	}
+
+	widgets := []googleChatWidget{
+		{KeyValue: &googleChatKeyValue{TopLabel: "Namespace", Content: info.Namespace}},
+		{KeyValue: &googleChatKeyValue{TopLabel: "Pod", Content: info.PodName}},
+		{KeyValue: &googleChatKeyValue{TopLabel: "Container", Content: info.Container}},
+	}
+	if info.terminated() {
+		widgets = append(widgets,
+			googleChatWidget{KeyValue: &googleChatKeyValue{TopLabel: "Exit code", Content: formatExitCode(info.ExitCode)}},
+			googleChatWidget{KeyValue: &googleChatKeyValue{TopLabel: "Reason", Content: info.TerminationReason}},
+		)
+	}
+	// card text paragraphs support a subset of HTML
+	widgets = append(widgets, googleChatWidget{TextParagraph: &googleChatTextParagraph{
+		Text: html.EscapeString(truncateText(info.Message, slackMaxTextLength)),
+	}})
+
+	return &googleChatMessage{
+		Text: title,
+		Cards: []googleChatCard{{
+			Header:   googleChatHeader{Title: title, Subtitle: subtitle},
+			Sections: []googleChatSection{{Widgets: widgets}},
+		}},
+	}
+}
@@ -0,0 +1,85 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGoogleChatSink(t *testing.T) {
+	receiver := newTestReceiver(t, http.StatusOK)
+	s := NewGoogleChatSink(receiver.URL, time.Second)
+	stop := runChatSink(t, s.coalescingSink)
+
+	// coalesced into one card
+	info := newTestRestartInfo()
+	info.Message = "Container app in pod default/web restarted.\nMessage: <b>out of memory</b>"
+	for i := 0; i < 3; i++ {
+		if err := s.Notify(context.Background(), info); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stop()
+
+	_, bodies := receiver.received()
+	if len(bodies) != 1 {
+		t.Fatalf("%d messages, want 1", len(bodies))
+	}
+	var message map[string]interface{}
+	if err := json.Unmarshal(bodies[0], &message); err != nil {
+		t.Fatal(err)
+	}
+	keyValue := func(label, content string) map[string]interface{} {
+		return map[string]interface{}{"keyValue": map[string]interface{}{"topLabel": label, "content": content}}
+	}
+	expected := map[string]interface{}{
+		"text": "Container app in pod default/web restarted",
+		"cards": []interface{}{map[string]interface{}{
+			"header": map[string]interface{}{
+				"title":    "Container app in pod default/web restarted",
+				"subtitle": "ContainerOOMKilled, 3 restarts within 10s",
+			},
+			"sections": []interface{}{map[string]interface{}{
+				"widgets": []interface{}{
+					keyValue("Namespace", "default"),
+					keyValue("Pod", "web"),
+					keyValue("Container", "app"),
+					keyValue("Exit code", "137 (SIGKILL)"),
+					keyValue("Reason", oomKilledReason),
+					map[string]interface{}{"textParagraph": map[string]interface{}{
+						"text": "Container app in pod default/web restarted.\nMessage: &lt;b&gt;out of memory&lt;/b&gt;",
+					}},
+				},
+			}},
+		}},
+	}
+	if !reflect.DeepEqual(message, expected) {
+		t.Errorf("message = %s", bodies[0])
+	}
+}
+
+func TestGoogleChatSinkRetriesTransientFailures(t *testing.T) {
+	receiver := newTestReceiver(t, http.StatusServiceUnavailable)
+	s := NewGoogleChatSink(receiver.URL, time.Second)
+	// fails the test if the message is not delivered
+	stop := runChatSink(t, s.coalescingSink)
+
+	if err := s.Notify(context.Background(), newTestRestartInfo()); err != nil {
+		t.Fatal(err)
+	}
+	// recovers after the first attempt, which is retried after a second
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		receiver.mu.Lock()
+		receiver.status = http.StatusOK
+		receiver.mu.Unlock()
+	}()
+	stop()
+
+	if _, bodies := receiver.received(); len(bodies) != 2 {
+		t.Errorf("%d attempts, want 2", len(bodies))
+	}
+}
@@ -0,0 +1,116 @@
+package monitor
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const grpcNotifyMethod = "/kuberestartmonitor.v1.RestartNotifier/Notify"
+
+// GRPCSink calls RestartNotifier.Notify (see restart_notifier.proto) for every restart. The
+// connection reconnects on its own; while it is down calls wait for it until the sink timeout,
+// and the dispatcher queue absorbs the backlog.
+type GRPCSink struct {
+	conn *grpc.ClientConn
+}
+
+// The version is reported in the user agent.
+func NewGRPCSink(addr string, useTLS bool, version string) (*GRPCSink, error) {
+	creds := grpc.WithInsecure()
+	if useTLS {
+		creds = grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{}))
+	}
+	conn, err := grpc.Dial(addr, creds, grpc.WithUserAgent("kube-restart-monitor/"+version))
+	if err != nil {
+		return nil, err
+	}
+	return &GRPCSink{conn: conn}, nil
+}
+
+func (s *GRPCSink) Notify(ctx context.Context, info *RestartInfo) error {
+	return s.conn.Invoke(ctx, grpcNotifyMethod, info, &struct{}{}, grpc.ForceCodec(restartInfoCodec{}), grpc.WaitForReady(true))
+}
+
+func (s *GRPCSink) Close() {
+	s.conn.Close()
+}
+
+// restartInfoCodec encodes RestartInfo in protobuf wire format, without generated code. Responses
+// carry no fields and are not decoded.
+type restartInfoCodec struct{}
+
+func (restartInfoCodec) Name() string {
+	return "proto"
+}
+
+func (restartInfoCodec) Unmarshal(data []byte, v interface{}) error {
+	return nil
+}
+
+func (restartInfoCodec) Marshal(v interface{}) ([]byte, error) {
+	info, ok := v.(*RestartInfo)
+	if !ok {
+		return nil, fmt.Errorf("unexpected message type %T", v)
+	}
+
+	var b []byte
+	appendString := func(num protowire.Number, s string) {
+		if s != "" {
+			b = protowire.AppendTag(b, num, protowire.BytesType)
+			b = protowire.AppendString(b, s)
+		}
+	}
+	appendInt32 := func(num protowire.Number, i int32) {
+		if i != 0 {
+			b = protowire.AppendTag(b, num, protowire.VarintType)
+			b = protowire.AppendVarint(b, uint64(int64(i)))
+		}
+	}
+
+	appendString(1, info.Namespace)
+	appendString(2, info.PodName)
+	appendString(3, string(info.PodUID))
+	appendString(4, info.Container)
+	appendString(5, info.Image)
+	appendString(6, info.ImageID)
+	appendInt32(7, info.RestartCount)
+	appendInt32(8, info.Delta)
+	appendInt32(9, info.ExitCode)
+	appendString(10, info.TerminationReason)
+	appendString(11, info.TerminationMessage)
+	if !info.Timestamp.IsZero() {
+		var ts []byte
+		ts = protowire.AppendTag(ts, 1, protowire.VarintType)
+		ts = protowire.AppendVarint(ts, uint64(info.Timestamp.Unix()))
+		if nanos := info.Timestamp.Nanosecond(); nanos != 0 {
+			ts = protowire.AppendTag(ts, 2, protowire.VarintType)
+			ts = protowire.AppendVarint(ts, uint64(nanos))
+		}
+		b = protowire.AppendTag(b, 12, protowire.BytesType)
+		b = protowire.AppendBytes(b, ts)
+	}
+	appendString(13, info.EventReason)
+	appendString(14, info.Message)
+	for key, value := range info.Labels {
+		var entry []byte
+		entry = protowire.AppendTag(entry, 1, protowire.BytesType)
+		entry = protowire.AppendString(entry, key)
+		entry = protowire.AppendTag(entry, 2, protowire.BytesType)
+		entry = protowire.AppendString(entry, value)
+		b = protowire.AppendTag(b, 15, protowire.BytesType)
+		b = protowire.AppendBytes(b, entry)
+	}
+	appendString(16, info.OwnerKind)
+	appendString(17, info.OwnerName)
+	appendString(18, string(restartSeverity(info)))
+	if info.NeverReady {
+		b = protowire.AppendTag(b, 19, protowire.VarintType)
+		b = protowire.AppendVarint(b, 1)
+	}
+	return b, nil
+}
@@ -0,0 +1,209 @@
+package monitor
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// rawCodec passes messages through as bytes, for the test server to decode them by hand.
+type rawCodec struct{}
+
+func (rawCodec) Name() string {
+	return "proto"
+}
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	return *v.(*[]byte), nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	*v.(*[]byte) = append([]byte(nil), data...)
+	return nil
+}
+
+// testNotifier is an in-process RestartNotifier recording the methods called and the messages received.
+type testNotifier struct {
+	*grpc.Server
+	addr string
+
+	mu       sync.Mutex
+	methods  []string
+	messages [][]byte
+}
+
+func newTestNotifier(t *testing.T, addr string) *testNotifier {
+	t.Helper()
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := &testNotifier{addr: listener.Addr().String()}
+	n.Server = grpc.NewServer(grpc.ForceServerCodec(rawCodec{}), grpc.UnknownServiceHandler(n.handle))
+	go n.Serve(listener)
+	t.Cleanup(n.Stop)
+	return n
+}
+
+func (n *testNotifier) handle(srv interface{}, stream grpc.ServerStream) error {
+	method, _ := grpc.MethodFromServerStream(stream)
+	var message []byte
+	if err := stream.RecvMsg(&message); err != nil {
+		return err
+	}
+	n.mu.Lock()
+	n.methods = append(n.methods, method)
+	n.messages = append(n.messages, message)
+	n.mu.Unlock()
+	response := []byte{}
+	return stream.SendMsg(&response)
+}
+
+func (n *testNotifier) received() ([]string, [][]byte) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]string(nil), n.methods...), append([][]byte(nil), n.messages...)
+}
+
+// decodeFields splits a protobuf message into the values of its fields, varints encoded on their own.
+func decodeFields(t *testing.T, b []byte) map[protowire.Number][][]byte {
+	t.Helper()
+	fields := map[protowire.Number][][]byte{}
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			t.Fatalf("invalid tag: %v", protowire.ParseError(n))
+		}
+		b = b[n:]
+		var value []byte
+		switch typ {
+		case protowire.VarintType:
+			var v uint64
+			v, n = protowire.ConsumeVarint(b)
+			value = protowire.AppendVarint(nil, v)
+		case protowire.BytesType:
+			value, n = protowire.ConsumeBytes(b)
+		default:
+			t.Fatalf("field %d has unexpected wire type %d", num, typ)
+		}
+		if n < 0 {
+			t.Fatalf("invalid field %d: %v", num, protowire.ParseError(n))
+		}
+		b = b[n:]
+		fields[num] = append(fields[num], value)
+	}
+	return fields
+}
+
+func TestGRPCSink(t *testing.T) {
+	notifier := newTestNotifier(t, "127.0.0.1:0")
+	s, err := NewGRPCSink(notifier.addr, false, "1.2.3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	info := newTestRestartInfo()
+	info.PodUID = "0b6f0e6c"
+	info.Labels = map[string]string{"team": "payments"}
+	info.NeverReady = true
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.Notify(ctx, info); err != nil {
+		t.Fatal(err)
+	}
+
+	methods, messages := notifier.received()
+	if len(messages) != 1 {
+		t.Fatalf("%d messages, want 1", len(messages))
+	}
+	if methods[0] != grpcNotifyMethod {
+		t.Errorf("method = %q, want %q", methods[0], grpcNotifyMethod)
+	}
+	fields := decodeFields(t, messages[0])
+	for num, expected := range map[protowire.Number]string{
+		1:  "default",
+		2:  "web",
+		3:  "0b6f0e6c",
+		4:  "app",
+		10: oomKilledReason,
+		11: "out of memory",
+		13: "ContainerOOMKilled",
+		14: "Container app in pod default/web restarted.",
+		18: string(severityCritical),
+	} {
+		if len(fields[num]) != 1 || string(fields[num][0]) != expected {
+			t.Errorf("field %d = %q, want %q", num, fields[num], expected)
+		}
+	}
+	for num, expected := range map[protowire.Number]uint64{7: 3, 8: 1, 9: 137, 19: 1} {
+		if len(fields[num]) != 1 {
+			t.Errorf("field %d = %v, want %d", num, fields[num], expected)
+			continue
+		}
+		if v, _ := protowire.ConsumeVarint(fields[num][0]); v != expected {
+			t.Errorf("field %d = %d, want %d", num, v, expected)
+		}
+	}
+	// empty fields are omitted
+	for _, num := range []protowire.Number{5, 6, 16, 17} {
+		if _, ok := fields[num]; ok {
+			t.Errorf("empty field %d is sent", num)
+		}
+	}
+
+	var timestamp timestamppb.Timestamp
+	if len(fields[12]) != 1 {
+		t.Fatalf("%d timestamps, want 1", len(fields[12]))
+	}
+	if err := proto.Unmarshal(fields[12][0], &timestamp); err != nil {
+		t.Fatal(err)
+	}
+	if !timestamp.AsTime().Equal(info.Timestamp.Time) {
+		t.Errorf("timestamp = %v, want %v", timestamp.AsTime(), info.Timestamp.Time)
+	}
+	if len(fields[15]) != 1 {
+		t.Fatalf("%d labels, want 1", len(fields[15]))
+	}
+	label := decodeFields(t, fields[15][0])
+	if string(label[1][0]) != "team" || string(label[2][0]) != "payments" {
+		t.Errorf("label = %q: %q, want team: payments", label[1], label[2])
+	}
+}
+
+func TestGRPCSinkReconnects(t *testing.T) {
+	notifier := newTestNotifier(t, "127.0.0.1:0")
+	s, err := NewGRPCSink(notifier.addr, false, "1.2.3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err := s.Notify(context.Background(), newTestRestartInfo()); err != nil {
+		t.Fatal(err)
+	}
+
+	notifier.Stop()
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := s.Notify(ctx, newTestRestartInfo()); err == nil {
+		t.Fatal("notification succeeded while the server is down")
+	}
+
+	// waits for the connection to the restarted server
+	notifier = newTestNotifier(t, notifier.addr)
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := s.Notify(ctx, newTestRestartInfo()); err != nil {
+		t.Fatal(err)
+	}
+	if _, messages := notifier.received(); len(messages) != 1 {
+		t.Errorf("%d messages after reconnecting, want 1", len(messages))
+	}
+}
@@ -0,0 +1,70 @@
+package monitor
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type healthState struct {
+	sync.Mutex
+	staleness     time.Duration
+	standby       bool
+	lastActivity  time.Time
+	watchers      int
+	readyWatchers map[string]bool
+}
+
+// markAlive is called on every processed watch event and on every (re)established watch,
+// the latter happens at least every watchTimeout(1) even if no pods change.
+func (h *healthState) markAlive() {
+	h.Lock()
+	h.lastActivity = time.Now()
+	h.Unlock()
+}
+
+// setStandby marks a replica which is not the leader and thus doesn't watch pods.
+func (h *healthState) setStandby(standby bool) {
+	h.Lock()
+	h.standby = standby
+	h.lastActivity = time.Now()
+	h.Unlock()
+}
+
+func (h *healthState) setWatchers(watchers int) {
+	h.Lock()
+	h.watchers = watchers
+	h.Unlock()
+}
+
+func (h *healthState) markWatcherReady(namespace string) {
+	h.Lock()
+	h.readyWatchers[namespace] = true
+	h.Unlock()
+}
+
+func (h *healthState) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	h.Lock()
+	idle := time.Since(h.lastActivity)
+	standby := h.standby
+	h.Unlock()
+
+	if !standby && idle > h.staleness {
+		http.Error(w, fmt.Sprintf("no watch activity for %v", idle.Round(time.Second)), http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
+
+func (h *healthState) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	h.Lock()
+	ready := h.standby || len(h.readyWatchers) >= h.watchers
+	h.Unlock()
+
+	if !ready {
+		http.Error(w, "pod watch is not established", http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
@@ -0,0 +1,69 @@
+package monitor
+
+import (
+	"fmt"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const imagePullEventAction = "PullImage"
+
+type imagePullEntry struct {
+	reported time.Time
+	active   bool
+}
+
+// imagePullTracker reports containers failing to pull their image once per failure, and not more often
+// than the period (-cooldown). It is only used from the main loop.
+type imagePullTracker struct {
+	period  time.Duration
+	entries map[containerKey]*imagePullEntry
+}
+
+func isImagePullError(containerStatus *v1.ContainerStatus) bool {
+	waiting := containerStatus.State.Waiting
+	return waiting != nil && (waiting.Reason == "ImagePullBackOff" || waiting.Reason == "ErrImagePull")
+}
+
+// check reports whether the container started failing to pull its image and should be reported.
+func (t *imagePullTracker) check(pod *v1.Pod, containerStatus *v1.ContainerStatus) bool {
+	key := containerKey{pod.UID, containerStatus.Name}
+	entry, ok := t.entries[key]
+	if !isImagePullError(containerStatus) {
+		if ok {
+			entry.active = false
+		}
+		return false
+	}
+	if ok && (entry.active || time.Since(entry.reported) < t.period) {
+		entry.active = true
+		return false
+	}
+	t.entries[key] = &imagePullEntry{reported: time.Now(), active: true}
+	return true
+}
+
+func (t *imagePullTracker) forget(podUID types.UID) {
+	for key := range t.entries {
+		if key.podUID == podUID {
+			delete(t.entries, key)
+		}
+	}
+}
+
+func (m *Monitor) reportImagePullError(pod *v1.Pod, containerStatus *v1.ContainerStatus) {
+	waiting := containerStatus.State.Waiting
+	msg := fmt.Sprintf("Container %s in pod %s/%s cannot pull image %s: %s.", containerStatus.Name, pod.Namespace, pod.Name, containerStatus.Image, waiting.Reason)
+	if waiting.Message != "" {
+		msg += "\nMessage: " + waiting.Message
+	}
+	logRestart(msg, pod, containerStatus)
+
+	info := m.newRestartInfo(pod, containerStatus, 0, m.opts.ImagePullEventReason)
+	info.EventType = v1.EventTypeWarning
+	info.EventAction = imagePullEventAction
+	info.Message = msg
+	m.sinks.dispatch(info)
+}
@@ -0,0 +1,129 @@
+package monitor
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+)
+
+type kafkaConfig struct {
+	brokers       string
+	topic         string
+	tls           bool
+	saslMechanism string
+	saslUsername  string
+	saslPassword  string
+}
+
+// KafkaSink produces restarts as JSON records keyed by namespace/pod, so that the history of a pod
+// stays ordered in one partition. Records are produced asynchronously: only errors fetching the partitions
+// are returned, delivery errors are logged and the notifications saved to the deadletter directory.
+// Close flushes the records not produced yet.
+type KafkaSink struct {
+	writer     kafkaWriter
+	deadletter func(sink string, item sinkItem, err error)
+}
+
+// kafkaWriter is implemented by *kafka.Writer.
+type kafkaWriter interface {
+	WriteMessages(ctx context.Context, messages ...kafka.Message) error
+	Close() error
+}
+
+func NewKafkaSink(config *kafkaConfig) (*KafkaSink, error) {
+	transport := &kafka.Transport{}
+	if config.tls {
+		transport.TLS = &tls.Config{}
+	}
+	mechanism, err := kafkaSASLMechanism(config.saslMechanism, config.saslUsername, config.saslPassword)
+	if err != nil {
+		return nil, err
+	}
+	transport.SASL = mechanism
+
+	writer := &kafka.Writer{
+		Addr:      kafka.TCP(splitList(config.brokers)...),
+		Topic:     config.topic,
+		Balancer:  &kafka.Hash{},
+		Transport: transport,
+		// acknowledged by all in-sync replicas, otherwise lost records are not reported as errors
+		RequiredAcks: kafka.RequireAll,
+		// notifications are batched by the dispatcher (-batch-size), don't wait for more records
+		BatchTimeout: 10 * time.Millisecond,
+		Async:        true,
+	}
+	s := &KafkaSink{writer: writer, deadletter: func(string, sinkItem, error) {}}
+	writer.Completion = s.completed
+	return s, nil
+}
+
+// completed is called by the writer with every produced batch of records.
+func (s *KafkaSink) completed(messages []kafka.Message, err error) {
+	if err == nil {
+		return
+	}
+	slog.Warn("Unable to produce Kafka records", "records", len(messages), "err", err)
+	for _, message := range messages {
+		var info RestartInfo
+		if err := json.Unmarshal(message.Value, &info); err != nil {
+			continue
+		}
+		s.deadletter("kafka", sinkItem{info: &info}, err)
+	}
+}
+
+func kafkaSASLMechanism(name, username, password string) (sasl.Mechanism, error) {
+	switch strings.ToLower(name) {
+	case "":
+		return nil, nil
+	case "plain":
+		return plain.Mechanism{Username: username, Password: password}, nil
+	case "scram-sha-256":
+		return scram.Mechanism(scram.SHA256, username, password)
+	case "scram-sha-512":
+		return scram.Mechanism(scram.SHA512, username, password)
+	}
+	return nil, fmt.Errorf("unknown SASL mechanism %q, expected plain, scram-sha-256 or scram-sha-512", name)
+}
+
+func (s *KafkaSink) Notify(ctx context.Context, info *RestartInfo) error {
+	body, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	return s.writer.WriteMessages(ctx, kafka.Message{
+		Key:   []byte(info.Namespace + "/" + info.PodName),
+		Value: body,
+	})
+}
+
+func (s *KafkaSink) NotifyBatch(ctx context.Context, infos []*RestartInfo) error {
+	messages := make([]kafka.Message, len(infos))
+	for i, info := range infos {
+		body, err := json.Marshal(info)
+		if err != nil {
+			return err
+		}
+		messages[i] = kafka.Message{
+			Key:   []byte(info.Namespace + "/" + info.PodName),
+			Value: body,
+		}
+	}
+	return s.writer.WriteMessages(ctx, messages...)
+}
+
+// Close produces the pending records and closes the connections to the brokers.
+func (s *KafkaSink) Close() {
+	if err := s.writer.Close(); err != nil {
+		slog.Warn("Unable to close Kafka writer", "err", err)
+	}
+}
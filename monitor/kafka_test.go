@@ -0,0 +1,123 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+type fakeKafkaWriter struct {
+	messages []kafka.Message
+}
+
+func (w *fakeKafkaWriter) WriteMessages(ctx context.Context, messages ...kafka.Message) error {
+	w.messages = append(w.messages, messages...)
+	return nil
+}
+
+func (w *fakeKafkaWriter) Close() error {
+	return nil
+}
+
+func TestKafkaSinkRecords(t *testing.T) {
+	writer := &fakeKafkaWriter{}
+	s := &KafkaSink{writer: writer}
+	web := newTestRestartInfo()
+	worker := newTestRestartInfo()
+	worker.PodName = "worker"
+	if err := s.Notify(context.Background(), web); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.NotifyBatch(context.Background(), []*RestartInfo{web, worker}); err != nil {
+		t.Fatal(err)
+	}
+
+	expectedKeys := []string{"default/web", "default/web", "default/worker"}
+	if len(writer.messages) != len(expectedKeys) {
+		t.Fatalf("%d records, want %d", len(writer.messages), len(expectedKeys))
+	}
+	for i, message := range writer.messages {
+		if string(message.Key) != expectedKeys[i] {
+			t.Errorf("record %d key %q, want %q", i, message.Key, expectedKeys[i])
+		}
+		var info RestartInfo
+		if err := json.Unmarshal(message.Value, &info); err != nil {
+			t.Fatal(err)
+		}
+		if info.Namespace+"/"+info.PodName != expectedKeys[i] || info.Container != "app" || info.ExitCode != 137 {
+			t.Errorf("record %d value %s, want the restart info of %s", i, message.Value, expectedKeys[i])
+		}
+	}
+}
+
+func TestKafkaSASLMechanism(t *testing.T) {
+	for _, name := range []string{"", "PLAIN", "scram-sha-256", "scram-sha-512"} {
+		if _, err := kafkaSASLMechanism(name, "user", "password"); err != nil {
+			t.Errorf("SASL mechanism %q: %v", name, err)
+		}
+	}
+	if _, err := kafkaSASLMechanism("gssapi", "user", "password"); err == nil {
+		t.Error("unknown SASL mechanism accepted")
+	}
+}
+
+func TestKafkaSinkReturnsMetadataErrors(t *testing.T) {
+	// a closed port, so that fetching the partitions of the topic fails
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	s, err := NewKafkaSink(&kafkaConfig{brokers: addr, topic: "restarts"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := s.Notify(ctx, &RestartInfo{Namespace: "default", PodName: "web"}); err == nil {
+		t.Error("no error producing to an unavailable broker")
+	}
+}
+
+func TestKafkaSinkDeadlettersUndeliveredRecords(t *testing.T) {
+	s, err := NewKafkaSink(&kafkaConfig{brokers: "127.0.0.1:9092", topic: "restarts"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer := s.writer.(*kafka.Writer)
+	if !writer.Async || writer.Completion == nil {
+		t.Fatal("records are produced synchronously")
+	}
+	var deadletters []string
+	s.deadletter = func(sink string, item sinkItem, err error) {
+		deadletters = append(deadletters, sink+" "+item.info.Namespace+"/"+item.info.PodName)
+	}
+
+	var messages []kafka.Message
+	for _, pod := range []string{"web", "worker"} {
+		body, err := json.Marshal(&RestartInfo{Namespace: "default", PodName: pod})
+		if err != nil {
+			t.Fatal(err)
+		}
+		messages = append(messages, kafka.Message{Value: body})
+	}
+	writer.Completion(messages, nil)
+	if len(deadletters) != 0 {
+		t.Errorf("deadletters %v of delivered records", deadletters)
+	}
+	writer.Completion(messages, errors.New("not enough replicas"))
+	expected := []string{"kafka default/web", "kafka default/worker"}
+	if !reflect.DeepEqual(deadletters, expected) {
+		t.Errorf("deadletters %v, want %v", deadletters, expected)
+	}
+}
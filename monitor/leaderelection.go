@@ -0,0 +1,94 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+const leaderElectionLeaseName = "kube-restart-monitor"
+
+var errLeadershipLost = errors.New("leadership lost")
+
+// runWithLeaderElection blocks until ctx is done, calling run while this replica is the leader.
+// Standby replicas report healthy and ready without watching pods. It fails if run fails or
+// the leadership is lost, as another replica may already be emitting events: the caller should
+// start over as standby.
+func (m *Monitor) runWithLeaderElection(ctx context.Context, namespace string, run func(ctx context.Context) error) error {
+	identity, err := os.Hostname()
+	if err != nil {
+		return fmt.Errorf("unable to set up leader election: %w", err)
+	}
+
+	lock, err := resourcelock.New(
+		resourcelock.LeasesResourceLock,
+		namespace,
+		leaderElectionLeaseName,
+		m.client.CoreV1(),
+		m.client.CoordinationV1(),
+		resourcelock.ResourceLockConfig{Identity: identity},
+	)
+	if err != nil {
+		return fmt.Errorf("unable to set up leader election: %w", err)
+	}
+
+	electionCtx, stop := context.WithCancelCause(ctx)
+	defer stop(nil)
+	// RunOrDie does not wait for the callback, which must return before the sinks are closed
+	var (
+		mu       sync.Mutex
+		returned bool
+		running  sync.WaitGroup
+	)
+	m.health.setStandby(true)
+	leaderelection.RunOrDie(electionCtx, leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		LeaseDuration:   15 * time.Second,
+		RenewDeadline:   10 * time.Second,
+		RetryPeriod:     2 * time.Second,
+		ReleaseOnCancel: true,
+		Name:            leaderElectionLeaseName,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				mu.Lock()
+				if returned {
+					mu.Unlock()
+					return
+				}
+				running.Add(1)
+				mu.Unlock()
+				defer running.Done()
+
+				slog.Info("Started leading", "identity", identity)
+				m.health.setStandby(false)
+				if err := run(ctx); err != nil {
+					stop(err)
+				}
+			},
+			OnStoppedLeading: func() {
+				stop(errLeadershipLost)
+			},
+			OnNewLeader: func(leader string) {
+				if leader != identity {
+					slog.Info("New leader elected", "leader", leader)
+				}
+			},
+		},
+	})
+	mu.Lock()
+	returned = true
+	mu.Unlock()
+	running.Wait()
+
+	if ctx.Err() != nil {
+		return nil
+	}
+	return context.Cause(electionCtx)
+}
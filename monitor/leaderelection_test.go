@@ -0,0 +1,120 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func (h *healthState) isStandby() bool {
+	h.Lock()
+	defer h.Unlock()
+	return h.standby
+}
+
+func runLeaderElection(m *Monitor, run func(ctx context.Context) error) (context.CancelFunc, <-chan error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- m.runWithLeaderElection(ctx, "default", run)
+	}()
+	return cancel, done
+}
+
+func TestLeaderElectionStandby(t *testing.T) {
+	m, client := newTestMonitor(t, DefaultOptions())
+	holder := "other"
+	leaseDuration := int32(15)
+	now := metav1.NewMicroTime(time.Now())
+	_, err := client.CoordinationV1().Leases("default").Create(context.Background(), &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: leaderElectionLeaseName},
+		Spec: coordinationv1.LeaseSpec{
+			HolderIdentity:       &holder,
+			LeaseDurationSeconds: &leaseDuration,
+			AcquireTime:          &now,
+			RenewTime:            &now,
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	started := make(chan struct{}, 1)
+	cancel, done := runLeaderElection(m, func(ctx context.Context) error {
+		started <- struct{}{}
+		return nil
+	})
+	select {
+	case <-started:
+		t.Fatal("standby replica started monitoring")
+	case <-time.After(200 * time.Millisecond):
+	}
+	if !m.health.isStandby() {
+		t.Error("replica is not standby while another replica leads")
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("leader election failed: %v", err)
+	}
+}
+
+func TestLeaderElectionLeader(t *testing.T) {
+	m, client := newTestMonitor(t, DefaultOptions())
+
+	started := make(chan struct{})
+	stopped := make(chan struct{})
+	cancel, done := runLeaderElection(m, func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		close(stopped)
+		return nil
+	})
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("leader did not start monitoring")
+	}
+	if m.health.isStandby() {
+		t.Error("leader is standby")
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("leader election failed: %v", err)
+	}
+	select {
+	case <-stopped:
+	default:
+		t.Error("monitoring is not stopped after leader election returned")
+	}
+	lease, err := client.CoordinationV1().Leases("default").Get(context.Background(), leaderElectionLeaseName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lease.Spec.HolderIdentity != nil && *lease.Spec.HolderIdentity != "" {
+		t.Errorf("lease is held by %q after cancellation, want released", *lease.Spec.HolderIdentity)
+	}
+}
+
+func TestLeaderElectionRunFailure(t *testing.T) {
+	m, _ := newTestMonitor(t, DefaultOptions())
+	errRun := errors.New("watch failed")
+
+	cancel, done := runLeaderElection(m, func(ctx context.Context) error {
+		return errRun
+	})
+	defer cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, errRun) {
+			t.Errorf("leader election error = %v, want %v", err, errRun)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("leader election did not return after monitoring failed")
+	}
+}
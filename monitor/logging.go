@@ -0,0 +1,20 @@
+package monitor
+
+import (
+	"log/slog"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// logRestart logs a restart message with the container identity and termination fields.
+func logRestart(msg string, pod *v1.Pod, containerStatus *v1.ContainerStatus) {
+	args := []any{
+		"namespace", pod.Namespace,
+		"pod", pod.Name,
+		"container", containerStatus.Name,
+	}
+	if t := containerStatus.LastTerminationState.Terminated; t != nil {
+		args = append(args, "exitCode", t.ExitCode, "reason", t.Reason)
+	}
+	slog.Info(msg, args...)
+}
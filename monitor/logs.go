@@ -0,0 +1,36 @@
+package monitor
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	logsFetchTimeout = 5 * time.Second
+	logsMaxBytes     = 512
+)
+
+// fetchPreviousLogs returns the tail of the logs of the previous (terminated) container instance.
+// Only the last logsMaxBytes bytes are kept.
+func (m *Monitor) fetchPreviousLogs(ctx context.Context, pod *v1.Pod, containerName string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, logsFetchTimeout)
+	defer cancel()
+
+	raw, err := m.client.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &v1.PodLogOptions{
+		Container: containerName,
+		Previous:  true,
+		TailLines: &m.opts.LogTailLines,
+	}).DoRaw(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	logs := strings.TrimRight(string(raw), "\n")
+	if len(logs) > logsMaxBytes {
+		logs = "…" + strings.ToValidUTF8(logs[len(logs)-logsMaxBytes:], "")
+	}
+	return logs, nil
+}
@@ -0,0 +1,44 @@
+package monitor
+
+import (
+	"context"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestFetchPreviousLogs(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	opts := DefaultOptions()
+	opts.LogTailLines = 5
+	m, err := New(client, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"}}
+	logs, err := m.fetchPreviousLogs(context.Background(), pod, "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the canned logs of the fake clientset
+	if logs != "fake logs" {
+		t.Errorf("logs = %q, want fake logs", logs)
+	}
+
+	var logOptions *v1.PodLogOptions
+	for _, action := range client.Actions() {
+		if action.GetSubresource() == "log" {
+			logOptions, _ = action.(k8stesting.GenericAction).GetValue().(*v1.PodLogOptions)
+		}
+	}
+	if logOptions == nil {
+		t.Fatal("logs are not requested")
+	}
+	if logOptions.Container != "app" || !logOptions.Previous || logOptions.TailLines == nil || *logOptions.TailLines != 5 {
+		t.Errorf("log options = %+v, want the last 5 lines of the previous app container", logOptions)
+	}
+}
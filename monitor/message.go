@@ -0,0 +1,236 @@
+package monitor
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// events.k8s.io/v1 rejects notes longer than 1kB
+const maxEventMessageBytes = 1024
+
+var signalNames = map[int32]string{
+	1:  "SIGHUP",
+	2:  "SIGINT",
+	3:  "SIGQUIT",
+	4:  "SIGILL",
+	6:  "SIGABRT",
+	7:  "SIGBUS",
+	8:  "SIGFPE",
+	9:  "SIGKILL",
+	11: "SIGSEGV",
+	13: "SIGPIPE",
+	14: "SIGALRM",
+	15: "SIGTERM",
+}
+
+// messageData is available to the -message-template.
+type messageData struct {
+	Namespace      string
+	Pod            string
+	Container      string
+	Node           string
+	NodeConditions []string
+	OwnerKind      string
+	OwnerName      string
+	Image          string
+	ImageID        string
+	RestartCount   int32
+	ExitCode       int32
+	Signal         string
+	Reason         string
+	Message        string
+	RunDuration    time.Duration
+}
+
+func (m *Monitor) newMessageData(pod *v1.Pod, containerStatus *v1.ContainerStatus) *messageData {
+	owner := m.owners.ownerOf(pod)
+	data := &messageData{
+		Namespace:      pod.Namespace,
+		Pod:            pod.Name,
+		Container:      containerStatus.Name,
+		Node:           pod.Spec.NodeName,
+		NodeConditions: m.nodeConditions.get(pod.Spec.NodeName),
+		OwnerKind:      owner.Kind,
+		OwnerName:      owner.Name,
+		Image:          containerStatus.Image,
+		ImageID:        containerStatus.ImageID,
+		RestartCount:   containerStatus.RestartCount,
+	}
+	if t := containerStatus.LastTerminationState.Terminated; t != nil {
+		data.ExitCode = t.ExitCode
+		data.Signal = exitSignal(t.ExitCode)
+		data.RunDuration, _ = containerRunDuration(t)
+		data.Reason = t.Reason
+		data.Message = sanitizeText(t.Message, m.opts.MaxMessageBytes)
+	}
+	return data
+}
+
+// parseMessageTemplate returns nil for an empty text, i.e. the default message. The template is executed
+// once with sample data, so that references to unknown fields fail at startup rather than on restarts.
+func parseMessageTemplate(text string) (*template.Template, error) {
+	if text == "" {
+		return nil, nil
+	}
+	tmpl, err := template.New("message").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	sample := &messageData{
+		Namespace:      "default",
+		Pod:            "web-0",
+		Container:      "app",
+		Node:           "node-1",
+		NodeConditions: []string{"MemoryPressure"},
+		OwnerKind:      "StatefulSet",
+		OwnerName:      "web",
+		Image:          "app:latest",
+		ImageID:        "docker-pullable://app@sha256:0",
+		RestartCount:   1,
+		ExitCode:       137,
+		Signal:         "SIGKILL",
+		Reason:         oomKilledReason,
+		Message:        "out of memory",
+		RunDuration:    time.Minute,
+	}
+	if err := tmpl.Execute(io.Discard, sample); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+func (m *Monitor) formatMessage(pod *v1.Pod, containerStatus *v1.ContainerStatus) string {
+	if m.messageTemplate == nil {
+		return m.formatDefaultMessage(pod, containerStatus)
+	}
+
+	var buf strings.Builder
+	err := m.messageTemplate.Execute(&buf, m.newMessageData(pod, containerStatus))
+	if err != nil {
+		slog.Warn("Unable to execute message template", "err", err)
+		return m.formatDefaultMessage(pod, containerStatus)
+	}
+	return buf.String()
+}
+
+func (m *Monitor) formatDefaultMessage(pod *v1.Pod, containerStatus *v1.ContainerStatus) string {
+	msg := fmt.Sprintf("Container %s in pod %s/%s restarted.", containerStatus.Name, pod.Namespace, pod.Name)
+	if owner := m.owners.ownerOf(pod); owner.Kind != "" {
+		msg += fmt.Sprintf("\nOwner: %s.", owner)
+	}
+	if node := pod.Spec.NodeName; node != "" {
+		if conditions := m.nodeConditions.get(node); len(conditions) > 0 {
+			msg += fmt.Sprintf("\nNode: %s (%s).", node, strings.Join(conditions, ", "))
+		} else {
+			msg += fmt.Sprintf("\nNode: %s.", node)
+		}
+	}
+	if containerStatus.ImageID != "" {
+		msg += fmt.Sprintf("\nImage: %s (%s).", containerStatus.Image, containerStatus.ImageID)
+	} else if containerStatus.Image != "" {
+		msg += fmt.Sprintf("\nImage: %s.", containerStatus.Image)
+	}
+	t := containerStatus.LastTerminationState.Terminated
+	if t == nil {
+		return msg
+	}
+	msg += fmt.Sprintf("\nReason: %s, exit code: %s.", t.Reason, formatExitCode(t.ExitCode))
+	if runDuration, ok := containerRunDuration(t); ok {
+		msg += fmt.Sprintf(" Ran for %v before restart.", runDuration)
+	}
+	if t.Reason == oomKilledReason {
+		msg += "\n" + formatMemoryResources(pod, containerStatus.Name)
+	}
+	if t.Message != "" {
+		msg += "\nMessage: " + sanitizeText(t.Message, m.opts.MaxMessageBytes)
+	}
+	return msg
+}
+
+// containerRunDuration returns how long the terminated container ran, rounded to seconds.
+func containerRunDuration(t *v1.ContainerStateTerminated) (time.Duration, bool) {
+	if t.StartedAt.IsZero() || t.FinishedAt.IsZero() || t.FinishedAt.Before(&t.StartedAt) {
+		return 0, false
+	}
+	return t.FinishedAt.Sub(t.StartedAt.Time).Round(time.Second), true
+}
+
+// exitSignal returns the name of the signal that killed the process, for exit codes of the form 128 + signal.
+func exitSignal(exitCode int32) string {
+	if exitCode <= 128 || exitCode > 128+64 {
+		return ""
+	}
+	if name, ok := signalNames[exitCode-128]; ok {
+		return name
+	}
+	return fmt.Sprintf("signal %d", exitCode-128)
+}
+
+func formatExitCode(exitCode int32) string {
+	if signal := exitSignal(exitCode); signal != "" {
+		return fmt.Sprintf("%d (%s)", exitCode, signal)
+	}
+	return fmt.Sprint(exitCode)
+}
+
+// sanitizeText strips control characters except newlines and tabs, and truncates text to maxBytes
+// (if positive) with an ellipsis.
+func sanitizeText(text string, maxBytes int) string {
+	text = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) && r != '\n' && r != '\t' {
+			return -1
+		}
+		return r
+	}, strings.ToValidUTF8(text, "�"))
+	return truncateText(text, maxBytes)
+}
+
+// truncateText cuts text to at most maxBytes (if positive) at a rune boundary, ending it with an ellipsis
+// unless maxBytes is too small to fit one.
+func truncateText(text string, maxBytes int) string {
+	const ellipsis = "…"
+	if maxBytes <= 0 || len(text) <= maxBytes {
+		return text
+	}
+	suffix := ellipsis
+	if maxBytes < len(ellipsis) {
+		suffix = ""
+	}
+	cut := maxBytes - len(suffix)
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	return text[:cut] + suffix
+}
+
+func formatMemoryResources(pod *v1.Pod, containerName string) string {
+	limit, request := "not set", "not set"
+	if container := findContainer(pod, containerName); container != nil {
+		if q, ok := container.Resources.Limits[v1.ResourceMemory]; ok {
+			limit = q.String()
+		}
+		if q, ok := container.Resources.Requests[v1.ResourceMemory]; ok {
+			request = q.String()
+		}
+	}
+	return fmt.Sprintf("Memory limit: %s, request: %s.", limit, request)
+}
+
+func findContainer(pod *v1.Pod, name string) *v1.Container {
+	for _, containers := range [][]v1.Container{pod.Spec.Containers, pod.Spec.InitContainers} {
+		for i := range containers {
+			if containers[i].Name == name {
+				return &containers[i]
+			}
+		}
+	}
+	return nil
+}
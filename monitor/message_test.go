@@ -0,0 +1,202 @@
+package monitor
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestMessageTemplate(t *testing.T) {
+	opts := DefaultOptions()
+	opts.MessageTemplate = "{{.Namespace}}/{{.Pod}} {{.Container}} on {{.Node}} ({{.Image}}): {{.Reason}}, exit code {{.ExitCode}}, {{.RestartCount}} restarts"
+	m, err := New(fake.NewSimpleClientset(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"},
+		Spec:       v1.PodSpec{NodeName: "node-1"},
+	}
+	containerStatus := &v1.ContainerStatus{
+		Name:         "app",
+		Image:        "app:latest",
+		RestartCount: 2,
+		LastTerminationState: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{
+			ExitCode: 1,
+			Reason:   "Error",
+		}},
+	}
+	expected := "default/web app on node-1 (app:latest): Error, exit code 1, 2 restarts"
+	if msg := m.formatMessage(pod, containerStatus); msg != expected {
+		t.Errorf("message = %q, want %q", msg, expected)
+	}
+}
+
+func TestMessageTemplateErrors(t *testing.T) {
+	for _, text := range []string{
+		"{{.Pod",
+		"{{.Unknown}}",
+		"{{template \"missing\"}}",
+		"{{.Pod.Name}}",
+	} {
+		opts := DefaultOptions()
+		opts.MessageTemplate = text
+		if _, err := New(fake.NewSimpleClientset(), opts); err == nil || !strings.Contains(err.Error(), "invalid message template") {
+			t.Errorf("template %q: error = %v, want invalid message template", text, err)
+		}
+	}
+}
+
+func TestTruncateText(t *testing.T) {
+	for _, tc := range []struct {
+		text     string
+		maxBytes int
+		expected string
+	}{
+		{"hello", 0, "hello"},
+		{"hello", 5, "hello"},
+		{"hello world", 8, "hello…"},
+		{"hello", 3, "…"},
+		{"hello", 2, "he"},
+		{"hello", 1, "h"},
+		{"привет", 7, "пр…"},
+		{"привет", 2, "п"},
+		{"привет", 1, ""},
+	} {
+		if actual := truncateText(tc.text, tc.maxBytes); actual != tc.expected {
+			t.Errorf("truncateText(%q, %d) = %q, want %q", tc.text, tc.maxBytes, actual, tc.expected)
+		}
+		if tc.maxBytes > 0 && len(truncateText(tc.text, tc.maxBytes)) > tc.maxBytes {
+			t.Errorf("truncateText(%q, %d) is longer than %d bytes", tc.text, tc.maxBytes, tc.maxBytes)
+		}
+	}
+}
+
+func TestSanitizeText(t *testing.T) {
+	for _, tc := range []struct {
+		text     string
+		maxBytes int
+		expected string
+	}{
+		{"panic: boom\n\tat main.go:1", 0, "panic: boom\n\tat main.go:1"},
+		{"bell\a and escape \x1b[31mred\x1b[0m", 0, "bell and escape [31mred[0m"},
+		{"binary \xff\xfe\x00 data", 0, "binary � data"},
+		{strings.Repeat("x", 100), 10, "xxxxxxx…"},
+	} {
+		if actual := sanitizeText(tc.text, tc.maxBytes); actual != tc.expected {
+			t.Errorf("sanitizeText(%q, %d) = %q, want %q", tc.text, tc.maxBytes, actual, tc.expected)
+		}
+	}
+}
+
+func TestFormatDefaultMessage(t *testing.T) {
+	m, err := New(fake.NewSimpleClientset(), DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"},
+		Spec:       v1.PodSpec{NodeName: "node-1"},
+	}
+	finishedAt := metav1.Now()
+	containerStatus := &v1.ContainerStatus{
+		Name:    "app",
+		Image:   "app:1.0",
+		ImageID: "docker-pullable://app@sha256:0123",
+		LastTerminationState: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{
+			ExitCode:   1,
+			Reason:     "Error",
+			Message:    "panic: boom",
+			StartedAt:  metav1.NewTime(finishedAt.Add(-90 * time.Second)),
+			FinishedAt: finishedAt,
+		}},
+	}
+
+	expected := "Container app in pod default/web restarted.\n" +
+		"Node: node-1.\n" +
+		"Image: app:1.0 (docker-pullable://app@sha256:0123).\n" +
+		"Reason: Error, exit code: 1. Ran for 1m30s before restart.\n" +
+		"Message: panic: boom"
+	if msg := m.formatMessage(pod, containerStatus); msg != expected {
+		t.Errorf("message = %q, want %q", msg, expected)
+	}
+
+	containerStatus.ImageID = ""
+	if msg := m.formatMessage(pod, containerStatus); !strings.Contains(msg, "\nImage: app:1.0.\n") {
+		t.Errorf("message %q has no image without image ID", msg)
+	}
+}
+
+func TestFormatExitCode(t *testing.T) {
+	for exitCode, expected := range map[int32]string{
+		137: "137 (SIGKILL)",
+		143: "143 (SIGTERM)",
+		139: "139 (SIGSEGV)",
+		158: "158 (signal 30)",
+		1:   "1",
+		128: "128",
+		255: "255",
+	} {
+		if actual := formatExitCode(exitCode); actual != expected {
+			t.Errorf("formatExitCode(%d) = %q, want %q", exitCode, actual, expected)
+		}
+	}
+
+	m, err := New(fake.NewSimpleClientset(), DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"}}
+	containerStatus := &v1.ContainerStatus{
+		Name:                 "app",
+		LastTerminationState: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{ExitCode: 137, Reason: "OOMKilled"}},
+	}
+	if msg := m.formatMessage(pod, containerStatus); !strings.Contains(msg, "exit code: 137 (SIGKILL)") {
+		t.Errorf("message %q has no decoded signal", msg)
+	}
+}
+
+func TestContainerRunDuration(t *testing.T) {
+	finishedAt := time.Date(2021, 5, 1, 12, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		startedAt, finishedAt time.Time
+		expected              string
+	}{
+		{finishedAt.Add(-4*time.Second - 300*time.Millisecond), finishedAt, "4s"},
+		{finishedAt.Add(-3 * 24 * time.Hour), finishedAt, "72h0m0s"},
+		{finishedAt, finishedAt, "0s"},
+		{time.Time{}, finishedAt, ""},
+		{finishedAt, time.Time{}, ""},
+		{finishedAt.Add(time.Second), finishedAt, ""},
+	} {
+		terminated := &v1.ContainerStateTerminated{StartedAt: metav1.NewTime(tc.startedAt), FinishedAt: metav1.NewTime(tc.finishedAt)}
+		actual := ""
+		if runDuration, ok := containerRunDuration(terminated); ok {
+			actual = runDuration.String()
+		}
+		if actual != tc.expected {
+			t.Errorf("run duration from %v to %v = %q, want %q", tc.startedAt, tc.finishedAt, actual, tc.expected)
+		}
+	}
+
+	m, err := New(fake.NewSimpleClientset(), DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"}}
+	containerStatus := &v1.ContainerStatus{
+		Name: "app",
+		LastTerminationState: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{
+			ExitCode: 1,
+			Reason:   "Error",
+		}},
+	}
+	if msg := m.formatMessage(pod, containerStatus); strings.Contains(msg, "Ran for") {
+		t.Errorf("message %q has a run duration without timestamps", msg)
+	}
+}
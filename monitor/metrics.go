@@ -0,0 +1,126 @@
+package monitor
+
+import (
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	startsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "restart_monitor_starts_total",
+		Help: "Number of starts of the monitor, 1 for every process. A reset means restarts may have been missed meanwhile.",
+	})
+
+	containerRestartsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "restart_monitor_container_restarts_total",
+		Help: "Number of detected container restarts.",
+	}, []string{"namespace", "pod", "container", "reason", "oom_killed"})
+
+	containerRestartsByCodeTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "restart_monitor_container_restarts_by_code_total",
+		Help: "Number of detected container restarts by termination reason and exit code. Uncommon exit codes are reported as \"other\".",
+	}, []string{"namespace", "reason", "exit_code"})
+
+	eventsEmittedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "restart_monitor_events_emitted_total",
+		Help: "Number of successfully created Kubernetes events.",
+	})
+
+	eventErrorsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "restart_monitor_event_errors_total",
+		Help: "Number of failed Kubernetes event creations.",
+	})
+
+	restartIntervalSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "restart_monitor_interval_seconds",
+		Help:    "Time between consecutive restarts of the same container.",
+		Buckets: []float64{1, 5, 10, 30, 60, 120, 300, 600, 1800, 3600},
+	}, []string{"namespace"})
+
+	watchReconnectsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "restart_monitor_watch_reconnects_total",
+		Help: "Number of pod watch reconnects.",
+	})
+
+	watchExpiredTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "restart_monitor_watch_expired_total",
+		Help: "Number of pod watches and lists failed with an expired resourceVersion.",
+	})
+
+	watchEventChannelDepth = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "restart_monitor_watch_event_channel_depth",
+		Help: "Number of watch events waiting to be processed, up to -channel-buffer.",
+	})
+
+	watchEventChannelBlockedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "restart_monitor_watch_event_channel_blocked_total",
+		Help: "Number of times a watch blocked because the watch event buffer was full.",
+	})
+
+	rateLimitedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "restart_monitor_rate_limited_total",
+		Help: "Number of restart reports dropped by -namespace-rate-limit.",
+	}, []string{"namespace"})
+
+	restartStormsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "restart_monitor_restart_storms_total",
+		Help: "Number of detected restart storms, see -storm-threshold.",
+	})
+
+	restartStormActive = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "restart_monitor_restart_storm_active",
+		Help: "1 while a restart storm is going on, 0 otherwise.",
+	})
+
+	secondsSinceLastEvent = prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "restart_monitor_seconds_since_last_event",
+		Help: "Seconds since the last processed watch event, including resyncs. 0 until pods are watched, e.g. in standby replicas.",
+	}, func() float64 {
+		last := lastEventTime.Load()
+		if last == 0 {
+			return 0
+		}
+		return time.Since(time.Unix(0, last)).Seconds()
+	})
+
+	trackedPods = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "restart_monitor_tracked_pods",
+		Help: "Number of pods whose container restart counts are tracked.",
+	})
+)
+
+// unix nanoseconds of the last watch event processed by the main loop, read on scrapes
+var lastEventTime atomic.Int64
+
+func registerMetrics() {
+	prometheus.MustRegister(
+		startsTotal,
+		containerRestartsTotal,
+		containerRestartsByCodeTotal,
+		watchReconnectsTotal,
+		watchExpiredTotal,
+		eventsEmittedTotal,
+		eventErrorsTotal,
+		restartIntervalSeconds,
+		watchEventChannelDepth,
+		watchEventChannelBlockedTotal,
+		rateLimitedTotal,
+		restartStormsTotal,
+		restartStormActive,
+		secondsSinceLastEvent,
+		trackedPods,
+	)
+}
+
+// exitCodeLabel keeps the cardinality of exit_code labels low: only exit codes with a common meaning
+// are exposed as is.
+func exitCodeLabel(exitCode int32) string {
+	switch {
+	case exitCode >= 0 && exitCode <= 2, exitCode == 126, exitCode == 127, exitSignal(exitCode) != "" && exitCode-128 <= 15:
+		return strconv.Itoa(int(exitCode))
+	}
+	return "other"
+}
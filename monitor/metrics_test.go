@@ -0,0 +1,190 @@
+package monitor
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func histogram(t *testing.T, observer prometheus.Observer) *dto.Histogram {
+	t.Helper()
+	var metric dto.Metric
+	if err := observer.(prometheus.Metric).Write(&metric); err != nil {
+		t.Fatal(err)
+	}
+	return metric.Histogram
+}
+
+func TestRestartIntervalHistogram(t *testing.T) {
+	m, _ := newTestMonitor(t, DefaultOptions())
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "interval", Name: "web", UID: "web"}}
+	finishedAt := time.Now().Add(-time.Hour)
+	restart := func(restartCount int32, after time.Duration) *v1.ContainerStatus {
+		finishedAt = finishedAt.Add(after)
+		return &v1.ContainerStatus{
+			Name:         "app",
+			RestartCount: restartCount,
+			LastTerminationState: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{
+				ExitCode:   1,
+				FinishedAt: metav1.NewTime(finishedAt),
+			}},
+		}
+	}
+
+	// the first restart has no previous one
+	m.observeRestartInterval(pod, restart(1, 0), 1)
+	m.observeRestartInterval(pod, restart(2, 90*time.Second), 1)
+	// spread over the restarts in between
+	m.observeRestartInterval(pod, restart(5, 30*time.Second), 3)
+
+	h := histogram(t, restartIntervalSeconds.WithLabelValues("interval"))
+	if h.GetSampleCount() != 4 || h.GetSampleSum() != 120 {
+		t.Errorf("%d observations with sum %v, want 4 with sum 120", h.GetSampleCount(), h.GetSampleSum())
+	}
+	for _, bucket := range h.Bucket {
+		expected := uint64(0)
+		if bucket.GetUpperBound() >= 10 {
+			expected = 3
+		}
+		if bucket.GetUpperBound() >= 120 {
+			expected = 4
+		}
+		if bucket.GetCumulativeCount() != expected {
+			t.Errorf("bucket %v has %d observations, want %d", bucket.GetUpperBound(), bucket.GetCumulativeCount(), expected)
+		}
+	}
+}
+
+func waitForCounter(t *testing.T, counter prometheus.Collector, expected float64) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for testutil.ToFloat64(counter) < expected && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if actual := testutil.ToFloat64(counter); actual != expected {
+		t.Errorf("counter = %v, want %v", actual, expected)
+	}
+}
+
+func TestWatchExpiredAndReconnectCounters(t *testing.T) {
+	m, client := newTestMonitor(t, DefaultOptions())
+	watchers := make(chan *watch.FakeWatcher, 10)
+	client.PrependWatchReactor("pods", func(action k8stesting.Action) (bool, watch.Interface, error) {
+		watcher := watch.NewFakeWithChanSize(1, false)
+		watchers <- watcher
+		return true, watcher, nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	informer, err := m.newPodInformer(ctx, "", "", make(chan WatchEvent, 10), func(err error) {
+		t.Errorf("watch failed: %v", err)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired := testutil.ToFloat64(watchExpiredTotal)
+	reconnects := testutil.ToFloat64(watchReconnectsTotal)
+	go m.runPodInformer(ctx, "", informer)
+
+	watcher := <-watchers
+	watcher.Error(&metav1.Status{Status: metav1.StatusFailure, Code: http.StatusGone, Reason: metav1.StatusReasonExpired})
+	waitForCounter(t, watchExpiredTotal, expired+1)
+	// the pods are relisted and watched again
+	waitForCounter(t, watchReconnectsTotal, reconnects+1)
+}
+
+func TestRestartsByCodeLabels(t *testing.T) {
+	m, _ := newTestMonitor(t, DefaultOptions())
+	restart := func(name string, exitCode int32, reason string) {
+		pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "by-code", Name: name, UID: types.UID(name)}}
+		containerStatus := &v1.ContainerStatus{
+			Name:                 "app",
+			RestartCount:         1,
+			LastTerminationState: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{ExitCode: exitCode, Reason: reason}},
+		}
+		m.handleContainerRestart(context.Background(), pod, regularContainer, containerStatus, 1, false, false)
+	}
+	restart("oom", 137, oomKilledReason)
+	restart("error", 1, "Error")
+	restart("custom", 42, "Error")
+
+	for _, tc := range []struct {
+		reason, exitCode string
+		expected         float64
+	}{
+		{oomKilledReason, "137", 1},
+		{"Error", "1", 1},
+		{"Error", "other", 1},
+		{oomKilledReason, "1", 0},
+		{"Error", "137", 0},
+	} {
+		if actual := testutil.ToFloat64(containerRestartsByCodeTotal.WithLabelValues("by-code", tc.reason, tc.exitCode)); actual != tc.expected {
+			t.Errorf("restarts with reason %s and exit code %s = %v, want %v", tc.reason, tc.exitCode, actual, tc.expected)
+		}
+	}
+	if actual := testutil.ToFloat64(containerRestartsTotal.WithLabelValues("by-code", "oom", "app", oomKilledReason, "true")); actual != 1 {
+		t.Errorf("OOM killed restarts = %v, want 1", actual)
+	}
+}
+
+func TestSecondsSinceLastEvent(t *testing.T) {
+	lastEventTime.Store(0)
+	trackedPods.Set(0)
+	if actual := testutil.ToFloat64(secondsSinceLastEvent); actual != 0 {
+		t.Errorf("gauge before watching = %v, want 0", actual)
+	}
+
+	opts := DefaultOptions()
+	opts.EnableLeaderElection = false
+	m, client := newTestMonitor(t, opts)
+	watcher := watch.NewFake()
+	client.PrependWatchReactor("pods", k8stesting.DefaultWatchReactor(watcher, nil))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- m.Run(ctx)
+	}()
+	defer func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("Run returned %v", err)
+		}
+	}()
+
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "liveness", Name: "web", UID: "liveness/web"}}
+	// blocks until the monitor watches
+	watcher.Add(pod)
+	deadline := time.Now().Add(5 * time.Second)
+	for testutil.ToFloat64(trackedPods) != 1 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	lastEventTime.Store(time.Now().Add(-time.Minute).UnixNano())
+	if actual := testutil.ToFloat64(secondsSinceLastEvent); actual < 60 {
+		t.Errorf("gauge a minute after the last event = %v, want at least 60", actual)
+	}
+	shortly := testutil.ToFloat64(secondsSinceLastEvent)
+	time.Sleep(50 * time.Millisecond)
+	if actual := testutil.ToFloat64(secondsSinceLastEvent); actual <= shortly {
+		t.Errorf("gauge = %v, want more than %v without events", actual, shortly)
+	}
+
+	watcher.Modify(pod)
+	deadline = time.Now().Add(5 * time.Second)
+	for testutil.ToFloat64(secondsSinceLastEvent) >= 60 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if actual := testutil.ToFloat64(secondsSinceLastEvent); actual >= 60 {
+		t.Errorf("gauge after an event = %v, want it reset", actual)
+	}
+}
@@ -0,0 +1,689 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+const (
+	oomKilledReason      = "OOMKilled"
+	podReconcileInterval = time.Minute
+	// watches lasting less are considered failed and backed off
+	minHealthyWatchDuration = 30 * time.Second
+	maxWatchBackoff         = time.Minute
+	// bound of -watch-jitter-factor, beyond it watches of some replicas would be kept for very long
+	maxWatchJitterFactor = 10
+)
+
+type containerKind int
+
+const (
+	regularContainer containerKind = iota
+	initContainer
+	ephemeralContainer
+)
+
+type WatchEvent struct {
+	Type watch.EventType
+	Pod  *v1.Pod
+	// previous version of the pod for Modified events
+	OldPod *v1.Pod
+}
+
+// runMonitor watches pods until ctx is done or a watch fails permanently.
+func (m *Monitor) runMonitor(parent context.Context) error {
+	state := newMonitorState()
+	if m.opts.StateFile != "" {
+		var err error
+		state, err = loadState(m.opts.StateFile)
+		if err != nil {
+			return fmt.Errorf("unable to load state: %w", err)
+		}
+	}
+
+	ctx, fail := context.WithCancelCause(parent)
+	defer fail(nil)
+
+	// restart_monitor_seconds_since_last_event counts from the start of watching
+	lastEventTime.Store(time.Now().UnixNano())
+	m.restartWorkers = startWorkerPool(m.opts.Workers)
+	// reports of restarts seen so far are still delivered
+	defer m.restartWorkers.close()
+	defer m.cooldowns.stop()
+	defer m.recoveries.stop()
+
+	// last seen restart count of each container, keyed by pod UID and container name
+	pods := state.RestartCounts
+	watchEventCh := m.newWatchEventChannel()
+	// one informer per namespace, each with its own resourceVersion
+	informers := make(map[string]cache.SharedIndexInformer, len(m.watchNamespaces))
+	var wg sync.WaitGroup
+	for _, namespace := range m.watchNamespaces {
+		informer, err := m.newPodInformer(ctx, namespace, state.ResourceVersions[namespace], watchEventCh, fail)
+		if err != nil {
+			return err
+		}
+		informers[namespace] = informer
+		wg.Add(1)
+		go func(namespace string) {
+			defer wg.Done()
+			m.runPodInformer(ctx, namespace, informer)
+		}(namespace)
+	}
+
+	if m.opts.ResyncPeriod > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.resyncPods(ctx, informers, watchEventCh)
+		}()
+	}
+
+	saveState := func() {
+		if m.opts.StateFile == "" {
+			return
+		}
+		for namespace, informer := range informers {
+			if resourceVersion := informer.LastSyncResourceVersion(); resourceVersion != "" {
+				state.ResourceVersions[namespace] = resourceVersion
+			}
+		}
+		if err := state.save(m.opts.StateFile); err != nil {
+			slog.Warn("Unable to save state", "err", err)
+		}
+	}
+
+	saveTicker := time.NewTicker(stateSaveInterval)
+	defer saveTicker.Stop()
+	reconcileTicker := time.NewTicker(podReconcileInterval)
+	defer reconcileTicker.Stop()
+	terminalSince := make(map[types.UID]time.Time)
+
+	for {
+		var watchEvent WatchEvent
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			saveState()
+			if parent.Err() == nil {
+				return context.Cause(ctx)
+			}
+			return nil
+		case <-saveTicker.C:
+			saveState()
+			continue
+		case <-reconcileTicker.C:
+			m.reconcilePods(pods, terminalSince, informers)
+			m.storms.expire(time.Now())
+			continue
+		case summary := <-m.cooldowns.summaries:
+			m.handleCooldownSummary(summary)
+			continue
+		case watchEvent = <-watchEventCh:
+			watchEventChannelDepth.Set(float64(len(watchEventCh)))
+			lastEventTime.Store(time.Now().UnixNano())
+		}
+
+		pod := watchEvent.Pod
+		if watchEvent.Type == watch.Deleted {
+			delete(pods, pod.UID)
+			delete(terminalSince, pod.UID)
+			m.forgetPod(pod.UID)
+		} else if isOlderResourceVersion(pod.ResourceVersion, m.resourceVersions[pod.UID]) {
+			// e.g. listed by a resync from a watch cache lagging behind the watch
+			slog.Debug("Skipping stale pod update", "namespace", pod.Namespace, "pod", pod.Name,
+				"resourceVersion", pod.ResourceVersion, "seenResourceVersion", m.resourceVersions[pod.UID])
+		} else {
+			m.resourceVersions[pod.UID] = pod.ResourceVersion
+			restartCounts, exist := pods[pod.UID]
+			if !exist {
+				restartCounts = make(map[string]int32)
+				pods[pod.UID] = restartCounts
+			}
+			m.handlePodUpdate(ctx, pod, watchEvent.OldPod, restartCounts)
+			if isTerminal(pod) {
+				if _, ok := terminalSince[pod.UID]; !ok {
+					terminalSince[pod.UID] = time.Now()
+				}
+			}
+		}
+		trackedPods.Set(float64(len(pods)))
+		m.health.markAlive()
+	}
+}
+
+// reconcilePods evicts pods missing from the informer caches, e.g. if their deletion was missed,
+// and pods that stayed in a terminal phase for longer than -terminal-pod-grace.
+func (m *Monitor) reconcilePods(pods map[types.UID]map[string]int32, terminalSince map[types.UID]time.Time, informers map[string]cache.SharedIndexInformer) {
+	present := make(map[types.UID]bool, len(pods))
+	for _, informer := range informers {
+		if !informer.HasSynced() {
+			return
+		}
+		for _, obj := range informer.GetStore().List() {
+			if pod, ok := obj.(*v1.Pod); ok {
+				present[pod.UID] = true
+			}
+		}
+	}
+
+	now := time.Now()
+	for uid := range pods {
+		since, terminal := terminalSince[uid]
+		if present[uid] && !(terminal && now.Sub(since) > m.opts.TerminalPodGrace) {
+			continue
+		}
+		delete(pods, uid)
+		delete(terminalSince, uid)
+		m.forgetPod(uid)
+	}
+	for uid := range terminalSince {
+		if !present[uid] {
+			delete(terminalSince, uid)
+		}
+	}
+	trackedPods.Set(float64(len(pods)))
+}
+
+// resyncPods periodically lists all watched pods and sends them to the main loop, so that restarts missed
+// by the watch are still detected. Restarts seen before are not reported again as their counts are tracked.
+// Pods not newer than in the informer cache, e.g. listed from a lagging watch cache, are skipped, the others
+// are sent with the cached version as the old one.
+func (m *Monitor) resyncPods(ctx context.Context, informers map[string]cache.SharedIndexInformer, c chan WatchEvent) {
+	ticker := time.NewTicker(m.opts.ResyncPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for _, namespace := range m.watchNamespaces {
+			options := metav1.ListOptions{
+				LabelSelector: m.labelSelector.String(),
+				FieldSelector: m.fieldSelector.String(),
+			}
+			if m.opts.ListFromCache {
+				options.ResourceVersion = "0"
+			}
+			list, err := m.listPods(ctx, namespace, options)
+			if err != nil {
+				slog.Warn("Resync failed", "namespace", namespaceTitle(namespace), "err", err)
+				continue
+			}
+			slog.Debug("Resyncing pods", "namespace", namespaceTitle(namespace), "count", len(list.Items))
+			store := informers[namespace].GetStore()
+			skipped := 0
+			for i := range list.Items {
+				pod := &list.Items[i]
+				obj, cached, _ := store.Get(pod)
+				if cachedPod, ok := obj.(*v1.Pod); cached && ok && (pod.ResourceVersion == cachedPod.ResourceVersion ||
+					isOlderResourceVersion(pod.ResourceVersion, cachedPod.ResourceVersion)) {
+					skipped++
+					continue
+				}
+				compactPod(pod)
+				sendWatchEvent(ctx, c, watch.Modified, pod, obj)
+			}
+			if skipped > 0 {
+				slog.Debug("Skipped resynced pods not newer than cached", "namespace", namespaceTitle(namespace), "count", skipped)
+			}
+		}
+	}
+}
+
+func (m *Monitor) forgetPod(uid types.UID) {
+	m.cooldowns.forget(uid)
+	m.recoveries.forget(uid)
+	m.imagePullErrors.forget(uid)
+	m.samples.forget(uid)
+	m.readiness.forget(uid)
+	delete(m.resourceVersions, uid)
+	for key := range m.lastRestartTimes {
+		if key.podUID == uid {
+			delete(m.lastRestartTimes, key)
+		}
+	}
+}
+
+func (m *Monitor) forgetContainer(key containerKey) {
+	delete(m.samples.counts, key)
+	delete(m.readiness.ready, key)
+	delete(m.lastRestartTimes, key)
+}
+
+// isOlderResourceVersion reports whether resourceVersion a is older than b. Resource versions are opaque, but
+// integers in practice; others, e.g. the empty one of pods not seen yet, are never considered older.
+func isOlderResourceVersion(a, b string) bool {
+	av, errA := strconv.ParseUint(a, 10, 64)
+	bv, errB := strconv.ParseUint(b, 10, 64)
+	return errA == nil && errB == nil && av < bv
+}
+
+func isTerminal(pod *v1.Pod) bool {
+	return pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed
+}
+
+// withAPITimeout bounds a single api call. Watches are bounded by their TimeoutSeconds instead.
+func (m *Monitor) withAPITimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, m.opts.APITimeout)
+}
+
+func (m *Monitor) listPods(ctx context.Context, namespace string, options metav1.ListOptions) (*v1.PodList, error) {
+	ctx, cancel := m.withAPITimeout(ctx)
+	defer cancel()
+	return m.client.CoreV1().Pods(namespace).List(ctx, options)
+}
+
+// isExpired reports whether err means that the resourceVersion is too old and a relist is needed.
+// Besides Expired and Gone reasons, apiservers and proxies may return a bare 410 status.
+func isExpired(err error) bool {
+	if apierrs.IsResourceExpired(err) || apierrs.IsGone(err) {
+		return true
+	}
+	var status apierrs.APIStatus
+	if errors.As(err, &status) && status.Status().Code == http.StatusGone {
+		return true
+	}
+	return err != nil && strings.Contains(err.Error(), "too old resource version")
+}
+
+// watchTimeout returns the watch timeout for a random r in [0, 1): between -min-watch-timeout
+// and -watch-jitter-factor times more.
+func (m *Monitor) watchTimeout(r float64) time.Duration {
+	return time.Duration(float64(m.opts.MinWatchTimeout) * (1 + r*m.opts.WatchJitterFactor))
+}
+
+func newWatchBackoff() wait.Backoff {
+	return wait.Backoff{
+		Duration: time.Second,
+		Factor:   2,
+		Jitter:   0.5,
+		Steps:    math.MaxInt32,
+		Cap:      maxWatchBackoff,
+	}
+}
+
+func splitList(value string, def ...string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	if len(items) == 0 {
+		return def
+	}
+	return items
+}
+
+// newPodInformer creates informer of pods in the namespace. If resumeResourceVersion is set, the initial list
+// is not older than it, so restart counts restored from it never go back.
+// fail is called with errors after which watching makes no sense.
+func (m *Monitor) newPodInformer(ctx context.Context, namespace string, resumeResourceVersion string, c chan WatchEvent, fail func(error)) (cache.SharedIndexInformer, error) {
+	backoff := newWatchBackoff()
+	var lastWatchStart time.Time
+	listWatch := &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			options.LabelSelector = m.labelSelector.String()
+			options.FieldSelector = m.fieldSelector.String()
+			if resumeResourceVersion != "" && options.ResourceVersion == "0" {
+				options.ResourceVersion = resumeResourceVersion
+				resumeResourceVersion = ""
+			}
+			// the reflector lists with a non-empty resourceVersion ("0" initially), allowing
+			// the apiserver to serve the list from its watch cache
+			if !m.opts.ListFromCache {
+				options.ResourceVersion = ""
+			}
+
+			// falls back to a full list also if resumed resourceVersion is too old
+			list, err := m.listPods(ctx, namespace, options)
+			if err != nil && options.ResourceVersion != "" && ctx.Err() == nil {
+				slog.Warn("List failed, falling back to full list", "namespace", namespaceTitle(namespace), "resourceVersion", options.ResourceVersion, "err", err)
+				options.ResourceVersion = ""
+				list, err = m.listPods(ctx, namespace, options)
+			}
+			if err != nil {
+				return nil, err
+			}
+			for i := range list.Items {
+				compactPod(&list.Items[i])
+			}
+			return list, nil
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			// the reflector backs off only on errors, so also delay re-watching if the apiserver keeps
+			// closing watches early, resetting once a watch lasted for long enough
+			if time.Since(lastWatchStart) < minHealthyWatchDuration {
+				delay := backoff.Step()
+				slog.Debug("Watch closed early, delaying reconnect", "namespace", namespaceTitle(namespace), "delay", delay)
+				select {
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				case <-time.After(delay):
+				}
+			} else {
+				backoff = newWatchBackoff()
+			}
+			if !lastWatchStart.IsZero() {
+				watchReconnectsTotal.Inc()
+			}
+			lastWatchStart = time.Now()
+
+			slog.Debug("Watching pods", "namespace", namespaceTitle(namespace), "resourceVersion", options.ResourceVersion)
+
+			timeoutSeconds := int64(m.watchTimeout(rand.Float64()).Seconds())
+			options.LabelSelector = m.labelSelector.String()
+			options.FieldSelector = m.fieldSelector.String()
+			options.TimeoutSeconds = &timeoutSeconds
+			watcher, err := m.client.CoreV1().Pods(namespace).Watch(ctx, options)
+			if err != nil {
+				return nil, err
+			}
+			m.health.markAlive()
+			return watch.Filter(watcher, func(event watch.Event) (watch.Event, bool) {
+				if pod, ok := event.Object.(*v1.Pod); ok {
+					compactPod(pod)
+				} else if event.Type == watch.Error && isExpired(apierrs.FromObject(event.Object)) {
+					watchExpiredTotal.Inc()
+				}
+				return event, true
+			}), nil
+		},
+	}
+
+	informer := cache.NewSharedIndexInformer(listWatch, &v1.Pod{}, 0, cache.Indexers{})
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			sendWatchEvent(ctx, c, watch.Added, obj, nil)
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			sendWatchEvent(ctx, c, watch.Modified, newObj, oldObj)
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			sendWatchEvent(ctx, c, watch.Deleted, obj, nil)
+		},
+	})
+
+	// the reflector retries failed list and watch calls with capped, jittered exponential backoff,
+	// relisting from scratch if the resourceVersion expired, so only auth errors stop the monitor
+	err := informer.SetWatchErrorHandler(func(r *cache.Reflector, err error) {
+		switch {
+		case err == io.EOF:
+			// watch closed normally
+		case apierrs.IsUnauthorized(err):
+			fail(fmt.Errorf("unauthorized to watch pods in %s: %w", namespaceTitle(namespace), err))
+		case isExpired(err):
+			watchExpiredTotal.Inc()
+			slog.Info("Resource version expired, relisting", "namespace", namespaceTitle(namespace), "err", err)
+		default:
+			slog.Warn("Watch failed, retrying with backoff", "namespace", namespaceTitle(namespace), "err", err)
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to set watch error handler: %w", err)
+	}
+
+	return informer, nil
+}
+
+func (m *Monitor) runPodInformer(ctx context.Context, namespace string, informer cache.SharedIndexInformer) {
+	go func() {
+		if cache.WaitForCacheSync(ctx.Done(), informer.HasSynced) {
+			m.health.markWatcherReady(namespace)
+		}
+	}()
+
+	informer.Run(ctx.Done())
+}
+
+func namespaceTitle(namespace string) string {
+	if namespace == v1.NamespaceAll {
+		return "[all namespaces]"
+	}
+	return "[" + namespace + "]"
+}
+
+// newWatchEventChannel returns the channel of the watch events of all informers, buffering -channel-buffer events.
+func (m *Monitor) newWatchEventChannel() chan WatchEvent {
+	return make(chan WatchEvent, m.opts.ChannelBuffer)
+}
+
+func sendWatchEvent(ctx context.Context, c chan WatchEvent, eventType watch.EventType, obj, oldObj interface{}) {
+	pod, ok := obj.(*v1.Pod)
+	if !ok {
+		slog.Warn("Unexpected object type", "type", fmt.Sprintf("%T", obj))
+		return
+	}
+	oldPod, _ := oldObj.(*v1.Pod)
+	watchEvent := WatchEvent{Type: eventType, Pod: pod, OldPod: oldPod}
+
+	select {
+	case c <- watchEvent:
+	default:
+		// the processing falls behind, which also delays the watch
+		watchEventChannelBlockedTotal.Inc()
+		select {
+		case c <- watchEvent:
+		case <-ctx.Done():
+			return
+		}
+	}
+	watchEventChannelDepth.Set(float64(len(c)))
+}
+
+// handlePodUpdate compares container restart counts with the last seen ones,
+// so pods re-sent after a relist are neither reported twice nor missed.
+// Containers seen for the first time only establish a baseline.
+// Restart counts of pods that are not monitored are still tracked, so no stale restarts
+// are reported if the pod becomes monitored later. oldPod is the previous version of the pod, if known.
+func (m *Monitor) handlePodUpdate(ctx context.Context, pod, oldPod *v1.Pod, restartCounts map[string]int32) {
+	ctx, span := startPodSpan(ctx, "handlePodUpdate", pod)
+	defer span.End()
+
+	monitored := m.isMonitored(pod)
+	m.handleContainersUpdate(ctx, pod, oldPod, regularContainer, pod.Status.ContainerStatuses, restartCounts, monitored)
+	m.handleContainersUpdate(ctx, pod, oldPod, initContainer, pod.Status.InitContainerStatuses, restartCounts, monitored)
+	// empty on clusters without ephemeral containers support
+	m.handleContainersUpdate(ctx, pod, oldPod, ephemeralContainer, pod.Status.EphemeralContainerStatuses, restartCounts, monitored)
+}
+
+func (m *Monitor) handleContainersUpdate(ctx context.Context, pod, oldPod *v1.Pod, kind containerKind, containerStatuses []v1.ContainerStatus, restartCounts map[string]int32, monitored bool) {
+	for i := range containerStatuses {
+		containerStatus := &containerStatuses[i]
+		prevRestartCount, ok := restartCounts[containerStatus.Name]
+		restartCounts[containerStatus.Name] = containerStatus.RestartCount
+		if ok && prevRestartCount != containerStatus.RestartCount {
+			slog.Debug("Restart count changed", "namespace", pod.Namespace, "pod", pod.Name, "container", containerStatus.Name,
+				"from", prevRestartCount, "to", containerStatus.RestartCount, "monitored", monitored, "state", containerStateName(containerStatus.State))
+		}
+		if ok && containerStatus.RestartCount < prevRestartCount {
+			// stale updates are skipped by the main loop, so the container really was replaced, e.g. its status
+			// was reset: the new count is the baseline and the history of the old container does not apply
+			m.forgetContainer(containerKey{pod.UID, containerStatus.Name})
+		}
+		if !monitored || !m.isContainerMonitored(containerStatus.Name) {
+			continue
+		}
+		if m.opts.WatchImagePullErrors && m.imagePullErrors.check(pod, containerStatus) && !m.muteSchedule.muted(time.Now()) {
+			m.restartWorkers.submit(pod.UID, func() {
+				m.reportImagePullError(pod, containerStatus)
+			})
+		}
+		if !ok {
+			continue
+		}
+		delta := containerStatus.RestartCount - prevRestartCount
+		neverReady := m.readiness.update(pod, containerStatus, delta > 0)
+		if delta > 0 {
+			// cooldown starts only when a notification passes these checks
+			notify := (!m.opts.CrashLoopOnly || isCrashLoopBackOff(containerStatus)) &&
+				containerStatus.RestartCount >= int32(m.opts.MinRestartCount) &&
+				!m.inStartupGrace(pod, containerStatus) &&
+				!imageChanged(oldPod, containerStatus)
+			m.handleContainerRestart(ctx, pod, kind, containerStatus, delta, notify, neverReady)
+		}
+		m.recoveries.update(pod, containerStatus)
+	}
+}
+
+// imageChanged reports whether the container was restarted with a new image, e.g. by an in-place update,
+// rather than after a crash.
+func imageChanged(oldPod *v1.Pod, containerStatus *v1.ContainerStatus) bool {
+	if oldPod == nil {
+		return false
+	}
+	oldImage := findContainerStatus(oldPod, containerStatus.Name).Image
+	return oldImage != "" && oldImage != containerStatus.Image
+}
+
+func containerStateName(state v1.ContainerState) string {
+	switch {
+	case state.Running != nil:
+		return "running"
+	case state.Waiting != nil:
+		return "waiting: " + state.Waiting.Reason
+	case state.Terminated != nil:
+		return "terminated: " + state.Terminated.Reason
+	}
+	return "unknown"
+}
+
+// observeRestartInterval records the time since the previous seen restart of the container,
+// spread evenly over delta restarts.
+func (m *Monitor) observeRestartInterval(pod *v1.Pod, containerStatus *v1.ContainerStatus, delta int32) {
+	restartTime := time.Now()
+	if t := containerStatus.LastTerminationState.Terminated; t != nil && !t.FinishedAt.IsZero() {
+		restartTime = t.FinishedAt.Time
+	}
+
+	key := containerKey{pod.UID, containerStatus.Name}
+	prev, ok := m.lastRestartTimes[key]
+	m.lastRestartTimes[key] = restartTime
+	if !ok || !restartTime.After(prev) {
+		return
+	}
+	interval := restartTime.Sub(prev).Seconds() / float64(delta)
+	for i := int32(0); i < delta; i++ {
+		restartIntervalSeconds.WithLabelValues(pod.Namespace).Observe(interval)
+	}
+}
+
+func isCrashLoopBackOff(containerStatus *v1.ContainerStatus) bool {
+	waiting := containerStatus.State.Waiting
+	return waiting != nil && waiting.Reason == "CrashLoopBackOff"
+}
+
+// handleContainerRestart records delta restarts (at least 1) in metrics and, if notify is set,
+// schedules the event and notifications.
+func (m *Monitor) handleContainerRestart(ctx context.Context, pod *v1.Pod, kind containerKind, containerStatus *v1.ContainerStatus, delta int32, notify, neverReady bool) {
+	ctx, span := startPodSpan(ctx, "handleContainerRestart", pod,
+		attribute.String("k8s.container.name", containerStatus.Name),
+		attribute.Int("restarts", int(delta)),
+		attribute.Bool("notify", notify))
+	defer span.End()
+
+	// kubelet may not have populated the last termination state yet
+	terminationReason, exitCode := "", "unknown"
+	if terminated := containerStatus.LastTerminationState.Terminated; terminated != nil {
+		if m.ignoreExitCodes[terminated.ExitCode] {
+			return
+		}
+		terminationReason = terminated.Reason
+		exitCode = exitCodeLabel(terminated.ExitCode)
+	}
+	oomKilled := terminationReason == oomKilledReason
+	containerRestartsTotal.WithLabelValues(pod.Namespace, pod.Name, containerStatus.Name, terminationReason, strconv.FormatBool(oomKilled)).Add(float64(delta))
+	containerRestartsByCodeTotal.WithLabelValues(pod.Namespace, terminationReason, exitCode).Add(float64(delta))
+	m.observeRestartInterval(pod, containerStatus, delta)
+
+	reason := m.opts.EventReason
+	switch kind {
+	case initContainer:
+		reason = m.opts.InitEventReason
+	case ephemeralContainer:
+		reason = m.opts.EphemeralEventReason
+	}
+	if oomKilled {
+		reason = m.opts.OOMEventReason
+	}
+
+	if notify && m.muteSchedule.muted(time.Now()) {
+		slog.Debug("Restart muted by -mute-schedule", "namespace", pod.Namespace, "pod", pod.Name, "container", containerStatus.Name)
+		return
+	}
+	if !notify || !m.samples.allow(containerKey{pod.UID, containerStatus.Name}, delta) || !m.cooldowns.allow(pod, containerStatus, delta, reason) {
+		return
+	}
+	if !m.namespaceLimits.allow(pod.Namespace) {
+		slog.Debug("Restart dropped by -namespace-rate-limit", "namespace", pod.Namespace, "pod", pod.Name, "container", containerStatus.Name)
+		return
+	}
+	// only restarts that would be reported count towards a storm
+	if m.storms.record(pod.Namespace, delta, time.Now()) {
+		slog.Debug("Restart suppressed by restart storm", "namespace", pod.Namespace, "pod", pod.Name, "container", containerStatus.Name)
+		return
+	}
+
+	// tracked here rather than by the worker, which may run after the main loop handled the container getting ready
+	m.recoveries.track(pod, containerStatus)
+	// reported also when ctx is done meanwhile, as the restart was seen
+	ctx = context.WithoutCancel(ctx)
+	m.restartWorkers.submit(pod.UID, func() {
+		m.reportRestart(ctx, pod, containerStatus, delta, reason, neverReady)
+	})
+}
+
+// reportRestart formats the restart message and notifies the sinks. It runs in restartWorkers,
+// as it may call the api server.
+func (m *Monitor) reportRestart(ctx context.Context, pod *v1.Pod, containerStatus *v1.ContainerStatus, delta int32, reason string, neverReady bool) {
+	ctx, span := startPodSpan(ctx, "reportRestart", pod,
+		attribute.String("k8s.container.name", containerStatus.Name),
+		attribute.String("reason", reason))
+	defer span.End()
+
+	msg := m.formatMessage(pod, containerStatus)
+	logRestart(msg, pod, containerStatus)
+
+	if m.opts.IncludeLogs {
+		logs, err := m.fetchPreviousLogs(ctx, pod, containerStatus.Name)
+		if err != nil {
+			slog.Warn("Unable to fetch logs", "namespace", pod.Namespace, "pod", pod.Name, "container", containerStatus.Name, "err", err)
+		} else if logs != "" {
+			msg += "\nLast logs:\n" + logs
+		}
+	}
+
+	info := m.newRestartInfo(pod, containerStatus, delta, reason)
+	info.Message = msg
+	info.NeverReady = neverReady
+	m.sinks.dispatch(info)
+}
@@ -0,0 +1,1553 @@
+package monitor_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/smpio/kube-restart-monitor/monitor"
+	"github.com/smpio/kube-restart-monitor/monitor/monitortest"
+)
+
+// noEventsTimeout is how long tests wait for events which must not be created.
+const noEventsTimeout = 300 * time.Millisecond
+
+func TestRestartDetected(t *testing.T) {
+	h := monitortest.NewHarness(t, monitortest.NewPod("default", "web", monitortest.Container("app", 0)))
+	h.Start(monitor.DefaultOptions())
+
+	h.Modify(monitortest.NewPod("default", "web", monitortest.Crashed(monitortest.Container("app", 1), 1)))
+	events := h.WaitForEvents(1, 5*time.Second)
+	if len(events) != 1 {
+		t.Fatalf("%d events, want 1", len(events))
+	}
+	event := events[0]
+	if event.Reason != "ContainerRestart" || event.Type != "Warning" {
+		t.Errorf("event reason %s, type %s, want ContainerRestart, Warning", event.Reason, event.Type)
+	}
+	if event.InvolvedObject.Kind != "Pod" || event.InvolvedObject.Namespace != "default" || event.InvolvedObject.Name != "web" {
+		t.Errorf("event involved object %+v, want pod default/web", event.InvolvedObject)
+	}
+}
+
+func TestNoRestart(t *testing.T) {
+	h := monitortest.NewHarness(t, monitortest.NewPod("default", "web", monitortest.Container("app", 2)))
+	h.Start(monitor.DefaultOptions())
+
+	// the restart count of the initial list is the baseline
+	notReady := monitortest.Container("app", 2)
+	notReady.Ready = false
+	h.Modify(monitortest.NewPod("default", "web", notReady))
+	if events := h.WaitForEvents(0, noEventsTimeout); len(events) != 0 {
+		t.Errorf("%d events without restarts, want 0", len(events))
+	}
+}
+
+func TestInitContainerRestart(t *testing.T) {
+	h := monitortest.NewHarness(t, monitortest.InitContainers(
+		monitortest.NewPod("default", "web", monitortest.Container("app", 0)),
+		monitortest.Container("migrate", 0),
+	))
+	h.Start(monitor.DefaultOptions())
+
+	h.Modify(monitortest.InitContainers(
+		monitortest.NewPod("default", "web", monitortest.Container("app", 0)),
+		monitortest.Crashed(monitortest.Container("migrate", 1), 1),
+	))
+	events := h.WaitForEvents(1, 5*time.Second)
+	if len(events) != 1 {
+		t.Fatalf("%d events, want 1", len(events))
+	}
+	if events[0].Reason != "InitContainerRestart" {
+		t.Errorf("event reason %s, want InitContainerRestart", events[0].Reason)
+	}
+}
+
+// kubelet may report the new restart count before the last termination state
+func TestRestartWithoutTerminationState(t *testing.T) {
+	h := monitortest.NewHarness(t, monitortest.NewPod("default", "web", monitortest.Container("app", 0)))
+	h.Start(monitor.DefaultOptions())
+
+	h.Modify(monitortest.NewPod("default", "web", monitortest.Container("app", 1)))
+	events := h.WaitForEvents(1, 5*time.Second)
+	if len(events) != 1 {
+		t.Fatalf("%d events, want 1", len(events))
+	}
+	if events[0].Reason != "ContainerRestart" {
+		t.Errorf("event reason %s, want ContainerRestart", events[0].Reason)
+	}
+}
+
+func TestRestartOfOneOfManyContainers(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		restarts map[string]int32
+	}{
+		{name: "first", restarts: map[string]int32{"a": 1}},
+		{name: "last", restarts: map[string]int32{"c": 2}},
+		{name: "first and last", restarts: map[string]int32{"a": 1, "c": 1}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			containers := func(restarts map[string]int32) []v1.ContainerStatus {
+				var statuses []v1.ContainerStatus
+				for _, name := range []string{"a", "b", "c"} {
+					statuses = append(statuses, monitortest.Container(name, restarts[name]))
+				}
+				return statuses
+			}
+			h := monitortest.NewHarness(t, monitortest.NewPod("default", "web", containers(nil)...))
+			h.Start(monitor.DefaultOptions())
+
+			h.Modify(monitortest.NewPod("default", "web", containers(tc.restarts)...))
+			events := h.WaitForEvents(len(tc.restarts), 5*time.Second)
+			if len(events) != len(tc.restarts) {
+				t.Fatalf("%d events, want %d", len(events), len(tc.restarts))
+			}
+			for _, event := range events {
+				var container string
+				fmt.Sscanf(event.Message, "Container %s in pod", &container)
+				if restarts, ok := tc.restarts[container]; !ok || event.Count != restarts {
+					t.Errorf("event %q with count %d, want restarts of %v", event.Message, event.Count, tc.restarts)
+				}
+				delete(tc.restarts, container)
+			}
+		})
+	}
+}
+
+func TestInitialListOfManyPods(t *testing.T) {
+	names := []string{"web-0", "web-1", "web-2"}
+	var pods []*v1.Pod
+	for _, name := range names {
+		pods = append(pods, monitortest.NewPod("default", name, monitortest.Container("app", 1)))
+	}
+	h := monitortest.NewHarness(t, pods...)
+	h.Start(monitor.DefaultOptions())
+
+	for _, name := range names {
+		h.Modify(monitortest.NewPod("default", name, monitortest.Container("app", 2)))
+	}
+	events := h.WaitForEvents(len(names), 5*time.Second)
+	if len(events) != len(names) {
+		t.Fatalf("%d events, want %d", len(events), len(names))
+	}
+	seen := map[string]bool{}
+	for _, event := range events {
+		seen[event.InvolvedObject.Name] = true
+	}
+	for _, name := range names {
+		if !seen[name] {
+			t.Errorf("no event of pod %s, got events of %v", name, seen)
+		}
+	}
+}
+
+func TestRelistAfterDisconnect(t *testing.T) {
+	h := monitortest.NewHarness(t,
+		monitortest.NewPod("default", "web", monitortest.Container("app", 0)),
+		monitortest.NewPod("default", "worker", monitortest.Container("app", 0)),
+	)
+	h.Start(monitor.DefaultOptions())
+
+	h.Modify(monitortest.NewPod("default", "web", monitortest.Container("app", 1)))
+	if events := h.WaitForEvents(1, 5*time.Second); len(events) != 1 {
+		t.Fatalf("%d events before disconnect, want 1", len(events))
+	}
+
+	// the restart of web is listed again, the worker restarted while disconnected
+	h.SetListed(monitortest.NewPod("default", "web", monitortest.Container("app", 1)))
+	h.SetListed(monitortest.NewPod("default", "worker", monitortest.Container("app", 1)))
+	h.ExpireWatch()
+
+	h.WaitForEvents(2, 10*time.Second)
+	// no more events follow
+	time.Sleep(noEventsTimeout)
+	events := h.Events()
+	if len(events) != 2 {
+		t.Fatalf("%d events after relist, want 2", len(events))
+	}
+	if name := events[1].InvolvedObject.Name; name != "worker" {
+		t.Errorf("event after relist of pod %s, want worker", name)
+	}
+}
+
+func TestRunReturnsWhenCancelled(t *testing.T) {
+	h := monitortest.NewHarness(t, monitortest.NewPod("default", "web", monitortest.Container("app", 0)))
+	m, err := monitor.New(h.Client, monitor.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- m.Run(ctx)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Run returned %v, want nil", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after cancel")
+	}
+}
+
+func TestDeletedPodIsForgotten(t *testing.T) {
+	h := monitortest.NewHarness(t, monitortest.NewPod("default", "web", monitortest.Container("app", 0)))
+	h.Start(monitor.DefaultOptions())
+
+	h.Delete(monitortest.NewPod("default", "web", monitortest.Container("app", 0)))
+	// a pod seen for the first time only establishes the baseline
+	h.Modify(monitortest.NewPod("default", "web", monitortest.Container("app", 3)))
+	if events := h.WaitForEvents(0, noEventsTimeout); len(events) != 0 {
+		t.Fatalf("%d events of a pod seen again after deletion, want 0", len(events))
+	}
+
+	h.Modify(monitortest.NewPod("default", "web", monitortest.Container("app", 4)))
+	if events := h.WaitForEvents(1, 5*time.Second); len(events) != 1 {
+		t.Errorf("%d events, want 1", len(events))
+	}
+}
+
+func TestWatchRetriedAfterTransientErrors(t *testing.T) {
+	h := monitortest.NewHarness(t, monitortest.NewPod("default", "web", monitortest.Container("app", 0)))
+	failures := 1
+	h.Client.PrependWatchReactor("pods", func(action k8stesting.Action) (bool, watch.Interface, error) {
+		if failures > 0 {
+			failures--
+			return true, nil, apierrs.NewInternalError(errors.New("etcd leader changed"))
+		}
+		return false, nil, nil
+	})
+	h.Start(monitor.DefaultOptions())
+
+	h.Modify(monitortest.NewPod("default", "web", monitortest.Container("app", 1)))
+	if events := h.WaitForEvents(1, 15*time.Second); len(events) != 1 {
+		t.Fatalf("%d events after watch errors, want 1", len(events))
+	}
+}
+
+func listEvents(t *testing.T, h *monitortest.Harness) []v1.Event {
+	t.Helper()
+	events, err := h.Client.CoreV1().Events("").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return events.Items
+}
+
+func TestRepeatedRestartsAggregated(t *testing.T) {
+	h := monitortest.NewHarness(t, monitortest.NewPod("default", "web", monitortest.Container("app", 0)))
+	opts := monitor.DefaultOptions()
+	opts.Cooldown = 0
+	h.Start(opts)
+
+	h.Modify(monitortest.NewPod("default", "web", monitortest.Container("app", 1)))
+	h.WaitForEvents(1, 5*time.Second)
+	h.Modify(monitortest.NewPod("default", "web", monitortest.Container("app", 2)))
+
+	events := waitForEventCount(t, h, 2)
+	if len(events) != 1 {
+		t.Fatalf("%d events, want 1", len(events))
+	}
+	if events[0].Count != 2 {
+		t.Errorf("event count %d, want 2", events[0].Count)
+	}
+}
+
+// waitForEventCount waits until the counts of the events add up to count, as events are created and then
+// patched with occurrences. It returns the events.
+func waitForEventCount(t *testing.T, h *monitortest.Harness, count int32) []v1.Event {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		events := listEvents(t, h)
+		total := int32(0)
+		for _, event := range events {
+			total += event.Count
+		}
+		if total >= count || time.Now().After(deadline) {
+			return events
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestCleanExitIgnored(t *testing.T) {
+	h := monitortest.NewHarness(t, monitortest.NewPod("default", "web", monitortest.Container("clean", 0), monitortest.Container("failed", 0)))
+	h.Start(monitor.DefaultOptions())
+
+	h.Modify(monitortest.NewPod("default", "web",
+		monitortest.Crashed(monitortest.Container("clean", 1), 0),
+		monitortest.Crashed(monitortest.Container("failed", 1), 1),
+	))
+	h.WaitForEvents(1, 5*time.Second)
+	time.Sleep(noEventsTimeout)
+	events := h.Events()
+	if len(events) != 1 {
+		t.Fatalf("%d events, want 1", len(events))
+	}
+	if !strings.HasPrefix(events[0].Message, "Container failed ") {
+		t.Errorf("event %q, want the restart of failed", events[0].Message)
+	}
+}
+
+func TestCrashLoopOnly(t *testing.T) {
+	h := monitortest.NewHarness(t, monitortest.NewPod("default", "web", monitortest.Container("running", 0), monitortest.Container("looping", 0)))
+	opts := monitor.DefaultOptions()
+	opts.CrashLoopOnly = true
+	h.Start(opts)
+
+	h.Modify(monitortest.NewPod("default", "web",
+		monitortest.Crashed(monitortest.Container("running", 1), 1),
+		monitortest.CrashLoopBackOff(monitortest.Crashed(monitortest.Container("looping", 1), 1)),
+	))
+	h.WaitForEvents(1, 5*time.Second)
+	time.Sleep(noEventsTimeout)
+	events := h.Events()
+	if len(events) != 1 {
+		t.Fatalf("%d events, want 1", len(events))
+	}
+	if !strings.HasPrefix(events[0].Message, "Container looping ") {
+		t.Errorf("event %q, want the restart of looping", events[0].Message)
+	}
+}
+
+func TestOOMKilled(t *testing.T) {
+	h := monitortest.NewHarness(t, monitortest.NewPod("default", "web", monitortest.Container("app", 0)))
+	h.Start(monitor.DefaultOptions())
+
+	pod := monitortest.NewPod("default", "web", monitortest.OOMKilled(monitortest.Container("app", 1)))
+	pod.Spec.Containers[0].Resources.Limits = v1.ResourceList{v1.ResourceMemory: resource.MustParse("256Mi")}
+	h.Modify(pod)
+	events := h.WaitForEvents(1, 5*time.Second)
+	if len(events) != 1 {
+		t.Fatalf("%d events, want 1", len(events))
+	}
+	event := events[0]
+	if event.Reason != "ContainerOOMKilled" {
+		t.Errorf("event reason %s, want ContainerOOMKilled", event.Reason)
+	}
+	for _, expected := range []string{"Reason: OOMKilled, exit code: 137 (SIGKILL).", "Memory limit: 256Mi, request: not set."} {
+		if !strings.Contains(event.Message, expected) {
+			t.Errorf("event message %q does not contain %q", event.Message, expected)
+		}
+	}
+}
+
+func TestIncludeLogs(t *testing.T) {
+	h := monitortest.NewHarness(t, monitortest.NewPod("default", "web", monitortest.Container("app", 0)))
+	opts := monitor.DefaultOptions()
+	opts.IncludeLogs = true
+	h.Start(opts)
+
+	h.Modify(monitortest.NewPod("default", "web", monitortest.Crashed(monitortest.Container("app", 1), 1)))
+	events := h.WaitForEvents(1, 5*time.Second)
+	if len(events) != 1 {
+		t.Fatalf("%d events, want 1", len(events))
+	}
+	// the canned logs of the fake clientset
+	if !strings.HasSuffix(events[0].Message, "\nLast logs:\nfake logs") {
+		t.Errorf("event message %q does not end with the logs", events[0].Message)
+	}
+}
+
+func TestCooldownSummary(t *testing.T) {
+	h := monitortest.NewHarness(t, monitortest.NewPod("default", "web", monitortest.Container("app", 0)))
+	opts := monitor.DefaultOptions()
+	opts.Cooldown = 500 * time.Millisecond
+	h.Start(opts)
+
+	h.Modify(monitortest.NewPod("default", "web", monitortest.Container("app", 1)))
+	h.WaitForEvents(1, 5*time.Second)
+	h.Modify(monitortest.NewPod("default", "web", monitortest.Container("app", 3)))
+	h.Modify(monitortest.NewPod("default", "web", monitortest.Container("app", 6)))
+
+	events := h.WaitForEvents(2, 5*time.Second)
+	if len(events) != 2 {
+		t.Fatalf("%d events, want the restart and the summary", len(events))
+	}
+	summary := events[1]
+	if summary.Count != 5 || !strings.Contains(summary.Message, "restarted 5 more times during 500ms cooldown") {
+		t.Errorf("summary event %q with count %d, want 5 suppressed restarts", summary.Message, summary.Count)
+	}
+}
+
+func TestEventCountIsRestartDelta(t *testing.T) {
+	h := monitortest.NewHarness(t, monitortest.NewPod("default", "web", monitortest.Container("app", 2)))
+	h.Start(monitor.DefaultOptions())
+
+	h.Modify(monitortest.NewPod("default", "web", monitortest.Crashed(monitortest.Container("app", 5), 1)))
+	events := h.WaitForEvents(1, 5*time.Second)
+	if len(events) != 1 {
+		t.Fatalf("%d events, want 1", len(events))
+	}
+	if events[0].Count != 3 {
+		t.Errorf("event count %d, want 3", events[0].Count)
+	}
+}
+
+func TestRestartAfterRestartCountReset(t *testing.T) {
+	h := monitortest.NewHarness(t, monitortest.NewPod("default", "web", monitortest.Container("app", 3)))
+	h.Start(monitor.DefaultOptions())
+
+	h.Modify(monitortest.NewPod("default", "web", monitortest.Container("app", 0)))
+	if events := h.WaitForEvents(0, noEventsTimeout); len(events) != 0 {
+		t.Fatalf("%d events after the reset, want 0", len(events))
+	}
+	h.Modify(monitortest.NewPod("default", "web", monitortest.Crashed(monitortest.Container("app", 1), 1)))
+	events := h.WaitForEvents(1, 5*time.Second)
+	if len(events) != 1 {
+		t.Fatalf("%d events, want 1", len(events))
+	}
+	if events[0].Count != 1 {
+		t.Errorf("event count %d, want 1", events[0].Count)
+	}
+}
+
+// reportedRestarts restarts every container of the pods once with exit code 1 and returns the restarts that
+// created events, as namespace/pod/container, sorted.
+func reportedRestarts(t *testing.T, opts monitor.Options, pods ...*v1.Pod) []string {
+	t.Helper()
+	h := monitortest.NewHarness(t, pods...)
+	h.Start(opts)
+
+	for _, pod := range pods {
+		restarted := pod.DeepCopy()
+		for i, containerStatus := range restarted.Status.ContainerStatuses {
+			containerStatus.RestartCount++
+			restarted.Status.ContainerStatuses[i] = monitortest.Crashed(containerStatus, 1)
+		}
+		for i, containerStatus := range restarted.Status.InitContainerStatuses {
+			containerStatus.RestartCount++
+			restarted.Status.InitContainerStatuses[i] = monitortest.Crashed(containerStatus, 1)
+		}
+		h.Modify(restarted)
+	}
+
+	// until no more events are created
+	count := -1
+	for count != len(h.Events()) {
+		count = len(h.Events())
+		time.Sleep(noEventsTimeout)
+	}
+
+	var restarts []string
+	for _, event := range h.Events() {
+		var container string
+		fmt.Sscanf(event.Message, "Container %s in pod", &container)
+		restarts = append(restarts, event.Namespace+"/"+event.InvolvedObject.Name+"/"+container)
+	}
+	sort.Strings(restarts)
+	return restarts
+}
+
+func annotated(pod *v1.Pod, key, value string) *v1.Pod {
+	pod.Annotations = map[string]string{key: value}
+	return pod
+}
+
+func TestIgnoreAnnotation(t *testing.T) {
+	restarts := reportedRestarts(t, monitor.DefaultOptions(),
+		monitortest.NewPod("default", "web", monitortest.Container("app", 0)),
+		annotated(monitortest.NewPod("default", "chaos", monitortest.Container("app", 0)), "restart-monitor.smpio/ignore", "true"),
+	)
+	if expected := []string{"default/web/app"}; !reflect.DeepEqual(restarts, expected) {
+		t.Errorf("reported restarts %v, want %v", restarts, expected)
+	}
+}
+
+func TestOptIn(t *testing.T) {
+	pods := func() []*v1.Pod {
+		return []*v1.Pod{
+			monitortest.NewPod("default", "web", monitortest.Container("app", 0)),
+			annotated(monitortest.NewPod("default", "api", monitortest.Container("app", 0)), "restart-monitor.smpio/enabled", "true"),
+		}
+	}
+	for _, tc := range []struct {
+		optIn    bool
+		expected []string
+	}{
+		{optIn: false, expected: []string{"default/api/app", "default/web/app"}},
+		{optIn: true, expected: []string{"default/api/app"}},
+	} {
+		opts := monitor.DefaultOptions()
+		opts.OptIn = tc.optIn
+		if restarts := reportedRestarts(t, opts, pods()...); !reflect.DeepEqual(restarts, tc.expected) {
+			t.Errorf("opt-in %v: reported restarts %v, want %v", tc.optIn, restarts, tc.expected)
+		}
+	}
+}
+
+func TestStartupGrace(t *testing.T) {
+	fresh := monitortest.NewPod("default", "fresh", monitortest.Container("app", 0))
+	fresh.CreationTimestamp = metav1.NewTime(time.Now().Add(-2 * time.Minute))
+	opts := monitor.DefaultOptions()
+	opts.StartupGrace = 10 * time.Minute
+
+	// restarted a minute ago, after 1 minute and 59 minutes since the pod start
+	restarts := reportedRestarts(t, opts, fresh, monitortest.NewPod("default", "old", monitortest.Container("app", 0)))
+	if expected := []string{"default/old/app"}; !reflect.DeepEqual(restarts, expected) {
+		t.Errorf("reported restarts %v, want %v", restarts, expected)
+	}
+}
+
+// eventReasons returns the reasons of the events by the name of the restarted container.
+func eventReasons(events []*v1.Event) map[string]string {
+	reasons := map[string]string{}
+	for _, event := range events {
+		var container string
+		fmt.Sscanf(event.Message, "Container %s in pod", &container)
+		reasons[container] = event.Reason
+	}
+	return reasons
+}
+
+func TestInitAndRegularContainerReasons(t *testing.T) {
+	h := monitortest.NewHarness(t, monitortest.InitContainers(
+		monitortest.NewPod("default", "web", monitortest.Container("app", 0)),
+		monitortest.Container("migrate", 0),
+	))
+	h.Start(monitor.DefaultOptions())
+
+	h.Modify(monitortest.InitContainers(
+		monitortest.NewPod("default", "web", monitortest.Crashed(monitortest.Container("app", 1), 1)),
+		monitortest.Crashed(monitortest.Container("migrate", 1), 1),
+	))
+	expected := map[string]string{"app": "ContainerRestart", "migrate": "InitContainerRestart"}
+	if reasons := eventReasons(h.WaitForEvents(2, 5*time.Second)); !reflect.DeepEqual(reasons, expected) {
+		t.Errorf("event reasons %v, want %v", reasons, expected)
+	}
+}
+
+func TestEphemeralContainerRestart(t *testing.T) {
+	h := monitortest.NewHarness(t, monitortest.EphemeralContainers(
+		monitortest.NewPod("default", "web", monitortest.Container("app", 0)),
+		monitortest.Container("debugger", 0),
+	))
+	h.Start(monitor.DefaultOptions())
+
+	h.Modify(monitortest.EphemeralContainers(
+		monitortest.NewPod("default", "web", monitortest.Container("app", 0)),
+		monitortest.Crashed(monitortest.Container("debugger", 1), 1),
+	))
+	expected := map[string]string{"debugger": "EphemeralContainerRestart"}
+	if reasons := eventReasons(h.WaitForEvents(1, 5*time.Second)); !reflect.DeepEqual(reasons, expected) {
+		t.Errorf("event reasons %v, want %v", reasons, expected)
+	}
+}
+
+func TestMinRestartCount(t *testing.T) {
+	for _, tc := range []struct {
+		minRestartCount int
+		expected        int32
+	}{
+		{1, 3},
+		{3, 1},
+	} {
+		t.Run(fmt.Sprint(tc.minRestartCount), func(t *testing.T) {
+			h := monitortest.NewHarness(t, monitortest.NewPod("default", "web", monitortest.Container("app", 0)))
+			opts := monitor.DefaultOptions()
+			opts.Cooldown = 0
+			opts.MinRestartCount = tc.minRestartCount
+			h.Start(opts)
+
+			for restartCount := int32(1); restartCount <= 3; restartCount++ {
+				h.Modify(monitortest.NewPod("default", "web", monitortest.Crashed(monitortest.Container("app", restartCount), 1)))
+			}
+			total := int32(0)
+			for _, event := range waitForEventCount(t, h, tc.expected) {
+				total += event.Count
+			}
+			if total != tc.expected {
+				t.Errorf("%d restarts reported, want %d", total, tc.expected)
+			}
+		})
+	}
+}
+
+func TestNodeNameFieldSelector(t *testing.T) {
+	h := monitortest.NewHarness(t, monitortest.NewPod("default", "web", monitortest.Container("app", 0)))
+	opts := monitor.DefaultOptions()
+	opts.NodeName = "node-1"
+	h.Start(opts)
+
+	var listed, watched bool
+	for _, action := range h.Client.Actions() {
+		if action.GetResource().Resource != "pods" {
+			continue
+		}
+		var selector string
+		switch action := action.(type) {
+		case k8stesting.ListAction:
+			listed = true
+			selector = action.GetListRestrictions().Fields.String()
+		case k8stesting.WatchAction:
+			watched = true
+			selector = action.GetWatchRestrictions().Fields.String()
+		default:
+			continue
+		}
+		if selector != "spec.nodeName=node-1" {
+			t.Errorf("%s field selector %q, want spec.nodeName=node-1", action.GetVerb(), selector)
+		}
+	}
+	if !listed || !watched {
+		t.Errorf("pods listed %v, watched %v, want both", listed, watched)
+	}
+}
+
+func TestStateResumedFromStaleResourceVersion(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+	// the restart counts reported before the monitor restarted
+	if err := os.WriteFile(stateFile, []byte(`{"resourceVersions":{"":"100"},"restartCounts":{"default/web":{"app":1}}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	h := monitortest.NewHarness(t, monitortest.NewPod("default", "web", monitortest.Crashed(monitortest.Container("app", 2), 1)))
+	// the fake clientset does not record the resourceVersion, so the first list, which resumes from the
+	// stored one, fails
+	lists := 0
+	h.Client.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		lists++
+		if lists == 1 {
+			return true, nil, apierrs.NewResourceExpired("too old resource version: 100")
+		}
+		return false, nil, nil
+	})
+	opts := monitor.DefaultOptions()
+	opts.StateFile = stateFile
+	h.Start(opts)
+
+	if lists != 2 {
+		t.Errorf("pods listed %d times, want the stale list and a full list", lists)
+	}
+	// only the restart while the monitor was not running is reported
+	h.WaitForEvents(1, 5*time.Second)
+	events := h.WaitForEvents(2, noEventsTimeout)
+	if len(events) != 1 {
+		t.Fatalf("%d events, want 1", len(events))
+	}
+	if events[0].Count != 1 {
+		t.Errorf("event count %d, want 1", events[0].Count)
+	}
+
+	h.Stop()
+	data, err := os.ReadFile(stateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var state struct {
+		RestartCounts map[string]map[string]int32 `json:"restartCounts"`
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		t.Fatal(err)
+	}
+	if count := state.RestartCounts["default/web"]["app"]; count != 2 {
+		t.Errorf("saved restart count %d, want 2", count)
+	}
+}
+
+// logBuffer collects the log output, which is written from the monitor goroutines.
+type logBuffer struct {
+	mu  sync.Mutex
+	buf strings.Builder
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *logBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// captureLogs sends the logs to the returned buffer until the test ends.
+func captureLogs(t *testing.T) *logBuffer {
+	defaultLogger := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(defaultLogger) })
+	logs := &logBuffer{}
+	slog.SetDefault(slog.New(slog.NewTextHandler(logs, nil)))
+	return logs
+}
+
+func TestDryRun(t *testing.T) {
+	logs := captureLogs(t)
+	h := monitortest.NewHarness(t, monitortest.NewPod("default", "web", monitortest.Container("app", 0)))
+	opts := monitor.DefaultOptions()
+	opts.DryRun = true
+	h.Start(opts)
+
+	h.Modify(monitortest.NewPod("default", "web", monitortest.Crashed(monitortest.Container("app", 1), 1)))
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(logs.String(), "Dry run") && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	h.Stop()
+
+	if events := h.Events(); len(events) != 0 {
+		t.Errorf("%d events in dry run, want 0", len(events))
+	}
+	output := logs.String()
+	if !strings.Contains(output, `msg="Dry run, not notifying sink" sink="kubernetes events" namespace=default pod=web container=app`) {
+		t.Errorf("no dry run log line in %q", output)
+	}
+}
+
+func TestRecoveryEvent(t *testing.T) {
+	h := monitortest.NewHarness(t, monitortest.NewPod("default", "web", monitortest.Container("app", 0)))
+	opts := monitor.DefaultOptions()
+	opts.RecoveryAfter = 200 * time.Millisecond
+	h.Start(opts)
+
+	h.Modify(monitortest.NewPod("default", "web", monitortest.Crashed(monitortest.Container("app", 1), 1)))
+	events := h.WaitForEvents(2, 5*time.Second)
+	if len(events) != 2 {
+		t.Fatalf("%d events, want the restart and the recovery", len(events))
+	}
+	if events[0].Type != v1.EventTypeWarning || events[0].Reason != "ContainerRestart" {
+		t.Errorf("first event %s %s, want a Warning ContainerRestart", events[0].Type, events[0].Reason)
+	}
+	recovery := events[1]
+	if recovery.Type != v1.EventTypeNormal || recovery.Reason != "ContainerRecovered" || recovery.InvolvedObject.Name != "web" {
+		t.Errorf("recovery event %s %s of %s, want a Normal ContainerRecovered of web", recovery.Type, recovery.Reason, recovery.InvolvedObject.Name)
+	}
+}
+
+func TestNoRecoveryEvent(t *testing.T) {
+	for name, update := range map[string]func(h *monitortest.Harness){
+		"restarted again": func(h *monitortest.Harness) {
+			h.Modify(monitortest.NewPod("default", "web", monitortest.CrashLoopBackOff(monitortest.Crashed(monitortest.Container("app", 2), 1))))
+		},
+		"deleted": func(h *monitortest.Harness) {
+			h.Delete(monitortest.NewPod("default", "web", monitortest.Container("app", 1)))
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			h := monitortest.NewHarness(t, monitortest.NewPod("default", "web", monitortest.Container("app", 0)))
+			opts := monitor.DefaultOptions()
+			opts.RecoveryAfter = 500 * time.Millisecond
+			h.Start(opts)
+
+			h.Modify(monitortest.NewPod("default", "web", monitortest.Crashed(monitortest.Container("app", 1), 1)))
+			h.WaitForEvents(1, 5*time.Second)
+			update(h)
+
+			time.Sleep(opts.RecoveryAfter)
+			for _, event := range h.WaitForEvents(0, noEventsTimeout) {
+				if event.Reason == "ContainerRecovered" {
+					t.Errorf("recovery event %q", event.Message)
+				}
+			}
+		})
+	}
+}
+
+func TestRecoveryEventAfterSlowReport(t *testing.T) {
+	pod := monitortest.NewPod("default", "web", monitortest.Container("app", 0))
+	pod.Spec.NodeName = "node-1"
+	h := monitortest.NewHarness(t, pod)
+	// the report waits for the node conditions, while the container gets ready
+	h.Client.PrependReactor("get", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		time.Sleep(300 * time.Millisecond)
+		return true, &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}}, nil
+	})
+	opts := monitor.DefaultOptions()
+	opts.RecoveryAfter = 200 * time.Millisecond
+	opts.IncludeNodeConditions = true
+	h.Start(opts)
+
+	restarted := monitortest.Crashed(monitortest.Container("app", 1), 1)
+	restarted.Ready = false
+	notReady := monitortest.NewPod("default", "web", restarted)
+	notReady.Spec.NodeName = "node-1"
+	h.Modify(notReady)
+	ready := monitortest.NewPod("default", "web", monitortest.Crashed(monitortest.Container("app", 1), 1))
+	ready.Spec.NodeName = "node-1"
+	h.Modify(ready)
+
+	var reasons []string
+	for _, event := range h.WaitForEvents(2, 5*time.Second) {
+		reasons = append(reasons, event.Reason)
+	}
+	sort.Strings(reasons)
+	if expected := []string{"ContainerRecovered", "ContainerRestart"}; !reflect.DeepEqual(reasons, expected) {
+		t.Errorf("event reasons %v, want %v", reasons, expected)
+	}
+}
+
+func TestContainerFilters(t *testing.T) {
+	for _, tc := range []struct {
+		include  string
+		exclude  string
+		expected []string
+	}{
+		{include: "app", expected: []string{"default/web/app"}},
+		{include: "app,*-proxy", expected: []string{"default/web/app", "default/web/istio-proxy"}},
+		{exclude: "istio-*,vault-agent", expected: []string{"default/web/app"}},
+		{include: "*", exclude: "vault-agent", expected: []string{"default/web/app", "default/web/istio-proxy"}},
+	} {
+		opts := monitor.DefaultOptions()
+		opts.IncludeContainers = tc.include
+		opts.ExcludeContainers = tc.exclude
+		restarts := reportedRestarts(t, opts, monitortest.NewPod("default", "web",
+			monitortest.Container("app", 0),
+			monitortest.Container("istio-proxy", 0),
+			monitortest.Container("vault-agent", 0),
+		))
+		if !reflect.DeepEqual(restarts, tc.expected) {
+			t.Errorf("include %q, exclude %q: reported restarts %v, want %v", tc.include, tc.exclude, restarts, tc.expected)
+		}
+	}
+}
+
+func TestExcludedNamespaces(t *testing.T) {
+	pods := func() []*v1.Pod {
+		return []*v1.Pod{
+			monitortest.NewPod("default", "web", monitortest.Container("app", 0)),
+			monitortest.NewPod("kube-system", "coredns", monitortest.Container("coredns", 0)),
+			monitortest.NewPod("monitoring", "prometheus", monitortest.Container("prometheus", 0)),
+		}
+	}
+	for _, tc := range []struct {
+		excludeNamespaces string
+		expected          []string
+	}{
+		{monitor.DefaultOptions().ExcludeNamespaces, []string{"default/web/app", "monitoring/prometheus/prometheus"}},
+		{"monitoring", []string{"default/web/app", "kube-system/coredns/coredns"}},
+		{"", []string{"default/web/app", "kube-system/coredns/coredns", "monitoring/prometheus/prometheus"}},
+	} {
+		opts := monitor.DefaultOptions()
+		opts.ExcludeNamespaces = tc.excludeNamespaces
+		if restarts := reportedRestarts(t, opts, pods()...); !reflect.DeepEqual(restarts, tc.expected) {
+			t.Errorf("excluded namespaces %q: reported restarts %v, want %v", tc.excludeNamespaces, restarts, tc.expected)
+		}
+	}
+}
+
+func TestPodNameRegexp(t *testing.T) {
+	pods := func() []*v1.Pod {
+		return []*v1.Pod{
+			monitortest.NewPod("default", "web-7d4b9c8f6-x2k9p", monitortest.Container("app", 0)),
+			monitortest.NewPod("staging", "web-5f6c7d8e9-b4n2q", monitortest.Container("app", 0)),
+			monitortest.NewPod("kube-system", "web-proxy", monitortest.Container("proxy", 0)),
+			monitortest.NewPod("default", "worker-0", monitortest.Container("app", 0)),
+			monitortest.NewPod("default", "frontend-web", monitortest.Container("app", 0)),
+		}
+	}
+	for _, tc := range []struct {
+		podNameRegexp string
+		expected      []string
+	}{
+		// combined with the default -exclude-namespaces
+		{"^web-", []string{"default/web-7d4b9c8f6-x2k9p/app", "staging/web-5f6c7d8e9-b4n2q/app"}},
+		// unanchored
+		{"web", []string{"default/frontend-web/app", "default/web-7d4b9c8f6-x2k9p/app", "staging/web-5f6c7d8e9-b4n2q/app"}},
+		{"^(worker|frontend)-", []string{"default/frontend-web/app", "default/worker-0/app"}},
+		{"^db-", nil},
+	} {
+		opts := monitor.DefaultOptions()
+		opts.PodNameRegexp = tc.podNameRegexp
+		if restarts := reportedRestarts(t, opts, pods()...); !reflect.DeepEqual(restarts, tc.expected) {
+			t.Errorf("pod name regexp %q: reported restarts %v, want %v", tc.podNameRegexp, restarts, tc.expected)
+		}
+	}
+
+	opts := monitor.DefaultOptions()
+	opts.PodNameRegexp = "^web-("
+	if _, err := monitor.New(fake.NewSimpleClientset(), opts); err == nil || !strings.Contains(err.Error(), "invalid pod name regexp") {
+		t.Errorf("error = %v, want invalid pod name regexp", err)
+	}
+}
+
+func ownedBy(meta *metav1.ObjectMeta, kind, name string) {
+	controller := true
+	apiVersion := "apps/v1"
+	if kind == "Job" || kind == "CronJob" {
+		apiVersion = "batch/v1"
+	}
+	meta.OwnerReferences = []metav1.OwnerReference{{
+		APIVersion: apiVersion,
+		Kind:       kind,
+		Name:       name,
+		UID:        types.UID(kind + "/" + name),
+		Controller: &controller,
+	}}
+}
+
+// newDeploymentHarness returns a harness with the pods web-7d4b9c8f6-x2k9p of the ReplicaSet web-7d4b9c8f6 of the
+// Deployment web, and the bare pod debug.
+func newDeploymentHarness(t *testing.T) *monitortest.Harness {
+	deploymentPod := monitortest.NewPod("default", "web-7d4b9c8f6-x2k9p", monitortest.Container("app", 0))
+	ownedBy(&deploymentPod.ObjectMeta, "ReplicaSet", "web-7d4b9c8f6")
+	h := monitortest.NewHarness(t, deploymentPod, monitortest.NewPod("default", "debug", monitortest.Container("app", 0)))
+
+	replicaSet := &appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web-7d4b9c8f6", UID: "ReplicaSet/web-7d4b9c8f6"}}
+	ownedBy(&replicaSet.ObjectMeta, "Deployment", "web")
+	if err := h.Client.Tracker().Add(replicaSet); err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+// restartDeploymentPods restarts the pods of newDeploymentHarness and returns the events by pod name.
+func restartDeploymentPods(t *testing.T, h *monitortest.Harness) map[string]*v1.Event {
+	t.Helper()
+	deploymentPod := monitortest.NewPod("default", "web-7d4b9c8f6-x2k9p", monitortest.Crashed(monitortest.Container("app", 1), 1))
+	ownedBy(&deploymentPod.ObjectMeta, "ReplicaSet", "web-7d4b9c8f6")
+	h.Modify(deploymentPod)
+	h.Modify(monitortest.NewPod("default", "debug", monitortest.Crashed(monitortest.Container("app", 1), 1)))
+
+	events := make(map[string]*v1.Event)
+	for _, event := range h.WaitForEvents(2, 5*time.Second) {
+		for _, pod := range []string{"web-7d4b9c8f6-x2k9p", "debug"} {
+			if strings.Contains(event.Message, "pod default/"+pod+" ") {
+				events[pod] = event
+			}
+		}
+	}
+	if len(events) != 2 {
+		t.Fatalf("events of %d pods, want 2", len(events))
+	}
+	return events
+}
+
+func TestPodOwner(t *testing.T) {
+	h := newDeploymentHarness(t)
+	h.Start(monitor.DefaultOptions())
+	events := restartDeploymentPods(t, h)
+
+	event := events["web-7d4b9c8f6-x2k9p"]
+	if !strings.Contains(event.Message, "\nOwner: Deployment/web.") {
+		t.Errorf("message %q has no Deployment owner", event.Message)
+	}
+	if kind, name := event.Annotations["restart-monitor.smpio/owner-kind"], event.Annotations["restart-monitor.smpio/owner-name"]; kind != "Deployment" || name != "web" {
+		t.Errorf("owner annotations %s/%s, want Deployment/web", kind, name)
+	}
+
+	bare := events["debug"]
+	if strings.Contains(bare.Message, "Owner:") {
+		t.Errorf("message %q of a bare pod has an owner", bare.Message)
+	}
+	if _, ok := bare.Annotations["restart-monitor.smpio/owner-kind"]; ok {
+		t.Error("event of a bare pod has an owner annotation")
+	}
+}
+
+func TestOwnerTarget(t *testing.T) {
+	h := newDeploymentHarness(t)
+	opts := monitor.DefaultOptions()
+	opts.Target = "owner"
+	h.Start(opts)
+	events := restartDeploymentPods(t, h)
+
+	event := events["web-7d4b9c8f6-x2k9p"]
+	involved := event.InvolvedObject
+	if involved.APIVersion != "apps/v1" || involved.Kind != "Deployment" || involved.Namespace != "default" || involved.Name != "web" || involved.UID != "Deployment/web" {
+		t.Errorf("involved object %+v, want Deployment default/web", involved)
+	}
+	// the pod identity is kept in the message
+	if !strings.Contains(event.Message, "pod default/web-7d4b9c8f6-x2k9p") {
+		t.Errorf("message %q has no pod", event.Message)
+	}
+
+	if involved := events["debug"].InvolvedObject; involved.Kind != "Pod" || involved.Name != "debug" {
+		t.Errorf("involved object %+v of a bare pod, want the pod", involved)
+	}
+}
+
+func TestEventsAPIActionAndRelated(t *testing.T) {
+	h := newDeploymentHarness(t)
+	opts := monitor.DefaultOptions()
+	opts.EventsAPI = "events.k8s.io"
+	opts.EventAction = "Restarting"
+	h.Start(opts)
+
+	deploymentPod := monitortest.NewPod("default", "web-7d4b9c8f6-x2k9p", monitortest.Crashed(monitortest.Container("app", 1), 1))
+	ownedBy(&deploymentPod.ObjectMeta, "ReplicaSet", "web-7d4b9c8f6")
+	h.Modify(deploymentPod)
+	h.Modify(monitortest.NewPod("default", "debug", monitortest.Crashed(monitortest.Container("app", 1), 2)))
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		events, err := h.Client.EventsV1().Events("default").List(context.Background(), metav1.ListOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events.Items) < 2 && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+			continue
+		}
+		if len(events.Items) != 2 {
+			t.Fatalf("%d events, want 2", len(events.Items))
+		}
+		for _, event := range events.Items {
+			if event.Action != "Restarting" {
+				t.Errorf("event of %s: action %q, want Restarting", event.Regarding.Name, event.Action)
+			}
+			switch event.Regarding.Name {
+			case "web-7d4b9c8f6-x2k9p":
+				if event.Related == nil || event.Related.Kind != "Deployment" || event.Related.Name != "web" {
+					t.Errorf("related = %+v, want deployment default/web", event.Related)
+				}
+			case "debug":
+				if event.Related != nil {
+					t.Errorf("event of a bare pod has related object %+v", event.Related)
+				}
+			default:
+				t.Errorf("event regarding %+v", event.Regarding)
+			}
+		}
+		return
+	}
+}
+
+func TestConcurrentRestarts(t *testing.T) {
+	// the 4 restarts per pod fit in the sink queue of 100 notifications, which drops the ones beyond
+	const podCount = 25
+	var pods []*v1.Pod
+	for i := 0; i < podCount; i++ {
+		pods = append(pods, monitortest.NewPod("default", fmt.Sprintf("web-%d", i), monitortest.Container("app", 0), monitortest.Container("sidecar", 0)))
+	}
+	h := monitortest.NewHarness(t, pods...)
+	opts := monitor.DefaultOptions()
+	opts.Cooldown = 0
+	opts.Workers = 8
+	h.Start(opts)
+
+	// every container restarts twice, the restarts of a pod are handled in order by one worker
+	for restartCount := int32(1); restartCount <= 2; restartCount++ {
+		for i := 0; i < podCount; i++ {
+			h.Modify(monitortest.NewPod("default", fmt.Sprintf("web-%d", i),
+				monitortest.Crashed(monitortest.Container("app", restartCount), 1),
+				monitortest.Crashed(monitortest.Container("sidecar", restartCount), 1),
+			))
+		}
+	}
+
+	events := waitForEventCount(t, h, 4*podCount)
+	counts := make(map[string]int32)
+	for _, event := range events {
+		var container string
+		fmt.Sscanf(event.Message, "Container %s in pod", &container)
+		counts[event.InvolvedObject.Name+"/"+container] += event.Count
+	}
+	if len(counts) != 2*podCount {
+		t.Errorf("restarts of %d containers reported, want %d", len(counts), 2*podCount)
+	}
+	for container, count := range counts {
+		if count != 2 {
+			t.Errorf("%d restarts of %s reported, want 2", count, container)
+		}
+	}
+}
+
+func TestEventSource(t *testing.T) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		component, host string
+		expected        v1.EventSource
+	}{
+		{"restart-monitor", "monitor-7f9c-abcde", v1.EventSource{Component: "restart-monitor", Host: "monitor-7f9c-abcde"}},
+		{monitor.DefaultOptions().EventSourceComponent, "", v1.EventSource{Component: "kube-restart-monitor", Host: hostname}},
+	} {
+		h := monitortest.NewHarness(t, monitortest.NewPod("default", "web", monitortest.Container("app", 0)))
+		opts := monitor.DefaultOptions()
+		opts.EventSourceComponent = tc.component
+		opts.EventSourceHost = tc.host
+		h.Start(opts)
+
+		h.Modify(monitortest.NewPod("default", "web", monitortest.Crashed(monitortest.Container("app", 1), 1)))
+		events := h.WaitForEvents(1, 5*time.Second)
+		h.Stop()
+		if len(events) != 1 {
+			t.Fatalf("%d events, want 1", len(events))
+		}
+		if events[0].Source != tc.expected {
+			t.Errorf("event source %+v, want %+v", events[0].Source, tc.expected)
+		}
+	}
+}
+
+func TestImageChangeIsNotReported(t *testing.T) {
+	withImage := func(containerStatus v1.ContainerStatus, image string) v1.ContainerStatus {
+		containerStatus.Image = image
+		return containerStatus
+	}
+	h := monitortest.NewHarness(t, monitortest.NewPod("default", "web",
+		withImage(monitortest.Container("app", 0), "app:1.0"),
+		withImage(monitortest.Container("sidecar", 0), "sidecar:1.0"),
+	))
+	h.Start(monitor.DefaultOptions())
+
+	// app is updated in place, sidecar crashes
+	h.Modify(monitortest.NewPod("default", "web",
+		withImage(monitortest.Crashed(monitortest.Container("app", 1), 1), "app:2.0"),
+		withImage(monitortest.Crashed(monitortest.Container("sidecar", 1), 1), "sidecar:1.0"),
+	))
+	h.WaitForEvents(1, 5*time.Second)
+	events := h.WaitForEvents(2, noEventsTimeout)
+	if len(events) != 1 {
+		t.Fatalf("%d events, want 1", len(events))
+	}
+	if !strings.HasPrefix(events[0].Message, "Container sidecar ") {
+		t.Errorf("event %q, want the restart of sidecar", events[0].Message)
+	}
+}
+
+func imagePullError(containerStatus v1.ContainerStatus, reason string) v1.ContainerStatus {
+	containerStatus.Ready = false
+	containerStatus.State = v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: reason, Message: "manifest unknown"}}
+	return containerStatus
+}
+
+func TestImagePullErrors(t *testing.T) {
+	for _, watchImagePullErrors := range []bool{true, false} {
+		h := monitortest.NewHarness(t, monitortest.NewPod("default", "web", monitortest.Container("app", 0)))
+		opts := monitor.DefaultOptions()
+		opts.WatchImagePullErrors = watchImagePullErrors
+		h.Start(opts)
+
+		// one failure to pull, retried with backoff
+		h.Modify(monitortest.NewPod("default", "web", imagePullError(monitortest.Container("app", 0), "ImagePullBackOff")))
+		h.Modify(monitortest.NewPod("default", "web", imagePullError(monitortest.Container("app", 0), "ErrImagePull")))
+		h.Modify(monitortest.NewPod("default", "web", imagePullError(monitortest.Container("app", 0), "ImagePullBackOff")))
+		if watchImagePullErrors {
+			h.WaitForEvents(1, 5*time.Second)
+		}
+		events := h.WaitForEvents(2, noEventsTimeout)
+		h.Stop()
+
+		if !watchImagePullErrors {
+			if len(events) != 0 {
+				t.Errorf("%d events without -watch-image-pull-errors, want 0", len(events))
+			}
+			continue
+		}
+		if len(events) != 1 {
+			t.Fatalf("%d events, want 1", len(events))
+		}
+		event := events[0]
+		expected := "Container app in pod default/web cannot pull image app:latest: ImagePullBackOff.\nMessage: manifest unknown"
+		if event.Reason != "ContainerImagePullError" || event.Type != v1.EventTypeWarning || event.Message != expected {
+			t.Errorf("event %s %s %q, want a Warning ContainerImagePullError %q", event.Type, event.Reason, event.Message, expected)
+		}
+	}
+}
+
+func TestRestartDetectedByResync(t *testing.T) {
+	withResourceVersion := func(pod *v1.Pod, resourceVersion string) *v1.Pod {
+		pod.ResourceVersion = resourceVersion
+		return pod
+	}
+	h := monitortest.NewHarness(t, withResourceVersion(monitortest.NewPod("default", "web", monitortest.Container("app", 0)), "1"))
+	opts := monitor.DefaultOptions()
+	opts.ResyncPeriod = 50 * time.Millisecond
+	// not suppressed by the cooldown if reported again
+	opts.Cooldown = 0
+	h.Start(opts)
+
+	// the watch event of the restart is missed
+	h.SetListed(withResourceVersion(monitortest.NewPod("default", "web", monitortest.Crashed(monitortest.Container("app", 1), 1)), "2"))
+	h.WaitForEvents(1, 5*time.Second)
+	// the following resyncs list the restarted pod again, a report would create or patch an event
+	time.Sleep(5*opts.ResyncPeriod + noEventsTimeout)
+	events := listEvents(t, h)
+	if len(events) != 1 {
+		t.Fatalf("%d events, want 1", len(events))
+	}
+	if events[0].Count != 1 {
+		t.Errorf("event count %d, want 1", events[0].Count)
+	}
+}
+
+func TestPropagatedLabels(t *testing.T) {
+	labeled := func(pod *v1.Pod) *v1.Pod {
+		pod.Labels = map[string]string{"team": "payments", "severity": "critical", "pod-template-hash": "7d4b9c8f6"}
+		return pod
+	}
+	h := monitortest.NewHarness(t, labeled(monitortest.NewPod("default", "web", monitortest.Container("app", 0))))
+	opts := monitor.DefaultOptions()
+	opts.PropagateLabels = "team,severity"
+	h.Start(opts)
+
+	h.Modify(labeled(monitortest.NewPod("default", "web", monitortest.Crashed(monitortest.Container("app", 1), 1))))
+	events := h.WaitForEvents(1, 5*time.Second)
+	if len(events) != 1 {
+		t.Fatalf("%d events, want 1", len(events))
+	}
+	annotations := events[0].Annotations
+	for key, value := range map[string]string{
+		"restart-monitor.smpio/label.team":     "payments",
+		"restart-monitor.smpio/label.severity": "critical",
+	} {
+		if annotations[key] != value {
+			t.Errorf("annotation %s = %q, want %q", key, annotations[key], value)
+		}
+	}
+	if _, ok := annotations["restart-monitor.smpio/label.pod-template-hash"]; ok {
+		t.Error("label not in the allowlist is copied")
+	}
+}
+
+func TestRelistAfterGoneWatch(t *testing.T) {
+	for name, status := range map[string]*metav1.Status{
+		"gone":            {Status: metav1.StatusFailure, Code: http.StatusGone, Reason: metav1.StatusReasonGone},
+		"bare 410 status": {Status: metav1.StatusFailure, Code: http.StatusGone},
+	} {
+		t.Run(name, func(t *testing.T) {
+			h := monitortest.NewHarness(t, monitortest.NewPod("default", "web", monitortest.Container("app", 0)))
+			h.Start(monitor.DefaultOptions())
+
+			// the restart is missed while disconnected
+			h.SetListed(monitortest.NewPod("default", "web", monitortest.Container("app", 1)))
+			h.Watcher.Error(status)
+			if events := h.WaitForEvents(1, 10*time.Second); len(events) != 1 {
+				t.Fatalf("%d events after relist, want 1", len(events))
+			}
+		})
+	}
+}
+
+func TestRestartSpans(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	// the monitor's tracer delegates to the first provider set, so it is not restored
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+
+	h := monitortest.NewHarness(t, monitortest.NewPod("tracing", "web", monitortest.Container("app", 0)))
+	h.Start(monitor.DefaultOptions())
+	h.Modify(monitortest.NewPod("tracing", "web", monitortest.Crashed(monitortest.Container("app", 1), 1)))
+	if events := h.WaitForEvents(1, 5*time.Second); len(events) != 1 {
+		t.Fatalf("%d events, want 1", len(events))
+	}
+	h.Stop()
+
+	spans := map[string]tracetest.SpanStub{}
+	for _, span := range exporter.GetSpans() {
+		attributes := map[attribute.Key]string{}
+		for _, kv := range span.Attributes {
+			attributes[kv.Key] = kv.Value.Emit()
+		}
+		if attributes["k8s.namespace.name"] != "tracing" || attributes["k8s.pod.name"] != "web" {
+			continue
+		}
+		if span.Name != "handlePodUpdate" && attributes["k8s.container.name"] != "app" {
+			t.Errorf("span %s has container %q, want app", span.Name, attributes["k8s.container.name"])
+		}
+		// the initial list also updates the pod, without a restart
+		if span.Name != "handlePodUpdate" || span.ChildSpanCount > 0 {
+			spans[span.Name] = span
+		}
+	}
+	for _, tc := range []struct{ name, parent string }{
+		{"handlePodUpdate", ""},
+		{"handleContainerRestart", "handlePodUpdate"},
+		{"reportRestart", "handleContainerRestart"},
+	} {
+		span, ok := spans[tc.name]
+		if !ok {
+			t.Errorf("no %s span", tc.name)
+			continue
+		}
+		if tc.parent != "" && span.Parent.SpanID() != spans[tc.parent].SpanContext.SpanID() {
+			t.Errorf("%s span is not a child of the %s span", tc.name, tc.parent)
+		}
+	}
+}
+
+func TestSingleNamespace(t *testing.T) {
+	h := monitortest.NewHarness(t,
+		monitortest.NewPod("team-a", "web", monitortest.Container("app", 0)),
+		monitortest.NewPod("team-b", "web", monitortest.Container("app", 0)),
+	)
+	opts := monitor.DefaultOptions()
+	opts.Namespace = "team-a"
+	h.Start(opts)
+
+	h.Modify(monitortest.NewPod("team-a", "web", monitortest.Crashed(monitortest.Container("app", 1), 1)))
+	events := h.WaitForEvents(1, 5*time.Second)
+	if len(events) != 1 {
+		t.Fatalf("%d events, want 1", len(events))
+	}
+	if events[0].Namespace != "team-a" {
+		t.Errorf("event created in %q, want team-a", events[0].Namespace)
+	}
+	h.Stop()
+
+	// a Role in team-a grants all calls
+	calls := map[string]bool{}
+	for _, action := range h.Client.Actions() {
+		call := action.GetVerb() + " " + action.GetResource().Resource
+		calls[call] = true
+		if action.GetNamespace() != "team-a" {
+			t.Errorf("%s in namespace %q, want team-a", call, action.GetNamespace())
+		}
+	}
+	for _, call := range []string{"list pods", "watch pods", "create events"} {
+		if !calls[call] {
+			t.Errorf("no %s call", call)
+		}
+	}
+}
+
+func TestNamespaceAndNamespacesAreExclusive(t *testing.T) {
+	opts := monitor.DefaultOptions()
+	opts.Namespace = "team-a"
+	opts.Namespaces = "team-a,team-b"
+	if _, err := monitor.New(monitortest.NewHarness(t).Client, opts); err == nil || !strings.Contains(err.Error(), "mutually exclusive") {
+		t.Errorf("error = %v, want -namespace and -namespaces mutually exclusive", err)
+	}
+}
+
+func TestMuteScheduleWindow(t *testing.T) {
+	// a window in two hours, so that now is outside of it
+	later := time.Now().UTC().Add(2 * time.Hour)
+	outside := later.Format("15:04") + "-" + later.Add(time.Hour).Format("15:04")
+	for _, tc := range []struct {
+		schedule string
+		events   int
+	}{
+		{"00:00-24:00", 0},
+		{outside, 1},
+	} {
+		t.Run(tc.schedule, func(t *testing.T) {
+			h := monitortest.NewHarness(t, monitortest.NewPod("default", "web", monitortest.Container("app", 0)))
+			opts := monitor.DefaultOptions()
+			opts.MuteSchedule = tc.schedule
+			h.Start(opts)
+
+			h.Modify(monitortest.NewPod("default", "web", monitortest.Crashed(monitortest.Container("app", 1), 1)))
+			timeout := 5 * time.Second
+			if tc.events == 0 {
+				timeout = noEventsTimeout
+			}
+			if events := h.WaitForEvents(tc.events, timeout); len(events) != tc.events {
+				t.Errorf("%d events, want %d", len(events), tc.events)
+			}
+		})
+	}
+}
+
+func TestEventTypeByExitCode(t *testing.T) {
+	h := monitortest.NewHarness(t, monitortest.NewPod("default", "web", monitortest.Container("clean", 0), monitortest.Container("failed", 0)))
+	opts := monitor.DefaultOptions()
+	// report clean exits too
+	opts.IgnoreExitCodes = ""
+	h.Start(opts)
+
+	h.Modify(monitortest.NewPod("default", "web",
+		monitortest.Crashed(monitortest.Container("clean", 1), 0),
+		monitortest.Crashed(monitortest.Container("failed", 1), 1),
+	))
+	eventTypes := map[string]string{}
+	for _, event := range h.WaitForEvents(2, 5*time.Second) {
+		container := strings.Fields(event.Message)[1]
+		eventTypes[container] = event.Type
+	}
+	if expected := map[string]string{"clean": v1.EventTypeNormal, "failed": v1.EventTypeWarning}; !reflect.DeepEqual(eventTypes, expected) {
+		t.Errorf("event types %v, want %v", eventTypes, expected)
+	}
+}
+
+func TestNeverReadyCrashLoop(t *testing.T) {
+	notReady := func(restartCount int32) v1.ContainerStatus {
+		// distinct exit codes, so that the events are not aggregated
+		return monitortest.Crashed(monitortest.CrashLoopBackOff(monitortest.Container("app", restartCount)), restartCount)
+	}
+	for _, tc := range []struct {
+		name       string
+		ready      bool
+		neverReady string
+	}{
+		{"never ready", false, "true"},
+		{"flapping", true, ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			h := monitortest.NewHarness(t, monitortest.NewPod("default", "web", monitortest.Container("app", 0)))
+			opts := monitor.DefaultOptions()
+			opts.Cooldown = 0
+			h.Start(opts)
+
+			h.Modify(monitortest.NewPod("default", "web", notReady(1)))
+			between := notReady(1)
+			between.Ready = tc.ready
+			h.Modify(monitortest.NewPod("default", "web", between))
+			h.Modify(monitortest.NewPod("default", "web", notReady(2)))
+
+			events := h.WaitForEvents(2, 5*time.Second)
+			if len(events) != 2 {
+				t.Fatalf("%d events, want 2", len(events))
+			}
+			// the history before the first restart is unknown
+			if value, ok := events[0].Annotations["restart-monitor.smpio/never-became-ready"]; ok {
+				t.Errorf("first restart is annotated never-became-ready=%s", value)
+			}
+			if value := events[1].Annotations["restart-monitor.smpio/never-became-ready"]; value != tc.neverReady {
+				t.Errorf("never-became-ready annotation %q, want %q", value, tc.neverReady)
+			}
+		})
+	}
+}
+
+func TestSelfStartedEvent(t *testing.T) {
+	t.Setenv("POD_NAMESPACE", "monitoring")
+	t.Setenv("POD_NAME", "kube-restart-monitor-0")
+	h := monitortest.NewHarness(t, monitortest.NewPod("monitoring", "kube-restart-monitor-0", monitortest.Container("monitor", 0)))
+	opts := monitor.DefaultOptions()
+	opts.SelfEvent = true
+	opts.Version = "1.2.3"
+	h.Start(opts)
+
+	events := h.WaitForEvents(1, 5*time.Second)
+	if len(events) != 1 {
+		t.Fatalf("%d events, want 1", len(events))
+	}
+	event := events[0]
+	if event.Reason != "RestartMonitorStarted" || event.Type != v1.EventTypeNormal {
+		t.Errorf("event reason %s, type %s, want RestartMonitorStarted, Normal", event.Reason, event.Type)
+	}
+	if event.InvolvedObject.Namespace != "monitoring" || event.InvolvedObject.Name != "kube-restart-monitor-0" || event.InvolvedObject.UID != "monitoring/kube-restart-monitor-0" {
+		t.Errorf("event involved object %+v, want the monitor pod", event.InvolvedObject)
+	}
+	if expected := "kube-restart-monitor 1.2.3 started, container restarts while it was not running may have been missed"; event.Message != expected {
+		t.Errorf("message %q, want %q", event.Message, expected)
+	}
+}
+
+func TestRestartStormEvent(t *testing.T) {
+	t.Setenv("POD_NAMESPACE", "monitoring")
+	t.Setenv("POD_NAME", "kube-restart-monitor-0")
+	var pods []*v1.Pod
+	for i := 0; i < 6; i++ {
+		pods = append(pods, monitortest.NewPod("default", fmt.Sprintf("web-%d", i), monitortest.Container("app", 0)))
+	}
+	h := monitortest.NewHarness(t, pods...)
+	opts := monitor.DefaultOptions()
+	opts.StormThreshold = 3
+	h.Start(opts)
+
+	for i := range pods {
+		h.Modify(monitortest.NewPod("default", fmt.Sprintf("web-%d", i), monitortest.Crashed(monitortest.Container("app", 1), 1)))
+	}
+	h.WaitForEvents(3, 5*time.Second)
+	time.Sleep(noEventsTimeout)
+	reasons := map[string]int{}
+	for _, event := range h.Events() {
+		reasons[event.Reason]++
+		if event.Reason == "RestartStorm" && event.InvolvedObject.Name != "kube-restart-monitor-0" {
+			t.Errorf("storm event of %+v, want the monitor pod", event.InvolvedObject)
+		}
+	}
+	// the restarts up to the threshold are reported on their own
+	if expected := map[string]int{"ContainerRestart": 2, "RestartStorm": 1}; !reflect.DeepEqual(reasons, expected) {
+		t.Errorf("events %v, want %v", reasons, expected)
+	}
+}
+
+func TestNamespaceRateLimit(t *testing.T) {
+	var pods []*v1.Pod
+	for i := 0; i < 5; i++ {
+		pods = append(pods, monitortest.NewPod("noisy", fmt.Sprintf("web-%d", i), monitortest.Container("app", 0)))
+	}
+	pods = append(pods, monitortest.NewPod("quiet", "web", monitortest.Container("app", 0)))
+	h := monitortest.NewHarness(t, pods...)
+	opts := monitor.DefaultOptions()
+	opts.NamespaceRateLimit = 0.01
+	opts.NamespaceRateBurst = 2
+	h.Start(opts)
+
+	for _, pod := range pods {
+		h.Modify(monitortest.NewPod(pod.Namespace, pod.Name, monitortest.Crashed(monitortest.Container("app", 1), 1)))
+	}
+	h.WaitForEvents(3, 5*time.Second)
+	time.Sleep(noEventsTimeout)
+	namespaces := map[string]int{}
+	for _, event := range h.Events() {
+		namespaces[event.Namespace]++
+	}
+	if expected := map[string]int{"noisy": 2, "quiet": 1}; !reflect.DeepEqual(namespaces, expected) {
+		t.Errorf("events by namespace %v, want %v", namespaces, expected)
+	}
+}
+
+// the monitor only needs a kubernetes.Interface, see monitortest for the harness built on this
+func TestMonitorWithFakeClient(t *testing.T) {
+	pod := monitortest.NewPod("default", "web", monitortest.Container("app", 0))
+	client := fake.NewSimpleClientset(pod)
+	watcher := watch.NewFake()
+	client.PrependWatchReactor("pods", k8stesting.DefaultWatchReactor(watcher, nil))
+	opts := monitor.DefaultOptions()
+	opts.EnableLeaderElection = false
+	m, err := monitor.New(client, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- m.Run(ctx)
+	}()
+	defer func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("Run returned %v", err)
+		}
+	}()
+
+	// blocks until the monitor watches
+	watcher.Modify(monitortest.NewPod("default", "web", monitortest.Crashed(monitortest.Container("app", 1), 1)))
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		events, err := client.CoreV1().Events("default").List(ctx, metav1.ListOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events.Items) > 0 {
+			if name := events.Items[0].InvolvedObject.Name; name != "web" {
+				t.Errorf("event of pod %s, want web", name)
+			}
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("restart is not reported")
+}
@@ -0,0 +1,255 @@
+// Package monitortest runs the monitor against a fake clientset, for testing the restart detection end to end.
+//
+// Pods passed to NewHarness make up the initial list, establishing the baseline restart counts. Updates sent with
+// Modify go through the watch to the detection pipeline, and the reported events are read back from the actions
+// recorded by the fake clientset:
+//
+//	h := monitortest.NewHarness(t, monitortest.NewPod("default", "web", monitortest.Container("app", 0)))
+//	h.Start(monitor.DefaultOptions())
+//	defer h.Stop()
+//	h.Modify(monitortest.NewPod("default", "web", monitortest.Crashed(monitortest.Container("app", 1), 1)))
+//	events := h.WaitForEvents(1, 5*time.Second)
+package monitortest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/smpio/kube-restart-monitor/monitor"
+)
+
+// Harness is a fake clientset with a controllable pod watch.
+type Harness struct {
+	Client  *fake.Clientset
+	Watcher *watch.FakeWatcher
+
+	t    testing.TB
+	stop func() error
+}
+
+// NewHarness creates a fake clientset listing the given pods. Failures are reported to t.
+func NewHarness(t testing.TB, pods ...*v1.Pod) *Harness {
+	t.Helper()
+	client := fake.NewSimpleClientset()
+	for _, pod := range pods {
+		if err := client.Tracker().Add(pod); err != nil {
+			t.Fatalf("unable to add pod %s/%s: %v", pod.Namespace, pod.Name, err)
+		}
+	}
+	watcher := watch.NewFakeWithChanSize(100, false)
+	client.PrependWatchReactor("pods", k8stesting.DefaultWatchReactor(watcher, nil))
+	return &Harness{Client: client, Watcher: watcher, t: t}
+}
+
+// Start runs a monitor with the options until Stop is called, or the test ends.
+// Leader election is disabled and the initial list is awaited, so updates sent afterwards are not part of the baseline.
+func (h *Harness) Start(opts monitor.Options) {
+	h.t.Helper()
+	opts.EnableLeaderElection = false
+	m, err := monitor.New(h.Client, opts)
+	if err != nil {
+		h.t.Fatalf("unable to create monitor: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- m.Run(ctx)
+	}()
+	h.stop = func() error {
+		cancel()
+		return <-done
+	}
+	h.t.Cleanup(h.Stop)
+
+	if err := h.waitFor(5*time.Second, func() bool {
+		return h.countActions("watch", "pods") > 0
+	}); err != nil {
+		h.Stop()
+		h.t.Fatalf("pods are not watched: %v", err)
+	}
+}
+
+// Stop stops the monitor and waits for it to return, which sends the pending notifications.
+// It does nothing if the monitor is not running.
+func (h *Harness) Stop() {
+	h.t.Helper()
+	if h.stop == nil {
+		return
+	}
+	err := h.stop()
+	h.stop = nil
+	if err != nil {
+		h.t.Errorf("monitor failed: %v", err)
+	}
+}
+
+// Modify sends a pod update through the watch.
+func (h *Harness) Modify(pod *v1.Pod) {
+	h.Watcher.Modify(pod)
+}
+
+// Delete sends a pod deletion through the watch.
+func (h *Harness) Delete(pod *v1.Pod) {
+	h.Watcher.Delete(pod)
+}
+
+// SetListed replaces the pod in the list returned to the monitor, without a watch event, e.g. to change it
+// while the watch is disconnected.
+func (h *Harness) SetListed(pod *v1.Pod) {
+	h.t.Helper()
+	if err := h.Client.Tracker().Update(v1.SchemeGroupVersion.WithResource("pods"), pod, pod.Namespace); err != nil {
+		h.t.Fatalf("unable to update pod %s/%s: %v", pod.Namespace, pod.Name, err)
+	}
+}
+
+// ExpireWatch fails the watch with an expired resourceVersion, so that the monitor relists the pods.
+func (h *Harness) ExpireWatch() {
+	h.Watcher.Error(&metav1.Status{
+		Status: metav1.StatusFailure,
+		Code:   http.StatusGone,
+		Reason: metav1.StatusReasonExpired,
+	})
+}
+
+// Events returns the core events created so far.
+func (h *Harness) Events() []*v1.Event {
+	var events []*v1.Event
+	for _, action := range h.Client.Actions() {
+		create, ok := action.(k8stesting.CreateAction)
+		if !ok || create.GetResource().Resource != "events" {
+			continue
+		}
+		if event, ok := create.GetObject().(*v1.Event); ok {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// WaitForEvents waits until at least n core events are created and returns them, or returns the events
+// created so far after the timeout. Use a short timeout with n = 0 to check that no events are created.
+func (h *Harness) WaitForEvents(n int, timeout time.Duration) []*v1.Event {
+	h.waitFor(timeout, func() bool {
+		return n > 0 && len(h.Events()) >= n
+	})
+	return h.Events()
+}
+
+func (h *Harness) countActions(verb, resource string) int {
+	count := 0
+	for _, action := range h.Client.Actions() {
+		if action.GetVerb() == verb && action.GetResource().Resource == resource {
+			count++
+		}
+	}
+	return count
+}
+
+func (h *Harness) waitFor(timeout time.Duration, cond func() bool) error {
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out after %v", timeout)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return nil
+}
+
+// NewPod builds a running pod with the container statuses. Init and ephemeral containers are added with
+// InitContainers and EphemeralContainers.
+// The UID is derived from the namespace and name, so pods built with the same names are versions of one pod.
+func NewPod(namespace, name string, containerStatuses ...v1.ContainerStatus) *v1.Pod {
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:         namespace,
+			Name:              name,
+			UID:               types.UID(namespace + "/" + name),
+			CreationTimestamp: metav1.NewTime(time.Now().Add(-time.Hour)),
+		},
+		Status: v1.PodStatus{
+			Phase:             v1.PodRunning,
+			ContainerStatuses: containerStatuses,
+		},
+	}
+	for _, containerStatus := range containerStatuses {
+		pod.Spec.Containers = append(pod.Spec.Containers, v1.Container{Name: containerStatus.Name, Image: containerStatus.Image})
+	}
+	return pod
+}
+
+// InitContainers adds init container statuses to the pod and returns it.
+func InitContainers(pod *v1.Pod, containerStatuses ...v1.ContainerStatus) *v1.Pod {
+	pod.Status.InitContainerStatuses = append(pod.Status.InitContainerStatuses, containerStatuses...)
+	for _, containerStatus := range containerStatuses {
+		pod.Spec.InitContainers = append(pod.Spec.InitContainers, v1.Container{Name: containerStatus.Name, Image: containerStatus.Image})
+	}
+	return pod
+}
+
+// EphemeralContainers adds ephemeral container statuses to the pod and returns it.
+func EphemeralContainers(pod *v1.Pod, containerStatuses ...v1.ContainerStatus) *v1.Pod {
+	pod.Status.EphemeralContainerStatuses = append(pod.Status.EphemeralContainerStatuses, containerStatuses...)
+	for _, containerStatus := range containerStatuses {
+		pod.Spec.EphemeralContainers = append(pod.Spec.EphemeralContainers, v1.EphemeralContainer{
+			EphemeralContainerCommon: v1.EphemeralContainerCommon{Name: containerStatus.Name, Image: containerStatus.Image},
+		})
+	}
+	return pod
+}
+
+// Container builds the status of a running, ready container with the restart count.
+func Container(name string, restartCount int32) v1.ContainerStatus {
+	startedAt := metav1.NewTime(time.Now().Add(-time.Minute))
+	return v1.ContainerStatus{
+		Name:         name,
+		Image:        name + ":latest",
+		Ready:        true,
+		RestartCount: restartCount,
+		State:        v1.ContainerState{Running: &v1.ContainerStateRunning{StartedAt: startedAt}},
+	}
+}
+
+// Crashed sets the last termination state of the container to an exit with exitCode, as after a restart.
+func Crashed(containerStatus v1.ContainerStatus, exitCode int32) v1.ContainerStatus {
+	reason := "Error"
+	if exitCode == 0 {
+		reason = "Completed"
+	}
+	return Terminated(containerStatus, exitCode, reason)
+}
+
+// OOMKilled sets the last termination state of the container to an OOM kill.
+func OOMKilled(containerStatus v1.ContainerStatus) v1.ContainerStatus {
+	return Terminated(containerStatus, 137, "OOMKilled")
+}
+
+// Terminated sets the last termination state of the container.
+func Terminated(containerStatus v1.ContainerStatus, exitCode int32, reason string) v1.ContainerStatus {
+	finishedAt := metav1.NewTime(time.Now().Add(-time.Minute))
+	containerStatus.LastTerminationState = v1.ContainerState{Terminated: &v1.ContainerStateTerminated{
+		ExitCode:   exitCode,
+		Reason:     reason,
+		StartedAt:  metav1.NewTime(finishedAt.Add(-time.Minute)),
+		FinishedAt: finishedAt,
+	}}
+	return containerStatus
+}
+
+// CrashLoopBackOff sets the container waiting in CrashLoopBackOff.
+func CrashLoopBackOff(containerStatus v1.ContainerStatus) v1.ContainerStatus {
+	containerStatus.Ready = false
+	containerStatus.State = v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}}
+	return containerStatus
+}
@@ -0,0 +1,126 @@
+package monitor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// muteWindow is a daily time range in minutes since midnight, on the given days. A range ending
+// before its start continues past midnight into the next day.
+type muteWindow struct {
+	days       [7]bool
+	start, end int
+}
+
+type muteWindows struct {
+	windows  []muteWindow
+	location *time.Location
+}
+
+// parseMuteSchedule parses semicolon-separated windows like "Mon-Fri 02:00-03:00; Sat,Sun 22:00-06:00; 12:00-12:30".
+// Days are optional and default to every day.
+func parseMuteSchedule(spec string, location *time.Location) (muteWindows, error) {
+	schedule := muteWindows{location: location}
+	for _, item := range strings.Split(spec, ";") {
+		fields := strings.Fields(item)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) > 2 {
+			return schedule, fmt.Errorf("invalid mute window %q", item)
+		}
+
+		var window muteWindow
+		if len(fields) == 1 {
+			for i := range window.days {
+				window.days[i] = true
+			}
+		} else {
+			days, err := parseWeekdays(fields[0])
+			if err != nil {
+				return schedule, err
+			}
+			window.days = days
+		}
+
+		timeRange := fields[len(fields)-1]
+		start, end, ok := strings.Cut(timeRange, "-")
+		if !ok {
+			return schedule, fmt.Errorf("invalid time range %q", timeRange)
+		}
+		var err error
+		if window.start, err = parseClock(start); err != nil {
+			return schedule, err
+		}
+		if window.end, err = parseClock(end); err != nil {
+			return schedule, err
+		}
+		if window.start == window.end {
+			return schedule, fmt.Errorf("empty time range %q", timeRange)
+		}
+		schedule.windows = append(schedule.windows, window)
+	}
+	return schedule, nil
+}
+
+// parseWeekdays parses comma-separated days or day ranges, e.g. "Mon-Fri" or "Sat,Sun".
+func parseWeekdays(spec string) (days [7]bool, err error) {
+	for _, item := range strings.Split(spec, ",") {
+		from, to, isRange := strings.Cut(item, "-")
+		first, ok := weekdayNames[strings.ToLower(from)]
+		if !ok {
+			return days, fmt.Errorf("invalid weekday %q", from)
+		}
+		last := first
+		if isRange {
+			if last, ok = weekdayNames[strings.ToLower(to)]; !ok {
+				return days, fmt.Errorf("invalid weekday %q", to)
+			}
+		}
+		for d := first; ; d = (d + 1) % 7 {
+			days[d] = true
+			if d == last {
+				break
+			}
+		}
+	}
+	return days, nil
+}
+
+// parseClock parses HH:MM into minutes since midnight. 24:00 is allowed as the end of a day.
+func parseClock(s string) (int, error) {
+	hours, minutes, ok := strings.Cut(s, ":")
+	h, err1 := strconv.Atoi(hours)
+	m, err2 := strconv.Atoi(minutes)
+	if !ok || err1 != nil || err2 != nil || h < 0 || m < 0 || m > 59 || h*60+m > 24*60 {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return h*60 + m, nil
+}
+
+// muted reports whether t falls into one of the windows.
+func (s muteWindows) muted(t time.Time) bool {
+	if len(s.windows) == 0 {
+		return false
+	}
+	t = t.In(s.location)
+	day := t.Weekday()
+	minute := t.Hour()*60 + t.Minute()
+	for _, w := range s.windows {
+		if w.start < w.end {
+			if w.days[day] && minute >= w.start && minute < w.end {
+				return true
+			}
+		} else if (w.days[day] && minute >= w.start) || (w.days[(day+6)%7] && minute < w.end) {
+			return true
+		}
+	}
+	return false
+}
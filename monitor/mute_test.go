@@ -0,0 +1,105 @@
+package monitor
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestMuteSchedule(t *testing.T) {
+	schedule, err := parseMuteSchedule("Mon-Fri 02:00-03:00; Sat,Sun 22:00-06:00; 12:00-12:30", time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 2021-05-03 is a Monday
+	at := func(day int, clock string) time.Time {
+		c, _ := time.Parse("15:04", clock)
+		return time.Date(2021, 5, day, c.Hour(), c.Minute(), 0, 0, time.UTC)
+	}
+	for _, tc := range []struct {
+		day      int
+		clock    string
+		expected bool
+	}{
+		{4, "02:00", true},
+		{4, "02:59", true},
+		{4, "03:00", false},
+		{4, "01:59", false},
+		{8, "02:30", false},
+		{8, "23:00", true},
+		{9, "05:59", true},
+		// the Sunday window continues into Monday
+		{3, "05:00", true},
+		{3, "06:00", false},
+		{7, "23:00", false},
+		{5, "12:15", true},
+		{5, "12:30", false},
+	} {
+		now := at(tc.day, tc.clock)
+		if muted := schedule.muted(now); muted != tc.expected {
+			t.Errorf("muted at %s = %v, want %v", now.Format("Mon 15:04"), muted, tc.expected)
+		}
+	}
+
+	if (muteWindows{}).muted(time.Now()) {
+		t.Error("muted without a schedule")
+	}
+
+	// evaluated in the schedule's time zone
+	schedule, err = parseMuteSchedule("02:00-03:00", time.FixedZone("UTC+3", 3*60*60))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !schedule.muted(at(4, "23:30")) || schedule.muted(at(4, "02:30")) {
+		t.Error("schedule is not evaluated in its time zone")
+	}
+}
+
+func TestParseMuteScheduleErrors(t *testing.T) {
+	for _, spec := range []string{
+		"Mon-Fri",
+		"02:00",
+		"Foo 02:00-03:00",
+		"Mon-Foo 02:00-03:00",
+		"25:00-26:00",
+		"02:60-03:00",
+		"02:00-02:00",
+		"Mon 02:00-03:00 daily",
+	} {
+		if _, err := parseMuteSchedule(spec, time.UTC); err == nil {
+			t.Errorf("schedule %q parsed, want an error", spec)
+		}
+	}
+	opts := DefaultOptions()
+	opts.MuteSchedule = "02:00"
+	if _, err := New(fake.NewSimpleClientset(), opts); err == nil || !strings.Contains(err.Error(), "mute") {
+		t.Errorf("error = %v, want invalid mute schedule", err)
+	}
+}
+
+func TestMutedRestartIsCounted(t *testing.T) {
+	opts := DefaultOptions()
+	opts.MuteSchedule = "00:00-24:00"
+	m, client := newTestMonitor(t, opts)
+
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "muted", Name: "web", UID: "muted"}}
+	containerStatus := &v1.ContainerStatus{
+		Name:                 "app",
+		RestartCount:         1,
+		LastTerminationState: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{ExitCode: 1, Reason: "Error"}},
+	}
+	m.handleContainerRestart(context.Background(), pod, regularContainer, containerStatus, 1, true, false)
+
+	if actual := testutil.ToFloat64(containerRestartsTotal.WithLabelValues("muted", "web", "app", "Error", "false")); actual != 1 {
+		t.Errorf("muted restarts = %v, want 1", actual)
+	}
+	if events := listEvents(t, client); len(events) != 0 {
+		t.Errorf("%d events, want 0", len(events))
+	}
+}
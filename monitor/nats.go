@@ -0,0 +1,58 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// the client buffers messages published while disconnected up to this size and drops newer ones
+const natsReconnectBufSize = 8 * 1024 * 1024
+
+// NATSSink publishes restarts as JSON messages to a NATS subject. Publishing is asynchronous:
+// the client buffers messages while reconnecting.
+type NATSSink struct {
+	conn    *nats.Conn
+	subject string
+}
+
+func NewNATSSink(url, subject string) (*NATSSink, error) {
+	conn, err := nats.Connect(url,
+		nats.Name("kube-restart-monitor"),
+		nats.RetryOnFailedConnect(true),
+		nats.MaxReconnects(-1),
+		nats.ReconnectWait(2*time.Second),
+		nats.ReconnectBufSize(natsReconnectBufSize),
+		nats.DisconnectErrHandler(func(conn *nats.Conn, err error) {
+			// err is nil if the connection is closed
+			if err != nil {
+				slog.Warn("Disconnected from NATS", "err", err)
+			}
+		}),
+		nats.ReconnectHandler(func(conn *nats.Conn) {
+			slog.Info("Reconnected to NATS", "url", redactURL(conn.ConnectedUrl()))
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &NATSSink{conn: conn, subject: subject}, nil
+}
+
+func (s *NATSSink) Notify(ctx context.Context, info *RestartInfo) error {
+	body, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	return s.conn.Publish(s.subject, body)
+}
+
+// Close flushes buffered messages and closes the connection.
+func (s *NATSSink) Close() {
+	if err := s.conn.Drain(); err != nil {
+		s.conn.Close()
+	}
+}
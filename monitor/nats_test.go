@@ -0,0 +1,123 @@
+package monitor
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type natsMessage struct {
+	subject string
+	payload []byte
+}
+
+// fakeNATSServer speaks enough of the NATS client protocol to receive published messages.
+type fakeNATSServer struct {
+	listener net.Listener
+
+	mu       sync.Mutex
+	messages []natsMessage
+}
+
+func newFakeNATSServer(t *testing.T) *fakeNATSServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeNATSServer{listener: listener}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeNATSServer) url() string {
+	return "nats://" + s.listener.Addr().String()
+}
+
+func (s *fakeNATSServer) serve(conn net.Conn) {
+	defer conn.Close()
+	port := s.listener.Addr().(*net.TCPAddr).Port
+	fmt.Fprintf(conn, "INFO {\"server_id\":\"test\",\"version\":\"2.2.0\",\"host\":\"127.0.0.1\",\"port\":%d,\"max_payload\":1048576,\"proto\":1}\r\n", port)
+	r := bufio.NewReader(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch strings.ToUpper(fields[0]) {
+		case "PING":
+			io.WriteString(conn, "PONG\r\n")
+		case "PUB":
+			size, err := strconv.Atoi(fields[len(fields)-1])
+			if err != nil {
+				return
+			}
+			payload := make([]byte, size+2)
+			if _, err := io.ReadFull(r, payload); err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.messages = append(s.messages, natsMessage{subject: fields[1], payload: payload[:size]})
+			s.mu.Unlock()
+		}
+	}
+}
+
+func (s *fakeNATSServer) received() []natsMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]natsMessage(nil), s.messages...)
+}
+
+func TestNATSSinkPublishes(t *testing.T) {
+	server := newFakeNATSServer(t)
+	s, err := NewNATSSink(server.url(), "restarts.default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := newTestRestartInfo()
+	if err := s.Notify(context.Background(), info); err != nil {
+		t.Fatal(err)
+	}
+	// flushes the published message
+	s.Close()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(server.received()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	messages := server.received()
+	if len(messages) != 1 {
+		t.Fatalf("%d messages, want 1", len(messages))
+	}
+	if messages[0].subject != "restarts.default" {
+		t.Errorf("subject %q, want restarts.default", messages[0].subject)
+	}
+	expected, err := json.Marshal(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(messages[0].payload) != string(expected) {
+		t.Errorf("payload %s, want %s", messages[0].payload, expected)
+	}
+}
@@ -0,0 +1,102 @@
+package monitor
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const nodeConditionsTTL = time.Minute
+
+type nodeConditionsEntry struct {
+	conditions []string
+	fetched    time.Time
+}
+
+// nodeConditionsCache keeps active problem conditions of nodes for nodeConditionsTTL.
+// Nothing is fetched unless enabled (-include-node-conditions).
+type nodeConditionsCache struct {
+	enabled bool
+	client  kubernetes.Interface
+	// of api calls
+	timeout time.Duration
+
+	sync.Mutex
+	entries map[string]*nodeConditionsEntry
+	// closed when the node is fetched, so that concurrent gets of the node wait for one api call
+	fetching map[string]chan struct{}
+}
+
+// get returns the active pressure conditions of the node (and NotReady), best effort.
+func (c *nodeConditionsCache) get(nodeName string) []string {
+	if !c.enabled || nodeName == "" {
+		return nil
+	}
+
+	c.Lock()
+	if entry, ok := c.entries[nodeName]; ok && time.Since(entry.fetched) < nodeConditionsTTL {
+		c.Unlock()
+		return entry.conditions
+	}
+	if done, ok := c.fetching[nodeName]; ok {
+		c.Unlock()
+		<-done
+		c.Lock()
+		defer c.Unlock()
+		if entry, ok := c.entries[nodeName]; ok {
+			return entry.conditions
+		}
+		return nil
+	}
+	for name, entry := range c.entries {
+		if time.Since(entry.fetched) >= nodeConditionsTTL {
+			delete(c.entries, name)
+		}
+	}
+	done := make(chan struct{})
+	c.fetching[nodeName] = done
+	c.Unlock()
+
+	// the lock is not held during the api call, which would block the gets of other nodes
+	conditions, err := c.fetch(nodeName)
+
+	c.Lock()
+	defer c.Unlock()
+	delete(c.fetching, nodeName)
+	close(done)
+	if err != nil {
+		slog.Warn("Unable to get node", "node", nodeName, "err", err)
+		return nil
+	}
+	c.entries[nodeName] = &nodeConditionsEntry{conditions: conditions, fetched: time.Now()}
+	return conditions
+}
+
+func (c *nodeConditionsCache) fetch(nodeName string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	node, err := c.client.CoreV1().Nodes().Get(ctx, nodeName, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	var conditions []string
+	for _, condition := range node.Status.Conditions {
+		switch condition.Type {
+		case v1.NodeReady:
+			if condition.Status != v1.ConditionTrue {
+				conditions = append(conditions, "NotReady")
+			}
+		case v1.NodeMemoryPressure, v1.NodeDiskPressure, v1.NodePIDPressure, v1.NodeNetworkUnavailable:
+			if condition.Status == v1.ConditionTrue {
+				conditions = append(conditions, string(condition.Type))
+			}
+		}
+	}
+	return conditions, nil
+}
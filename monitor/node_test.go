@@ -0,0 +1,97 @@
+package monitor
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func newTestNode(name string, conditions ...v1.NodeCondition) *v1.Node {
+	return &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}, Status: v1.NodeStatus{Conditions: conditions}}
+}
+
+func TestMessageIncludesNode(t *testing.T) {
+	opts := DefaultOptions()
+	opts.IncludeNodeConditions = true
+	client := fake.NewSimpleClientset(newTestNode("node-1",
+		v1.NodeCondition{Type: v1.NodeReady, Status: v1.ConditionTrue},
+		v1.NodeCondition{Type: v1.NodeMemoryPressure, Status: v1.ConditionTrue},
+		v1.NodeCondition{Type: v1.NodeDiskPressure, Status: v1.ConditionFalse},
+	))
+	m, err := New(client, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"}, Spec: v1.PodSpec{NodeName: "node-1"}}
+	msg := m.formatMessage(pod, &v1.ContainerStatus{Name: "app"})
+	if !strings.Contains(msg, "\nNode: node-1 (MemoryPressure).") {
+		t.Errorf("message %q has no node with its conditions", msg)
+	}
+}
+
+func TestNodeConditionsCacheFetchesUnlocked(t *testing.T) {
+	client := fake.NewSimpleClientset(newTestNode("slow"))
+	release := make(chan struct{})
+	var mu sync.Mutex
+	gets := map[string]int{}
+	client.PrependReactor("get", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		name := action.(k8stesting.GetAction).GetName()
+		mu.Lock()
+		gets[name]++
+		mu.Unlock()
+		if name == "slow" {
+			<-release
+		}
+		return false, nil, nil
+	})
+	c := &nodeConditionsCache{
+		enabled:  true,
+		client:   client,
+		timeout:  time.Minute,
+		entries:  make(map[string]*nodeConditionsEntry),
+		fetching: make(map[string]chan struct{}),
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.get("slow")
+		}()
+	}
+
+	// the fake clientset serializes api calls, so check the lock directly
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		fetching := gets["slow"] > 0
+		mu.Unlock()
+		if fetching {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("node is not fetched")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !c.TryLock() {
+		t.Error("lock is held during the api call")
+	} else {
+		c.Unlock()
+	}
+
+	close(release)
+	wg.Wait()
+	if gets["slow"] != 1 {
+		t.Errorf("node fetched %d times by concurrent gets, want 1", gets["slow"])
+	}
+}
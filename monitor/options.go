@@ -0,0 +1,171 @@
+package monitor
+
+import (
+	"time"
+)
+
+// Options configures a Monitor. Fields correspond to the command line flags of kube-restart-monitor
+// (see its -h), lists are comma-separated.
+type Options struct {
+	// reported in events, notifications and the gRPC user agent
+	Version string
+
+	// pods to watch
+	Namespaces        string
+	Namespace         string
+	ExcludeNamespaces string
+	LabelSelector     string
+	PodNameRegexp     string
+	NodeName          string
+	ListFromCache     bool
+	MinWatchTimeout   time.Duration
+	WatchJitterFactor float64
+	ResyncPeriod      time.Duration
+	TerminalPodGrace  time.Duration
+	ChannelBuffer     int
+	APITimeout        time.Duration
+	StateFile         string
+	HealthStaleness   time.Duration
+
+	// leader election
+	EnableLeaderElection    bool
+	LeaderElectionNamespace string
+
+	// which restarts to report
+	IgnoreAnnotation     string
+	OptIn                bool
+	OptInAnnotation      string
+	IncludeContainers    string
+	ExcludeContainers    string
+	IgnoreExitCodes      string
+	CrashLoopOnly        bool
+	MinRestartCount      int
+	StartupGrace         time.Duration
+	SampleRate           int
+	Cooldown             time.Duration
+	NamespaceRateLimit   float64
+	NamespaceRateBurst   int
+	StormThreshold       int
+	StormWindow          time.Duration
+	MuteSchedule         string
+	MuteTimezone         string
+	RecoveryAfter        time.Duration
+	WatchImagePullErrors bool
+
+	// events
+	EventsAPI            string
+	Target               string
+	EventSourceComponent string
+	EventSourceHost      string
+	EventReason          string
+	EventAction          string
+	OOMEventReason       string
+	InitEventReason      string
+	EphemeralEventReason string
+	RecoveryEventReason  string
+	ImagePullEventReason string
+	EventTypeMap         string
+	SelfEvent            bool
+	PropagateLabels      string
+
+	// messages
+	MessageTemplate       string
+	MaxMessageBytes       int
+	IncludeLogs           bool
+	LogTailLines          int64
+	IncludeNodeConditions bool
+
+	// sinks
+	Workers              int
+	SinkTimeout          time.Duration
+	DryRun               bool
+	BatchSize            int
+	FlushInterval        time.Duration
+	DeadletterDir        string
+	DeadletterReplay     bool
+	WebhookURL           string
+	WebhookSecret        string
+	WebhookClientCert    string
+	WebhookClientKey     string
+	WebhookCACert        string
+	WebhookTimeout       time.Duration
+	SlackWebhookURL      string
+	TeamsWebhookURL      string
+	DiscordWebhookURL    string
+	GoogleChatWebhookURL string
+	PagerDutyRoutingKey  string
+	AlertmanagerURL      string
+	Output               string
+	OutputFile           string
+	OutputFileMaxSize    int64
+	OutputFileMaxBackups int
+	SyslogAddr           string
+	SyslogProtocol       string
+	NATSURL              string
+	NATSSubject          string
+	GRPCSinkAddr         string
+	GRPCSinkTLS          bool
+	RedisAddr            string
+	RedisStream          string
+	RedisStreamMaxLen    int64
+	KafkaBrokers         string
+	KafkaTopic           string
+	KafkaTLS             bool
+	KafkaSASLMechanism   string
+	KafkaSASLUsername    string
+	KafkaSASLPassword    string
+}
+
+// DefaultOptions returns the defaults of the command line flags.
+func DefaultOptions() Options {
+	return Options{
+		Version: "dev",
+
+		ExcludeNamespaces: "kube-system,kube-public,kube-node-lease",
+		ListFromCache:     true,
+		MinWatchTimeout:   5 * time.Minute,
+		WatchJitterFactor: 1,
+		TerminalPodGrace:  10 * time.Minute,
+		ChannelBuffer:     128,
+		APITimeout:        30 * time.Second,
+		HealthStaleness:   15 * time.Minute,
+
+		LeaderElectionNamespace: "default",
+
+		IgnoreAnnotation:   "restart-monitor.smpio/ignore",
+		OptInAnnotation:    "restart-monitor.smpio/enabled",
+		IgnoreExitCodes:    "0",
+		MinRestartCount:    1,
+		SampleRate:         1,
+		Cooldown:           5 * time.Minute,
+		NamespaceRateBurst: 10,
+		StormWindow:        time.Minute,
+		MuteTimezone:       "UTC",
+
+		EventsAPI:            eventsAPICore,
+		Target:               eventTargetPod,
+		EventSourceComponent: "kube-restart-monitor",
+		EventReason:          "ContainerRestart",
+		EventAction:          "Restarted",
+		OOMEventReason:       "ContainerOOMKilled",
+		InitEventReason:      "InitContainerRestart",
+		EphemeralEventReason: "EphemeralContainerRestart",
+		RecoveryEventReason:  "ContainerRecovered",
+		ImagePullEventReason: "ContainerImagePullError",
+
+		MaxMessageBytes: 256,
+		LogTailLines:    10,
+
+		Workers:              4,
+		SinkTimeout:          time.Minute,
+		FlushInterval:        5 * time.Second,
+		WebhookTimeout:       10 * time.Second,
+		OutputFileMaxSize:    100 * 1024 * 1024,
+		OutputFileMaxBackups: 3,
+		SyslogProtocol:       "udp",
+		NATSSubject:          "kube-restart-monitor.restarts",
+		RedisStream:          "kube-restart-monitor",
+		RedisStreamMaxLen:    10000,
+		KafkaTopic:           "kube-restart-monitor",
+	}
+}
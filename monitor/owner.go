@@ -0,0 +1,108 @@
+package monitor
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	ownerCacheSize = 10000
+	maxOwnerDepth  = 5
+)
+
+type podOwner struct {
+	APIVersion string
+	Kind       string
+	Name       string
+	UID        types.UID
+}
+
+func (o podOwner) String() string {
+	if o.Kind == "" {
+		return ""
+	}
+	return o.Kind + "/" + o.Name
+}
+
+// ownerResolver finds the top-level owner of pods, e.g. the Deployment of a ReplicaSet or the CronJob of a Job.
+// Owners of immutable ownership links are cached by UID.
+type ownerResolver struct {
+	client kubernetes.Interface
+	// of api calls
+	timeout time.Duration
+
+	sync.Mutex
+	parents map[types.UID]*metav1.OwnerReference
+}
+
+// ownerOf returns the top-level owner of the pod, or the zero podOwner for bare pods.
+func (r *ownerResolver) ownerOf(pod *v1.Pod) podOwner {
+	ref := metav1.GetControllerOf(pod)
+	if ref == nil {
+		if len(pod.OwnerReferences) == 0 {
+			return podOwner{}
+		}
+		ref = &pod.OwnerReferences[0]
+	}
+	for i := 0; i < maxOwnerDepth; i++ {
+		parent := r.parentOf(pod.Namespace, ref)
+		if parent == nil {
+			break
+		}
+		ref = parent
+	}
+	return podOwner{APIVersion: ref.APIVersion, Kind: ref.Kind, Name: ref.Name, UID: ref.UID}
+}
+
+func (r *ownerResolver) parentOf(namespace string, ref *metav1.OwnerReference) *metav1.OwnerReference {
+	if ref.Kind != "ReplicaSet" && ref.Kind != "Job" {
+		return nil
+	}
+
+	r.Lock()
+	parent, ok := r.parents[ref.UID]
+	r.Unlock()
+	if ok {
+		return parent
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	var meta metav1.Object
+	var err error
+	switch ref.Kind {
+	case "ReplicaSet":
+		meta, err = r.client.AppsV1().ReplicaSets(namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+	case "Job":
+		meta, err = r.client.BatchV1().Jobs(namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+	}
+	switch {
+	case err == nil:
+		if meta.GetUID() == ref.UID {
+			parent = metav1.GetControllerOfNoCopy(meta)
+		}
+	case apierrs.IsForbidden(err) || apierrs.IsNotFound(err):
+		// the direct pod owner is still useful, do not retry
+		slog.Debug("Unable to get pod owner", "kind", ref.Kind, "namespace", namespace, "name", ref.Name, "err", err)
+	default:
+		slog.Debug("Unable to get pod owner", "kind", ref.Kind, "namespace", namespace, "name", ref.Name, "err", err)
+		return nil
+	}
+
+	r.Lock()
+	if len(r.parents) >= ownerCacheSize {
+		r.parents = make(map[types.UID]*metav1.OwnerReference)
+	}
+	r.parents[ref.UID] = parent
+	r.Unlock()
+	return parent
+}
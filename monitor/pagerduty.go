@@ -0,0 +1,86 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const pagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+type pagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"`
+	DedupKey    string            `json:"dedup_key"`
+	Payload     *pagerDutyPayload `json:"payload,omitempty"`
+}
+
+type pagerDutyPayload struct {
+	Summary       string       `json:"summary"`
+	Source        string       `json:"source"`
+	Severity      string       `json:"severity"`
+	Timestamp     string       `json:"timestamp,omitempty"`
+	Component     string       `json:"component"`
+	Group         string       `json:"group"`
+	Class         string       `json:"class"`
+	CustomDetails *RestartInfo `json:"custom_details"`
+}
+
+// PagerDutySink triggers PagerDuty incidents through the Events API v2. Restarts of the same container
+// are deduplicated into one incident, which is resolved when the container recovers.
+type PagerDutySink struct {
+	url        string
+	routingKey string
+	client     *http.Client
+}
+
+func NewPagerDutySink(routingKey string, timeout time.Duration) *PagerDutySink {
+	return &PagerDutySink{
+		url:        pagerDutyEventsURL,
+		routingKey: routingKey,
+		client:     &http.Client{Timeout: timeout},
+	}
+}
+
+func (s *PagerDutySink) Notify(ctx context.Context, info *RestartInfo) error {
+	event := &pagerDutyEvent{
+		RoutingKey:  s.routingKey,
+		EventAction: "trigger",
+		DedupKey:    pagerDutyDedupKey(info),
+		Payload: &pagerDutyPayload{
+			Summary:       fmt.Sprintf("Container %s in pod %s/%s restarted (%s)", info.Container, info.Namespace, info.PodName, info.EventReason),
+			Source:        info.Namespace + "/" + info.PodName,
+			Severity:      string(restartSeverity(info)),
+			Component:     info.Container,
+			Group:         info.Namespace,
+			Class:         info.EventReason,
+			CustomDetails: info,
+		},
+	}
+	if !info.Timestamp.IsZero() {
+		event.Payload.Timestamp = info.Timestamp.UTC().Format(time.RFC3339)
+	}
+	return s.send(ctx, event)
+}
+
+func (s *PagerDutySink) NotifyRecovery(ctx context.Context, info *RestartInfo) error {
+	return s.send(ctx, &pagerDutyEvent{
+		RoutingKey:  s.routingKey,
+		EventAction: "resolve",
+		DedupKey:    pagerDutyDedupKey(info),
+	})
+}
+
+func (s *PagerDutySink) send(ctx context.Context, event *pagerDutyEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return postJSON(ctx, s.client, s.url, body)
+}
+
+func pagerDutyDedupKey(info *RestartInfo) string {
+	return info.Namespace + "/" + info.PodName + "/" + info.Container
+}
@@ -0,0 +1,84 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPagerDutySinkTriggerAndResolve(t *testing.T) {
+	receiver := newTestReceiver(t, http.StatusAccepted)
+	s := NewPagerDutySink("routing-key", time.Second)
+	s.url = receiver.URL
+	info := newTestRestartInfo()
+	if err := s.Notify(context.Background(), info); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.NotifyRecovery(context.Background(), info); err != nil {
+		t.Fatal(err)
+	}
+
+	_, bodies := receiver.received()
+	if len(bodies) != 2 {
+		t.Fatalf("%d requests, want the trigger and the resolve", len(bodies))
+	}
+	var trigger, resolve map[string]interface{}
+	if err := json.Unmarshal(bodies[0], &trigger); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(bodies[1], &resolve); err != nil {
+		t.Fatal(err)
+	}
+
+	payload, _ := trigger["payload"].(map[string]interface{})
+	details, _ := payload["custom_details"].(map[string]interface{})
+	delete(payload, "custom_details")
+	if trigger["routing_key"] != "routing-key" || trigger["event_action"] != "trigger" || trigger["dedup_key"] != "default/web/app" {
+		t.Errorf("trigger event %v, want a trigger of default/web/app with the routing key", trigger)
+	}
+	expectedPayload := map[string]interface{}{
+		"summary":   "Container app in pod default/web restarted (ContainerOOMKilled)",
+		"source":    "default/web",
+		"severity":  "critical",
+		"timestamp": "2021-05-01T12:00:00Z",
+		"component": "app",
+		"group":     "default",
+		"class":     "ContainerOOMKilled",
+	}
+	if !reflect.DeepEqual(payload, expectedPayload) {
+		t.Errorf("trigger payload %v, want %v", payload, expectedPayload)
+	}
+	if details["namespace"] != "default" || details["pod"] != "web" || details["container"] != "app" {
+		t.Errorf("custom details %v, want the restart info", details)
+	}
+
+	expectedResolve := map[string]interface{}{
+		"routing_key":  "routing-key",
+		"event_action": "resolve",
+		"dedup_key":    "default/web/app",
+	}
+	if !reflect.DeepEqual(resolve, expectedResolve) {
+		t.Errorf("resolve event %v, want %v", resolve, expectedResolve)
+	}
+}
+
+func TestRestartSeverity(t *testing.T) {
+	for _, tc := range []struct {
+		reason   string
+		exitCode int32
+		expected severity
+	}{
+		{oomKilledReason, 137, severityCritical},
+		{"Error", 1, severityError},
+		{"Completed", 0, severityWarning},
+		{"", 0, severityWarning},
+	} {
+		info := &RestartInfo{TerminationReason: tc.reason, ExitCode: tc.exitCode}
+		if actual := restartSeverity(info); actual != tc.expected {
+			t.Errorf("severity of %s with exit code %d = %s, want %s", tc.reason, tc.exitCode, actual, tc.expected)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package monitor
+
+import (
+	"golang.org/x/time/rate"
+)
+
+// namespaceLimiter caps reported restarts per namespace with a token bucket each,
+// on top of the per-container cooldown. It is only used from the main loop.
+type namespaceLimiter struct {
+	rate     float64
+	burst    int
+	limiters map[string]*rate.Limiter
+}
+
+func (l *namespaceLimiter) allow(namespace string) bool {
+	if l.rate <= 0 {
+		return true
+	}
+	limiter, ok := l.limiters[namespace]
+	if !ok {
+		limiter = rate.NewLimiter(rate.Limit(l.rate), l.burst)
+		l.limiters[namespace] = limiter
+	}
+	if !limiter.Allow() {
+		rateLimitedTotal.WithLabelValues(namespace).Inc()
+		return false
+	}
+	return true
+}
@@ -0,0 +1,35 @@
+package monitor
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"golang.org/x/time/rate"
+)
+
+func TestNamespaceLimiter(t *testing.T) {
+	l := &namespaceLimiter{rate: 0.001, burst: 3, limiters: make(map[string]*rate.Limiter)}
+	allowed := 0
+	for i := 0; i < 10; i++ {
+		if l.allow("rate-limited") {
+			allowed++
+		}
+	}
+	if allowed != 3 {
+		t.Errorf("%d of a burst of 10 restarts allowed, want 3", allowed)
+	}
+	if dropped := testutil.ToFloat64(rateLimitedTotal.WithLabelValues("rate-limited")); dropped != 7 {
+		t.Errorf("%v restarts counted as rate limited, want 7", dropped)
+	}
+	// other namespaces have buckets of their own
+	if !l.allow("rate-limited-other") {
+		t.Error("restart in another namespace is rate limited")
+	}
+
+	disabled := &namespaceLimiter{limiters: make(map[string]*rate.Limiter)}
+	for i := 0; i < 100; i++ {
+		if !disabled.allow("rate-limited") {
+			t.Fatal("restart rate limited without a rate")
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package monitor
+
+import (
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// readinessTracker remembers whether each container was seen Ready since its last observed restart.
+// Containers are tracked from their first restart on, as their earlier history is unknown.
+// It is only used from the main loop.
+type readinessTracker struct {
+	ready map[containerKey]bool
+}
+
+// update records the container status and, if it restarted since the previous one, reports whether
+// the container never became Ready in between.
+func (t *readinessTracker) update(pod *v1.Pod, containerStatus *v1.ContainerStatus, restarted bool) (neverReady bool) {
+	key := containerKey{pod.UID, containerStatus.Name}
+	wasReady, tracked := t.ready[key]
+	if restarted {
+		t.ready[key] = containerStatus.Ready
+		return tracked && !wasReady
+	}
+	if tracked && containerStatus.Ready {
+		t.ready[key] = true
+	}
+	return false
+}
+
+func (t *readinessTracker) forget(podUID types.UID) {
+	for key := range t.ready {
+		if key.podUID == podUID {
+			delete(t.ready, key)
+		}
+	}
+}
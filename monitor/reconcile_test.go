@@ -0,0 +1,67 @@
+package monitor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestReconcilePodsEvictsMissedDeletes(t *testing.T) {
+	opts := DefaultOptions()
+	m, client := newTestMonitor(t, opts)
+	for _, pod := range []*v1.Pod{
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web", UID: "web"}, Status: v1.PodStatus{Phase: v1.PodRunning}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "job", UID: "job"}, Status: v1.PodStatus{Phase: v1.PodSucceeded}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "batch", UID: "batch"}, Status: v1.PodStatus{Phase: v1.PodFailed}},
+	} {
+		if err := client.Tracker().Add(pod); err != nil {
+			t.Fatal(err)
+		}
+	}
+	factory := informers.NewSharedInformerFactory(client, 0)
+	informer := factory.Core().V1().Pods().Informer()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	factory.Start(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), informer.HasSynced) {
+		t.Fatal("informer cache not synced")
+	}
+
+	pods := map[types.UID]map[string]int32{
+		"web":   {"app": 1},
+		"job":   {"app": 0},
+		"batch": {"app": 0},
+		// deleted while the watch was disconnected
+		"gone": {"app": 2},
+	}
+	terminalSince := map[types.UID]time.Time{
+		"job":   time.Now().Add(-opts.TerminalPodGrace - time.Minute),
+		"batch": time.Now(),
+		"gone":  time.Now(),
+	}
+	m.reconcilePods(pods, terminalSince, map[string]cache.SharedIndexInformer{"": informer})
+
+	for _, uid := range []types.UID{"web", "batch"} {
+		if _, ok := pods[uid]; !ok {
+			t.Errorf("pod %s is evicted", uid)
+		}
+	}
+	for _, uid := range []types.UID{"job", "gone"} {
+		if _, ok := pods[uid]; ok {
+			t.Errorf("pod %s is not evicted", uid)
+		}
+		if _, ok := terminalSince[uid]; ok {
+			t.Errorf("terminal time of pod %s is kept", uid)
+		}
+	}
+	if size := testutil.ToFloat64(trackedPods); size != 2 {
+		t.Errorf("tracked pods gauge = %v, want 2", size)
+	}
+}
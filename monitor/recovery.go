@@ -0,0 +1,141 @@
+package monitor
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const recoveredEventAction = "Recovered"
+
+type recoveryEntry struct {
+	restartCount int32
+	pod          *v1.Pod
+	timer        *time.Timer
+	// incremented on every timer change, so that a stale timer does not report recovery
+	generation int
+}
+
+// recoveryTracker emits a Normal event for containers that were reported as restarted,
+// once they stay ready without further restarts for the recovery period.
+type recoveryTracker struct {
+	sync.Mutex
+	period  time.Duration
+	entries map[containerKey]*recoveryEntry
+	// reports the recovery of a container
+	recovered func(pod *v1.Pod, containerStatus *v1.ContainerStatus)
+}
+
+// track starts watching a container for which a restart was reported.
+func (t *recoveryTracker) track(pod *v1.Pod, containerStatus *v1.ContainerStatus) {
+	if t.period <= 0 {
+		return
+	}
+
+	key := containerKey{pod.UID, containerStatus.Name}
+
+	t.Lock()
+	if entry, ok := t.entries[key]; ok {
+		t.stopTimer(entry)
+	}
+	t.entries[key] = &recoveryEntry{restartCount: containerStatus.RestartCount}
+	t.Unlock()
+
+	t.update(pod, containerStatus)
+}
+
+// update (re)starts the recovery timer of a tracked container while it is ready and not restarting.
+func (t *recoveryTracker) update(pod *v1.Pod, containerStatus *v1.ContainerStatus) {
+	key := containerKey{pod.UID, containerStatus.Name}
+
+	t.Lock()
+	defer t.Unlock()
+
+	entry, ok := t.entries[key]
+	if !ok {
+		return
+	}
+	entry.pod = pod
+	if containerStatus.RestartCount != entry.restartCount || !containerStatus.Ready {
+		entry.restartCount = containerStatus.RestartCount
+		t.stopTimer(entry)
+		return
+	}
+	if entry.timer == nil {
+		entry.generation++
+		generation := entry.generation
+		entry.timer = time.AfterFunc(t.period, func() {
+			t.recover(key, generation)
+		})
+	}
+}
+
+func (t *recoveryTracker) stopTimer(entry *recoveryEntry) {
+	if entry.timer != nil {
+		entry.timer.Stop()
+		entry.timer = nil
+		entry.generation++
+	}
+}
+
+func (t *recoveryTracker) recover(key containerKey, generation int) {
+	t.Lock()
+	entry, ok := t.entries[key]
+	if !ok || entry.generation != generation {
+		t.Unlock()
+		return
+	}
+	delete(t.entries, key)
+	t.Unlock()
+
+	t.recovered(entry.pod, findContainerStatus(entry.pod, key.container))
+}
+
+func (m *Monitor) reportRecovery(pod *v1.Pod, containerStatus *v1.ContainerStatus) {
+	msg := fmt.Sprintf("Container %s in pod %s/%s is ready without restarts for %v.", containerStatus.Name, pod.Namespace, pod.Name, m.recoveries.period)
+	logRestart(msg, pod, containerStatus)
+
+	info := m.newRestartInfo(pod, containerStatus, 0, m.opts.RecoveryEventReason)
+	info.EventType = v1.EventTypeNormal
+	info.EventAction = recoveredEventAction
+	info.Message = msg
+	// not muted by -mute-schedule, so that notifications sent before are resolved
+	m.sinks.dispatchRecovery(info)
+}
+
+// stop cancels the recovery timers, recoveries of the tracked containers are not reported.
+func (t *recoveryTracker) stop() {
+	t.Lock()
+	defer t.Unlock()
+
+	for key, entry := range t.entries {
+		t.stopTimer(entry)
+		delete(t.entries, key)
+	}
+}
+
+func (t *recoveryTracker) forget(podUID types.UID) {
+	t.Lock()
+	defer t.Unlock()
+
+	for key, entry := range t.entries {
+		if key.podUID == podUID {
+			t.stopTimer(entry)
+			delete(t.entries, key)
+		}
+	}
+}
+
+func findContainerStatus(pod *v1.Pod, name string) *v1.ContainerStatus {
+	for _, statuses := range [][]v1.ContainerStatus{pod.Status.ContainerStatuses, pod.Status.InitContainerStatuses, pod.Status.EphemeralContainerStatuses} {
+		for i := range statuses {
+			if statuses[i].Name == name {
+				return &statuses[i]
+			}
+		}
+	}
+	return &v1.ContainerStatus{Name: name}
+}
@@ -0,0 +1,85 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestRedisStreamSink(t *testing.T) {
+	server := miniredis.RunT(t)
+	s, err := NewRedisStreamSink(server.Addr(), "restarts", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	info := newTestRestartInfo()
+	if err := s.Notify(context.Background(), info); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := server.Stream("restarts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("%d entries, want 1", len(entries))
+	}
+	values := entryValues(entries[0])
+	if values["namespace"] != "default" || values["pod"] != "web" || values["container"] != "app" {
+		t.Errorf("entry fields = %v", values)
+	}
+	var record map[string]interface{}
+	if err := json.Unmarshal([]byte(values["data"]), &record); err != nil {
+		t.Fatalf("invalid data %q: %v", values["data"], err)
+	}
+	if record["namespace"] != "default" || record["pod"] != "web" || record["container"] != "app" || record["exitCode"] != float64(137) {
+		t.Errorf("data = %v", record)
+	}
+
+	// miniredis trims exactly, redis at most to the next macro node
+	for i := 0; i < 5; i++ {
+		info := newTestRestartInfo()
+		info.PodName = fmt.Sprintf("web-%d", i)
+		if err := s.Notify(context.Background(), info); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries, err = server.Stream("restarts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pods []string
+	for _, entry := range entries {
+		pods = append(pods, entryValues(entry)["pod"])
+	}
+	if expected := []string{"web-2", "web-3", "web-4"}; !reflect.DeepEqual(pods, expected) {
+		t.Errorf("entries of pods %v, want the latest %v", pods, expected)
+	}
+}
+
+func entryValues(entry miniredis.StreamEntry) map[string]string {
+	values := map[string]string{}
+	for i := 0; i+1 < len(entry.Values); i += 2 {
+		values[entry.Values[i]] = entry.Values[i+1]
+	}
+	return values
+}
+
+func TestRedisStreamSinkUnavailable(t *testing.T) {
+	server := miniredis.RunT(t)
+	s, err := NewRedisStreamSink("redis://"+server.Addr()+"/0", "restarts", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	server.Close()
+
+	if err := s.Notify(context.Background(), newTestRestartInfo()); err == nil {
+		t.Error("notification succeeded without a redis server")
+	}
+}
@@ -0,0 +1,409 @@
+package monitor
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"golang.org/x/time/rate"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Monitor watches pods for container restarts and reports them to the configured sinks.
+type Monitor struct {
+	opts            Options
+	client          kubernetes.Interface
+	watchNamespaces []string
+
+	labelSelector     labels.Selector
+	fieldSelector     fields.Selector
+	excludeNamespaces map[string]bool
+	// nil matches all pods
+	podNameRegexp *regexp.Regexp
+	// glob patterns of container names
+	includeContainers []string
+	excludeContainers []string
+	ignoreExitCodes   map[int32]bool
+	propagateLabels   []string
+	// termination reasons or exit codes mapped to event types by -event-type-map
+	eventTypes      map[string]string
+	messageTemplate *template.Template
+	muteSchedule    muteWindows
+	// nil without -webhook-client-cert, -webhook-client-key and -webhook-ca-cert
+	webhookTLSConfig *tls.Config
+
+	health         *healthState
+	sinks          *sinkDispatcher
+	restartWorkers *workerPool
+	eventWriter    *eventWriter
+	// the monitor's own pod, the target of events not about a particular pod.
+	// It is nil if $POD_NAMESPACE or $POD_NAME is not set.
+	selfObject runtime.Object
+
+	owners          *ownerResolver
+	nodeConditions  *nodeConditionsCache
+	cooldowns       *cooldownTracker
+	recoveries      *recoveryTracker
+	imagePullErrors *imagePullTracker
+	samples         *sampler
+	readiness       *readinessTracker
+	storms          *stormDetector
+	namespaceLimits *namespaceLimiter
+	// time of the last seen restart of each container, for restart_monitor_interval_seconds
+	lastRestartTimes map[containerKey]time.Time
+	// last processed resourceVersion of each pod, to skip stale updates
+	resourceVersions map[types.UID]string
+}
+
+// New validates the options and creates a monitor. The client is used for all api calls.
+func New(client kubernetes.Interface, opts Options) (*Monitor, error) {
+	if opts.EventSourceHost == "" {
+		opts.EventSourceHost, _ = os.Hostname()
+	}
+	m := &Monitor{
+		client:            client,
+		labelSelector:     labels.Everything(),
+		fieldSelector:     fields.Everything(),
+		excludeNamespaces: make(map[string]bool),
+		ignoreExitCodes:   make(map[int32]bool),
+		eventTypes:        make(map[string]string),
+		health: &healthState{
+			staleness:     opts.HealthStaleness,
+			lastActivity:  time.Now(),
+			readyWatchers: make(map[string]bool),
+		},
+		sinks: &sinkDispatcher{timeout: opts.SinkTimeout, dryRun: opts.DryRun, deadletterDir: opts.DeadletterDir},
+		eventWriter: &eventWriter{
+			client:    client,
+			timeout:   opts.APITimeout,
+			api:       opts.EventsAPI,
+			component: opts.EventSourceComponent,
+			host:      opts.EventSourceHost,
+			series:    make(map[eventKey]*eventSeries),
+			limiters:  make(map[v1.ObjectReference]*rate.Limiter),
+		},
+		owners: &ownerResolver{
+			client:  client,
+			timeout: opts.APITimeout,
+			parents: make(map[types.UID]*metav1.OwnerReference),
+		},
+		nodeConditions: &nodeConditionsCache{
+			enabled:  opts.IncludeNodeConditions,
+			client:   client,
+			timeout:  opts.APITimeout,
+			entries:  make(map[string]*nodeConditionsEntry),
+			fetching: make(map[string]chan struct{}),
+		},
+		cooldowns: newCooldownTracker(opts.Cooldown),
+		recoveries: &recoveryTracker{
+			period:  opts.RecoveryAfter,
+			entries: make(map[containerKey]*recoveryEntry),
+		},
+		imagePullErrors: &imagePullTracker{
+			period:  opts.Cooldown,
+			entries: make(map[containerKey]*imagePullEntry),
+		},
+		samples:   &sampler{rate: int32(opts.SampleRate), counts: make(map[containerKey]int32)},
+		readiness: &readinessTracker{ready: make(map[containerKey]bool)},
+		storms:    &stormDetector{threshold: opts.StormThreshold, window: opts.StormWindow},
+		namespaceLimits: &namespaceLimiter{
+			rate:     opts.NamespaceRateLimit,
+			burst:    opts.NamespaceRateBurst,
+			limiters: make(map[string]*rate.Limiter),
+		},
+		lastRestartTimes: make(map[containerKey]time.Time),
+		resourceVersions: make(map[types.UID]string),
+	}
+	m.recoveries.recovered = m.reportRecovery
+	m.storms.detected = m.reportStorm
+
+	var err error
+	m.labelSelector, err = labels.Parse(opts.LabelSelector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid label selector: %w", err)
+	}
+
+	if opts.PodNameRegexp != "" {
+		m.podNameRegexp, err = regexp.Compile(opts.PodNameRegexp)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pod name regexp: %w", err)
+		}
+	}
+
+	if opts.NodeName != "" {
+		m.fieldSelector = fields.OneTermEqualSelector("spec.nodeName", opts.NodeName)
+	}
+
+	if opts.Output != "" && opts.Output != outputJSON {
+		return nil, fmt.Errorf("invalid output format %q", opts.Output)
+	}
+
+	if opts.NamespaceRateLimit > 0 && opts.NamespaceRateBurst < 1 {
+		return nil, errors.New("-namespace-rate-burst must be at least 1")
+	}
+	if opts.Workers < 1 {
+		return nil, errors.New("-workers must be at least 1")
+	}
+	if opts.SampleRate < 1 {
+		return nil, errors.New("-sample-rate must be at least 1")
+	}
+	if opts.ChannelBuffer < 0 {
+		return nil, errors.New("-channel-buffer must not be negative")
+	}
+	if opts.MinWatchTimeout < time.Second {
+		return nil, errors.New("-min-watch-timeout must be at least 1s")
+	}
+	if opts.WatchJitterFactor < 0 || opts.WatchJitterFactor > maxWatchJitterFactor {
+		clamped := math.Max(0, math.Min(opts.WatchJitterFactor, maxWatchJitterFactor))
+		slog.Warn("-watch-jitter-factor out of range, clamping", "watchJitterFactor", opts.WatchJitterFactor, "clamped", clamped)
+		opts.WatchJitterFactor = clamped
+	}
+	m.opts = opts
+	if maxWatchTimeout := m.watchTimeout(1); maxWatchTimeout > opts.HealthStaleness {
+		slog.Warn("Watches may outlast -health-staleness, /healthz can fail while no pods change", "maxWatchTimeout", maxWatchTimeout, "healthStaleness", opts.HealthStaleness)
+	}
+	if opts.Target != eventTargetPod && opts.Target != eventTargetOwner {
+		return nil, fmt.Errorf("unknown event target %q, expected %q or %q", opts.Target, eventTargetPod, eventTargetOwner)
+	}
+	if opts.EventsAPI != eventsAPICore && opts.EventsAPI != eventsAPIEvents {
+		return nil, fmt.Errorf("unknown events API %q, expected %q or %q", opts.EventsAPI, eventsAPICore, eventsAPIEvents)
+	}
+	if opts.EventsAPI == eventsAPIEvents && opts.EventAction == "" {
+		return nil, errors.New("-event-action must not be empty with -events-api=events.k8s.io")
+	}
+	if opts.BatchSize > 1 && opts.FlushInterval <= 0 {
+		return nil, errors.New("-flush-interval must be positive")
+	}
+	if opts.DeadletterReplay && (opts.DeadletterDir == "" || opts.DryRun) {
+		return nil, errors.New("-deadletter-replay needs -deadletter-dir and can't be used with -dry-run")
+	}
+
+	if err := parseEventTypeMap(opts.EventTypeMap, m.eventTypes); err != nil {
+		return nil, fmt.Errorf("invalid event type map: %w", err)
+	}
+
+	muteLocation, err := time.LoadLocation(opts.MuteTimezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mute time zone: %w", err)
+	}
+	m.muteSchedule, err = parseMuteSchedule(opts.MuteSchedule, muteLocation)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mute schedule: %w", err)
+	}
+
+	m.propagateLabels = splitList(opts.PropagateLabels)
+	for _, key := range m.propagateLabels {
+		if errs := validation.IsQualifiedName(labelAnnotation(key)); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid propagated label %q: %s", key, strings.Join(errs, ", "))
+		}
+	}
+
+	m.includeContainers = splitList(opts.IncludeContainers)
+	m.excludeContainers = splitList(opts.ExcludeContainers)
+	if err := validatePatterns(append(m.includeContainers, m.excludeContainers...)); err != nil {
+		return nil, fmt.Errorf("invalid container name pattern: %w", err)
+	}
+
+	m.messageTemplate, err = parseMessageTemplate(opts.MessageTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid message template: %w", err)
+	}
+
+	if opts.WebhookURL != "" {
+		m.webhookTLSConfig, err = loadTLSConfig(opts.WebhookClientCert, opts.WebhookClientKey, opts.WebhookCACert)
+		if err != nil {
+			return nil, fmt.Errorf("invalid webhook TLS configuration: %w", err)
+		}
+	}
+
+	for _, code := range splitList(opts.IgnoreExitCodes) {
+		exitCode, err := strconv.ParseInt(code, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exit code: %w", err)
+		}
+		m.ignoreExitCodes[int32(exitCode)] = true
+	}
+
+	m.watchNamespaces = splitList(opts.Namespaces, v1.NamespaceAll)
+	if opts.Namespace != "" {
+		if opts.Namespaces != "" {
+			return nil, errors.New("-namespace and -namespaces are mutually exclusive")
+		}
+		m.watchNamespaces = []string{opts.Namespace}
+		if opts.IncludeNodeConditions {
+			slog.Warn("-include-node-conditions needs cluster-scoped get permission on nodes")
+		}
+	}
+	for _, namespace := range splitList(opts.ExcludeNamespaces) {
+		m.excludeNamespaces[namespace] = true
+	}
+	for _, namespace := range m.watchNamespaces {
+		delete(m.excludeNamespaces, namespace)
+	}
+	m.health.setWatchers(len(m.watchNamespaces))
+
+	return m, nil
+}
+
+// RegisterMetrics registers the monitor metrics with the default prometheus registry.
+func RegisterMetrics() {
+	registerMetrics()
+	startsTotal.Inc()
+}
+
+// HandleHealth serves /healthz and /readyz on the mux.
+func (m *Monitor) HandleHealth(mux *http.ServeMux) {
+	mux.HandleFunc("/healthz", m.health.handleHealthz)
+	mux.HandleFunc("/readyz", m.health.handleReadyz)
+}
+
+// Run sets up the sinks and watches pods until ctx is done, then waits for pending notifications.
+// It fails if a sink can't be set up or watching pods fails permanently.
+func (m *Monitor) Run(ctx context.Context) error {
+	opts := m.opts
+	// also stops the sinks if watching fails
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	if opts.SelfEvent || opts.StormThreshold > 0 {
+		m.resolveSelfObject(ctx, os.Getenv("POD_NAMESPACE"), os.Getenv("POD_NAME"))
+	}
+	if opts.StormThreshold > 0 && m.selfObject == nil {
+		slog.Warn("$POD_NAMESPACE or $POD_NAME is not set, restart storms are only logged")
+	}
+	if opts.SelfEvent {
+		m.emitStartedEvent(ctx)
+	}
+
+	m.sinks.add("kubernetes events", &KubeEventSink{monitor: m})
+	if opts.WebhookURL != "" {
+		m.sinks.add("webhook", NewWebhookSink(opts.WebhookURL, opts.WebhookSecret, m.webhookTLSConfig, opts.WebhookTimeout))
+	}
+	if opts.SlackWebhookURL != "" {
+		slack := NewSlackSink(opts.SlackWebhookURL, opts.WebhookTimeout)
+		slack.deadletter = m.sinks.writeDeadletter
+		m.sinks.add("slack", slack)
+	}
+	if opts.GoogleChatWebhookURL != "" {
+		googleChat := NewGoogleChatSink(opts.GoogleChatWebhookURL, opts.WebhookTimeout)
+		googleChat.deadletter = m.sinks.writeDeadletter
+		m.sinks.add("google chat", googleChat)
+	}
+	if opts.DiscordWebhookURL != "" {
+		discord := NewDiscordSink(opts.DiscordWebhookURL, opts.WebhookTimeout)
+		discord.deadletter = m.sinks.writeDeadletter
+		m.sinks.add("discord", discord)
+	}
+	if opts.TeamsWebhookURL != "" {
+		teams := NewTeamsSink(opts.TeamsWebhookURL, opts.WebhookTimeout)
+		teams.deadletter = m.sinks.writeDeadletter
+		m.sinks.add("teams", teams)
+	}
+	if opts.Output == outputJSON {
+		m.sinks.add("stdout", NewStreamSink(os.Stdout))
+	}
+	if opts.SyslogAddr != "" {
+		syslog, err := NewSyslogSink(opts.SyslogProtocol, opts.SyslogAddr)
+		if err != nil {
+			return fmt.Errorf("unable to set up syslog: %w", err)
+		}
+		defer syslog.Close()
+		m.sinks.add("syslog", syslog)
+	}
+	if opts.OutputFile != "" {
+		file, err := NewFileSink(opts.OutputFile, opts.OutputFileMaxSize, opts.OutputFileMaxBackups)
+		if err != nil {
+			return fmt.Errorf("unable to open output file: %w", err)
+		}
+		defer file.Close()
+		m.sinks.add("file", file)
+	}
+	if opts.NATSURL != "" {
+		nats, err := NewNATSSink(opts.NATSURL, opts.NATSSubject)
+		if err != nil {
+			return fmt.Errorf("unable to connect to NATS: %w", err)
+		}
+		defer nats.Close()
+		m.sinks.add("nats", nats)
+	}
+	if opts.GRPCSinkAddr != "" {
+		grpcSink, err := NewGRPCSink(opts.GRPCSinkAddr, opts.GRPCSinkTLS, opts.Version)
+		if err != nil {
+			return fmt.Errorf("invalid gRPC sink configuration: %w", err)
+		}
+		defer grpcSink.Close()
+		m.sinks.add("grpc", grpcSink)
+	}
+	if opts.RedisAddr != "" {
+		redisSink, err := NewRedisStreamSink(opts.RedisAddr, opts.RedisStream, opts.RedisStreamMaxLen)
+		if err != nil {
+			return fmt.Errorf("invalid Redis configuration: %w", err)
+		}
+		defer redisSink.Close()
+		m.sinks.add("redis", redisSink)
+	}
+	if opts.KafkaBrokers != "" {
+		kafkaSink, err := NewKafkaSink(&kafkaConfig{
+			brokers:       opts.KafkaBrokers,
+			topic:         opts.KafkaTopic,
+			tls:           opts.KafkaTLS,
+			saslMechanism: opts.KafkaSASLMechanism,
+			saslUsername:  opts.KafkaSASLUsername,
+			saslPassword:  opts.KafkaSASLPassword,
+		})
+		if err != nil {
+			return fmt.Errorf("invalid Kafka configuration: %w", err)
+		}
+		kafkaSink.deadletter = m.sinks.writeDeadletter
+		defer kafkaSink.Close()
+		m.sinks.add("kafka", kafkaSink)
+	}
+	if opts.AlertmanagerURL != "" {
+		m.sinks.add("alertmanager", NewAlertmanagerSink(opts.AlertmanagerURL, opts.EventReason, m.propagateLabels, opts.WebhookTimeout))
+	}
+	if opts.PagerDutyRoutingKey != "" {
+		m.sinks.add("pagerduty", NewPagerDutySink(opts.PagerDutyRoutingKey, opts.WebhookTimeout))
+	}
+	if opts.BatchSize > 1 {
+		m.sinks.batch(opts.BatchSize, opts.FlushInterval)
+	}
+	m.sinks.start()
+	// after watching stopped and the restart workers are drained
+	defer m.sinks.close()
+	defer cancel()
+
+	if opts.DeadletterDir != "" {
+		if err := os.MkdirAll(opts.DeadletterDir, 0o700); err != nil {
+			return fmt.Errorf("unable to create deadletter directory: %w", err)
+		}
+	}
+	if opts.DeadletterReplay {
+		if err := m.sinks.replayDeadletters(); err != nil {
+			slog.Warn("Unable to replay deadletters", "err", err)
+		}
+	}
+
+	if opts.EnableLeaderElection {
+		return m.runWithLeaderElection(ctx, opts.LeaderElectionNamespace, func(ctx context.Context) error {
+			return m.runMonitor(ctx)
+		})
+	}
+	return m.runMonitor(ctx)
+}
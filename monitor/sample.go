@@ -0,0 +1,31 @@
+package monitor
+
+import (
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// sampler lets only every rate-th restart of a container through, starting with the first one.
+// It is only used from the main loop.
+type sampler struct {
+	rate   int32
+	counts map[containerKey]int32
+}
+
+func (s *sampler) allow(key containerKey, delta int32) bool {
+	if s.rate <= 1 {
+		return true
+	}
+	prev := s.counts[key]
+	s.counts[key] = prev + delta
+	// whether one of the restarts prev..prev+delta-1 is a multiple of rate
+	nextSampled := (prev + s.rate - 1) / s.rate * s.rate
+	return nextSampled < prev+delta
+}
+
+func (s *sampler) forget(podUID types.UID) {
+	for key := range s.counts {
+		if key.podUID == podUID {
+			delete(s.counts, key)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package monitor
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestSampler(t *testing.T) {
+	for _, tc := range []struct {
+		rate     int32
+		deltas   []int32
+		expected []bool
+	}{
+		{1, []int32{1, 1, 1}, []bool{true, true, true}},
+		// restarts 0, 3 and 6 of every container are sampled, the first one always
+		{3, []int32{1, 1, 1, 1, 1, 1, 1}, []bool{true, false, false, true, false, false, true}},
+		// restarts 0, 1-2, 3-7, 8
+		{3, []int32{1, 2, 5, 1}, []bool{true, false, true, false}},
+	} {
+		s := &sampler{rate: tc.rate, counts: make(map[containerKey]int32)}
+		key := containerKey{"uid", "app"}
+		var actual []bool
+		for _, delta := range tc.deltas {
+			actual = append(actual, s.allow(key, delta))
+		}
+		if !reflect.DeepEqual(actual, tc.expected) {
+			t.Errorf("rate %d, deltas %v: allowed %v, want %v", tc.rate, tc.deltas, actual, tc.expected)
+		}
+	}
+
+	s := &sampler{rate: 3, counts: make(map[containerKey]int32)}
+	s.allow(containerKey{"uid", "app"}, 1)
+	if !s.allow(containerKey{"uid", "sidecar"}, 1) {
+		t.Error("first restart of another container is not sampled")
+	}
+	s.forget("uid")
+	if !s.allow(containerKey{"uid", "app"}, 1) {
+		t.Error("first restart after forgetting the pod is not sampled")
+	}
+}
+
+func TestInvalidSampleRateAndWorkers(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		apply func(opts *Options)
+	}{
+		{"-sample-rate", func(opts *Options) { opts.SampleRate = 0 }},
+		{"-workers", func(opts *Options) { opts.Workers = 0 }},
+	} {
+		opts := DefaultOptions()
+		tc.apply(&opts)
+		if _, err := New(fake.NewSimpleClientset(), opts); err == nil || !strings.Contains(err.Error(), tc.name) {
+			t.Errorf("error = %v, want %s must be at least 1", err, tc.name)
+		}
+	}
+}
@@ -0,0 +1,341 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const sinkQueueSize = 100
+
+// Sink is an output for restart notifications.
+type Sink interface {
+	Notify(ctx context.Context, info *RestartInfo) error
+}
+
+// RecoverySink is implemented by sinks that resolve their notifications when a restarted container recovers
+// (see -recovery-after).
+type RecoverySink interface {
+	NotifyRecovery(ctx context.Context, info *RestartInfo) error
+}
+
+// RestartInfo describes a detected container restart.
+type RestartInfo struct {
+	Namespace          string                  `json:"namespace"`
+	PodName            string                  `json:"pod"`
+	PodUID             types.UID               `json:"podUID"`
+	Labels             map[string]string       `json:"labels,omitempty"`
+	OwnerReferences    []metav1.OwnerReference `json:"ownerReferences,omitempty"`
+	OwnerKind          string                  `json:"ownerKind,omitempty"`
+	OwnerName          string                  `json:"ownerName,omitempty"`
+	Container          string                  `json:"container"`
+	Image              string                  `json:"image"`
+	ImageID            string                  `json:"imageID"`
+	RestartCount       int32                   `json:"restartCount"`
+	Delta              int32                   `json:"delta"`
+	ExitCode           int32                   `json:"exitCode"`
+	TerminationReason  string                  `json:"terminationReason"`
+	TerminationMessage string                  `json:"terminationMessage"`
+	Timestamp          metav1.Time             `json:"timestamp"`
+	EventReason        string                  `json:"eventReason"`
+	EventType          string                  `json:"eventType"`
+	EventAction        string                  `json:"eventAction,omitempty"`
+	Message            string                  `json:"message"`
+	NeverReady         bool                    `json:"neverReady,omitempty"`
+
+	Pod             *v1.Pod             `json:"-"`
+	ContainerStatus *v1.ContainerStatus `json:"-"`
+}
+
+// terminated reports whether the container has a last termination state. It uses the serialized fields, since
+// ContainerStatus is not set on notifications replayed from older deadletters.
+func (info *RestartInfo) terminated() bool {
+	return info.TerminationReason != "" || info.ExitCode != 0
+}
+
+// isImagePullError reports whether the notification is about an image pull error of -watch-image-pull-errors
+// instead of a restart.
+func (info *RestartInfo) isImagePullError() bool {
+	return info.EventAction == imagePullEventAction
+}
+
+// restartEventType looks up the termination reason, then the exit code in the -event-type-map. Without a match
+// clean exits (code 0, except OOM kills) are Normal and everything else is Warning.
+func (m *Monitor) restartEventType(info *RestartInfo) string {
+	terminated := info.terminated()
+	if eventType, ok := m.eventTypes[info.TerminationReason]; ok && info.TerminationReason != "" {
+		return eventType
+	}
+	if eventType, ok := m.eventTypes[strconv.Itoa(int(info.ExitCode))]; ok && terminated {
+		return eventType
+	}
+	if terminated && info.ExitCode == 0 && info.TerminationReason != oomKilledReason {
+		return v1.EventTypeNormal
+	}
+	return v1.EventTypeWarning
+}
+
+// parseEventTypeMap parses comma-separated reason=type or exitCode=type pairs, e.g. "Completed=Normal,143=Normal",
+// into eventTypes.
+func parseEventTypeMap(spec string, eventTypes map[string]string) error {
+	for _, pair := range splitList(spec) {
+		key, eventType, ok := strings.Cut(pair, "=")
+		if !ok || key == "" || (eventType != v1.EventTypeNormal && eventType != v1.EventTypeWarning) {
+			return fmt.Errorf("invalid event type mapping %q, expected <reason or exit code>=Normal|Warning", pair)
+		}
+		eventTypes[key] = eventType
+	}
+	return nil
+}
+
+type severity string
+
+const (
+	severityCritical severity = "critical"
+	severityError    severity = "error"
+	severityWarning  severity = "warning"
+)
+
+// restartSeverity is critical for OOM kills, error for non-zero exit codes and warning otherwise.
+func restartSeverity(info *RestartInfo) severity {
+	switch {
+	case info.TerminationReason == oomKilledReason:
+		return severityCritical
+	case info.ExitCode != 0:
+		return severityError
+	}
+	return severityWarning
+}
+
+// propagatedLabels returns the labels with the keys, i.e. the -propagate-labels of the pod.
+func propagatedLabels(labels map[string]string, keys []string) map[string]string {
+	propagated := make(map[string]string, len(keys))
+	for _, key := range keys {
+		if value, ok := labels[key]; ok {
+			propagated[key] = value
+		}
+	}
+	return propagated
+}
+
+func (m *Monitor) newRestartInfo(pod *v1.Pod, containerStatus *v1.ContainerStatus, delta int32, eventReason string) *RestartInfo {
+	owner := m.owners.ownerOf(pod)
+	info := &RestartInfo{
+		Namespace:       pod.Namespace,
+		PodName:         pod.Name,
+		PodUID:          pod.UID,
+		Labels:          pod.Labels,
+		OwnerReferences: pod.OwnerReferences,
+		OwnerKind:       owner.Kind,
+		OwnerName:       owner.Name,
+		Container:       containerStatus.Name,
+		Image:           containerStatus.Image,
+		ImageID:         containerStatus.ImageID,
+		RestartCount:    containerStatus.RestartCount,
+		Delta:           delta,
+		Timestamp:       metav1.Now(),
+		EventReason:     eventReason,
+		Pod:             pod,
+		ContainerStatus: containerStatus,
+	}
+	if t := containerStatus.LastTerminationState.Terminated; t != nil {
+		info.ExitCode = t.ExitCode
+		info.TerminationReason = t.Reason
+		info.TerminationMessage = t.Message
+		info.Timestamp = t.FinishedAt
+	}
+	info.EventType = m.restartEventType(info)
+	info.EventAction = m.opts.EventAction
+	return info
+}
+
+// sinkDispatcher delivers each restart to all sinks concurrently. Every sink has its own bounded queue,
+// so a slow or failing sink neither blocks pod processing nor delays other sinks. On close the queues
+// are drained, each delivery has its own timeout regardless of the shutdown.
+type sinkDispatcher struct {
+	timeout time.Duration
+	// only logs notifications
+	dryRun bool
+	// keeps notifications which could not be delivered, one JSON file each (disabled if empty)
+	deadletterDir string
+	deadletterSeq atomic.Uint64
+
+	sinks []*sinkRunner
+	wg    sync.WaitGroup
+
+	// guards closing the queues against concurrent enqueues
+	mu     sync.RWMutex
+	closed bool
+}
+
+type sinkRunner struct {
+	name  string
+	sink  Sink
+	queue chan sinkItem
+}
+
+// backgroundSink is implemented by sinks delivering from a goroutine of their own, which the dispatcher
+// runs next to the sink worker. Once the sink queue is drained, stop is called and run returns after
+// delivering everything still pending, e.g. the last batch.
+type backgroundSink interface {
+	run()
+	stop()
+}
+
+type sinkItem struct {
+	info      *RestartInfo
+	recovered bool
+}
+
+func (d *sinkDispatcher) add(name string, sink Sink) {
+	d.sinks = append(d.sinks, &sinkRunner{
+		name:  name,
+		sink:  sink,
+		queue: make(chan sinkItem, sinkQueueSize),
+	})
+}
+
+// start runs the sink workers until close.
+func (d *sinkDispatcher) start() {
+	for _, runner := range d.sinks {
+		d.wg.Add(1)
+		go func(runner *sinkRunner) {
+			defer d.wg.Done()
+			d.run(runner)
+		}(runner)
+	}
+}
+
+func (d *sinkDispatcher) run(runner *sinkRunner) {
+	if background, ok := runner.sink.(backgroundSink); ok {
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			background.run()
+		}()
+		defer func() {
+			background.stop()
+			<-done
+		}()
+	}
+
+	for item := range runner.queue {
+		info := item.info
+		sinkCtx, cancel := context.WithTimeout(context.Background(), d.timeout)
+		var err error
+		if item.recovered {
+			err = runner.sink.(RecoverySink).NotifyRecovery(sinkCtx, info)
+		} else {
+			err = runner.sink.Notify(sinkCtx, info)
+		}
+		cancel()
+		if err != nil {
+			slog.Warn("Unable to notify sink", "sink", runner.name, "namespace", info.Namespace, "pod", info.PodName, "container", info.Container, "err", err)
+			d.writeDeadletter(runner.name, item, err)
+		}
+	}
+}
+
+// close stops accepting notifications and blocks until the queued ones are delivered. Notifications
+// dispatched afterwards are saved to the deadletter directory.
+func (d *sinkDispatcher) close() {
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		for _, runner := range d.sinks {
+			close(runner.queue)
+		}
+	}
+	d.mu.Unlock()
+	d.wg.Wait()
+}
+
+func (d *sinkDispatcher) dispatch(info *RestartInfo) {
+	d.enqueue(sinkItem{info: info})
+}
+
+// dispatchRecovery notifies the sinks implementing RecoverySink.
+func (d *sinkDispatcher) dispatchRecovery(info *RestartInfo) {
+	d.enqueue(sinkItem{info: info, recovered: true})
+}
+
+func (d *sinkDispatcher) enqueue(item sinkItem) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	info := item.info
+	for _, runner := range d.sinks {
+		if _, ok := runner.sink.(RecoverySink); item.recovered && !ok {
+			continue
+		}
+		if d.dryRun {
+			slog.Info("Dry run, not notifying sink", "sink", runner.name, "namespace", info.Namespace, "pod", info.PodName, "container", info.Container, "eventReason", info.EventReason)
+			continue
+		}
+		if d.closed {
+			slog.Warn("Sinks are closed, dropping notification", "sink", runner.name, "namespace", info.Namespace, "pod", info.PodName, "container", info.Container)
+			d.writeDeadletter(runner.name, item, errors.New("sinks are closed"))
+			continue
+		}
+		select {
+		case runner.queue <- item:
+		default:
+			slog.Warn("Sink queue is full, dropping notification", "sink", runner.name, "namespace", info.Namespace, "pod", info.PodName, "container", info.Container)
+			d.writeDeadletter(runner.name, item, errors.New("sink queue is full"))
+		}
+	}
+}
+
+// labelAnnotation returns the event annotation of a propagated pod label, prefixed so that labels cannot
+// overwrite the annotations of the monitor, e.g. restart-monitor.smpio/label.team. The slash of prefixed label
+// keys is replaced with an underscore: app.kubernetes.io/name becomes restart-monitor.smpio/label.app.kubernetes.io_name.
+func labelAnnotation(key string) string {
+	return annotationPrefix + "label." + strings.ReplaceAll(key, "/", "_")
+}
+
+// KubeEventSink emits Kubernetes events, one per report with the count of restarts, and Normal events
+// of recoveries.
+type KubeEventSink struct {
+	monitor *Monitor
+}
+
+func (s *KubeEventSink) Notify(ctx context.Context, info *RestartInfo) error {
+	m := s.monitor
+	annotations := map[string]string{
+		annotationPrefix + "image":    info.Image,
+		annotationPrefix + "image-id": info.ImageID,
+	}
+	for key, value := range propagatedLabels(info.Labels, m.propagateLabels) {
+		annotations[labelAnnotation(key)] = value
+	}
+	if info.NeverReady {
+		annotations[annotationPrefix+"never-became-ready"] = "true"
+	}
+	if info.OwnerKind != "" {
+		annotations[annotationPrefix+"owner-kind"] = info.OwnerKind
+		annotations[annotationPrefix+"owner-name"] = info.OwnerName
+	}
+	// set since image pull errors are reported as well, which are not restarts
+	eventType, action := info.EventType, info.EventAction
+	if eventType == "" {
+		eventType, action = m.restartEventType(info), m.opts.EventAction
+	}
+	return m.eventWriter.write(ctx, m.eventObject(info.Pod), m.relatedObject(info.Pod), annotations,
+		eventType, info.EventReason, action, info.Message, max(info.Delta, 1))
+}
+
+func (s *KubeEventSink) NotifyRecovery(ctx context.Context, info *RestartInfo) error {
+	m := s.monitor
+	return m.eventWriter.write(ctx, m.eventObject(info.Pod), m.relatedObject(info.Pod), nil,
+		v1.EventTypeNormal, info.EventReason, recoveredEventAction, info.Message, 1)
+}
@@ -0,0 +1,213 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newTestMonitor(t *testing.T, opts Options) (*Monitor, *fake.Clientset) {
+	t.Helper()
+	client := fake.NewSimpleClientset()
+	m, err := New(client, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m, client
+}
+
+func listEvents(t *testing.T, client *fake.Clientset) []v1.Event {
+	t.Helper()
+	events, err := client.CoreV1().Events("").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return events.Items
+}
+
+func TestKubeEventSinkPropagatesLabels(t *testing.T) {
+	opts := DefaultOptions()
+	opts.PropagateLabels = "team,app.kubernetes.io/name,missing"
+	m, client := newTestMonitor(t, opts)
+
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Namespace: "default",
+		Name:      "web",
+		Labels:    map[string]string{"team": "payments", "app.kubernetes.io/name": "web"},
+	}}
+	containerStatus := &v1.ContainerStatus{Name: "app", Image: "app:latest", RestartCount: 1}
+	info := m.newRestartInfo(pod, containerStatus, 1, opts.EventReason)
+	if err := (&KubeEventSink{monitor: m}).Notify(context.Background(), info); err != nil {
+		t.Fatal(err)
+	}
+
+	events := listEvents(t, client)
+	if len(events) != 1 {
+		t.Fatalf("%d events, want 1", len(events))
+	}
+	annotations := events[0].Annotations
+	for key, value := range map[string]string{
+		"restart-monitor.smpio/label.team":                   "payments",
+		"restart-monitor.smpio/label.app.kubernetes.io_name": "web",
+		"restart-monitor.smpio/image":                        "app:latest",
+	} {
+		if annotations[key] != value {
+			t.Errorf("annotation %s = %q, want %q", key, annotations[key], value)
+		}
+	}
+	if _, ok := annotations["team"]; ok {
+		t.Error("label is copied under its raw key")
+	}
+	if _, ok := annotations["restart-monitor.smpio/label.missing"]; ok {
+		t.Error("missing label is copied")
+	}
+}
+
+func TestNewFailsOnInvalidPropagatedLabel(t *testing.T) {
+	opts := DefaultOptions()
+	opts.PropagateLabels = "team," + strings.Repeat("x", 64)
+	if _, err := New(fake.NewSimpleClientset(), opts); err == nil || !strings.Contains(err.Error(), "invalid propagated label") {
+		t.Errorf("error = %v, want invalid propagated label", err)
+	}
+}
+
+func TestRestartEventType(t *testing.T) {
+	for _, tc := range []struct {
+		eventTypeMap string
+		exitCode     int32
+		reason       string
+		expected     string
+	}{
+		{"", 0, "Completed", v1.EventTypeNormal},
+		{"", 1, "Error", v1.EventTypeWarning},
+		{"", 137, oomKilledReason, v1.EventTypeWarning},
+		{"", 0, oomKilledReason, v1.EventTypeWarning},
+		// without a termination state
+		{"", 0, "", v1.EventTypeWarning},
+		{"143=Normal,Completed=Warning", 143, "Error", v1.EventTypeNormal},
+		{"143=Normal,Completed=Warning", 0, "Completed", v1.EventTypeWarning},
+		{"143=Normal,Completed=Warning", 1, "Error", v1.EventTypeWarning},
+		// the reason takes precedence over the exit code
+		{"Error=Normal,1=Warning", 1, "Error", v1.EventTypeNormal},
+	} {
+		opts := DefaultOptions()
+		opts.EventTypeMap = tc.eventTypeMap
+		m, _ := newTestMonitor(t, opts)
+		info := &RestartInfo{ExitCode: tc.exitCode, TerminationReason: tc.reason}
+		if eventType := m.restartEventType(info); eventType != tc.expected {
+			t.Errorf("event type of exit code %d (%s) with map %q = %s, want %s", tc.exitCode, tc.reason, tc.eventTypeMap, eventType, tc.expected)
+		}
+	}
+
+	for _, spec := range []string{"Completed", "=Normal", "Completed=Info", "143=normal"} {
+		opts := DefaultOptions()
+		opts.EventTypeMap = spec
+		if _, err := New(fake.NewSimpleClientset(), opts); err == nil || !strings.Contains(err.Error(), "invalid event type mapping") {
+			t.Errorf("map %q: error = %v, want invalid event type mapping", spec, err)
+		}
+	}
+}
+
+// fakeSink records the notifications. Notify fails with err, after waiting for release if it is set.
+type fakeSink struct {
+	err     error
+	release chan struct{}
+
+	mu        sync.Mutex
+	notified  []*RestartInfo
+	deadlines []time.Time
+}
+
+func (s *fakeSink) Notify(ctx context.Context, info *RestartInfo) error {
+	if s.release != nil {
+		select {
+		case <-s.release:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	deadline, _ := ctx.Deadline()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.notified = append(s.notified, info)
+	s.deadlines = append(s.deadlines, deadline)
+	return s.err
+}
+
+func (s *fakeSink) infos() []*RestartInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*RestartInfo(nil), s.notified...)
+}
+
+func TestSinkDispatcherNotifiesAllSinks(t *testing.T) {
+	failing := &fakeSink{err: errors.New("unavailable")}
+	working := &fakeSink{}
+	d := &sinkDispatcher{timeout: time.Minute}
+	d.add("failing", failing)
+	d.add("working", working)
+	d.start()
+
+	for _, pod := range []string{"web", "worker"} {
+		d.dispatch(&RestartInfo{Namespace: "default", PodName: pod, Container: "app"})
+	}
+	d.close()
+
+	for name, sink := range map[string]*fakeSink{"failing": failing, "working": working} {
+		infos := sink.infos()
+		if len(infos) != 2 || infos[0].PodName != "web" || infos[1].PodName != "worker" {
+			t.Errorf("%s sink notified of %+v, want web and worker in order", name, infos)
+		}
+	}
+	if deadline := working.deadlines[0]; time.Until(deadline) > time.Minute || time.Until(deadline) < 50*time.Second {
+		t.Errorf("sink deadline in %v, want the 1m sink timeout", time.Until(deadline))
+	}
+}
+
+func TestSinkDispatcherSlowSinkDoesNotBlockOthers(t *testing.T) {
+	slow := &fakeSink{release: make(chan struct{})}
+	fast := &fakeSink{}
+	d := &sinkDispatcher{timeout: time.Minute}
+	d.add("slow", slow)
+	d.add("fast", fast)
+	d.start()
+
+	d.dispatch(&RestartInfo{Namespace: "default", PodName: "web", Container: "app"})
+	deadline := time.Now().Add(5 * time.Second)
+	for len(fast.infos()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(fast.infos()) != 1 {
+		t.Error("fast sink is not notified while the slow sink is pending")
+	}
+
+	close(slow.release)
+	d.close()
+	if len(slow.infos()) != 1 {
+		t.Error("slow sink is not notified before close returned")
+	}
+}
+
+func TestSinkDispatcherTimeout(t *testing.T) {
+	stuck := &fakeSink{release: make(chan struct{})}
+	d := &sinkDispatcher{timeout: 50 * time.Millisecond}
+	d.add("stuck", stuck)
+	d.start()
+
+	start := time.Now()
+	d.dispatch(&RestartInfo{Namespace: "default", PodName: "web", Container: "app"})
+	d.close()
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("close returned after %v, want the stuck delivery to time out", elapsed)
+	}
+	if len(stuck.infos()) != 0 {
+		t.Error("stuck sink is notified")
+	}
+}
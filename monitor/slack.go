@@ -0,0 +1,85 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const slackMaxTextLength = 500
+
+type slackMessage struct {
+	Text        string            `json:"text"`
+	Attachments []slackAttachment `json:"attachments"`
+}
+
+type slackAttachment struct {
+	Color  string       `json:"color"`
+	Text   string       `json:"text"`
+	Fields []slackField `json:"fields"`
+}
+
+type slackField struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short"`
+}
+
+// SlackSink sends Slack messages, coalescing restarts of the same container.
+type SlackSink struct {
+	*coalescingSink
+	url    string
+	client *http.Client
+}
+
+func NewSlackSink(url string, timeout time.Duration) *SlackSink {
+	s := &SlackSink{
+		url:    url,
+		client: &http.Client{Timeout: timeout},
+	}
+	s.coalescingSink = newCoalescingSink("slack", s.send)
+	return s
+}
+
+func (s *SlackSink) send(ctx context.Context, notification *chatNotification) error {
+	body, err := json.Marshal(newSlackMessage(notification))
+	if err != nil {
+		return err
+	}
+	return postJSON(ctx, s.client, s.url, body)
+}
+
+func newSlackMessage(notification *chatNotification) *slackMessage {
+	info := notification.info
+
+	text := truncateText(info.Message, slackMaxTextLength)
+	if notification.restarts > 1 {
+		text += fmt.Sprintf("\n(%d restarts within %v)", notification.restarts, chatCoalesceWindow)
+	}
+
+	attachment := slackAttachment{
+		Color: "warning",
+		Text:  text,
+		Fields: []slackField{
+			{Title: "Namespace", Value: info.Namespace, Short: true},
+			{Title: "Pod", Value: info.PodName, Short: true},
+			{Title: "Container", Value: info.Container, Short: true},
+		},
+	}
+	if info.terminated() {
+		if info.ExitCode != 0 {
+			attachment.Color = "danger"
+		}
+		attachment.Fields = append(attachment.Fields,
+			slackField{Title: "Exit code", Value: formatExitCode(info.ExitCode), Short: true},
+			slackField{Title: "Reason", Value: info.TerminationReason, Short: true},
+		)
+	}
+
+	return &slackMessage{
+		Text:        chatTitle(info),
+		Attachments: []slackAttachment{attachment},
+	}
+}
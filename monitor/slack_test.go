@@ -0,0 +1,68 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// runChatSink runs the sink until the returned stop is called, which sends the pending messages.
+func runChatSink(t *testing.T, s *coalescingSink) (stop func()) {
+	s.deadletter = func(sink string, item sinkItem, err error) {
+		t.Errorf("%s: message of %s/%s is undelivered: %v", sink, item.info.Namespace, item.info.PodName, err)
+	}
+	done := make(chan struct{})
+	go func() {
+		s.run()
+		close(done)
+	}()
+	return func() {
+		s.stop()
+		<-done
+	}
+}
+
+func TestSlackSink(t *testing.T) {
+	receiver := newTestReceiver(t, http.StatusOK)
+	s := NewSlackSink(receiver.URL, time.Second)
+	stop := runChatSink(t, s.coalescingSink)
+
+	// coalesced into one message
+	for i := 0; i < 2; i++ {
+		if err := s.Notify(context.Background(), newTestRestartInfo()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stop()
+
+	_, bodies := receiver.received()
+	if len(bodies) != 1 {
+		t.Fatalf("%d messages, want 1", len(bodies))
+	}
+	var message slackMessage
+	if err := json.Unmarshal(bodies[0], &message); err != nil {
+		t.Fatal(err)
+	}
+	if message.Text != "Container app in pod default/web restarted" {
+		t.Errorf("text = %q", message.Text)
+	}
+	if len(message.Attachments) != 1 {
+		t.Fatalf("%d attachments, want 1", len(message.Attachments))
+	}
+	attachment := message.Attachments[0]
+	if expected := "Container app in pod default/web restarted.\n(2 restarts within 10s)"; attachment.Text != expected {
+		t.Errorf("attachment text = %q, want %q", attachment.Text, expected)
+	}
+	if attachment.Color != "danger" {
+		t.Errorf("color = %q, want danger", attachment.Color)
+	}
+	fields := map[string]string{}
+	for _, field := range attachment.Fields {
+		fields[field.Title] = field.Value
+	}
+	if fields["Exit code"] != "137 (SIGKILL)" || fields["Reason"] != oomKilledReason {
+		t.Errorf("fields = %v", fields)
+	}
+}
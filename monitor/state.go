@@ -0,0 +1,74 @@
+package monitor
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const stateSaveInterval = 30 * time.Second
+
+// monitorState is persisted in the -state-file.
+type monitorState struct {
+	// last synced resourceVersion per watched namespace
+	ResourceVersions map[string]string `json:"resourceVersions"`
+	// last seen restart count of each container, keyed by pod UID and container name
+	RestartCounts map[types.UID]map[string]int32 `json:"restartCounts"`
+}
+
+func newMonitorState() *monitorState {
+	return &monitorState{
+		ResourceVersions: make(map[string]string),
+		RestartCounts:    make(map[types.UID]map[string]int32, 1000),
+	}
+}
+
+// loadState reads the state from path, returning an empty state if the file doesn't exist yet.
+func loadState(path string) (*monitorState, error) {
+	state := newMonitorState()
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, err
+	}
+	if state.ResourceVersions == nil {
+		state.ResourceVersions = make(map[string]string)
+	}
+	if state.RestartCounts == nil {
+		state.RestartCounts = make(map[types.UID]map[string]int32, 1000)
+	}
+	return state, nil
+}
+
+// save writes the state atomically, so a crash never leaves a partially written file.
+func (s *monitorState) save(path string) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,50 @@
+package monitor
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestStateSaveLoad(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state.json")
+
+	state, err := loadState(path)
+	if err != nil {
+		t.Fatalf("loading a missing state: %v", err)
+	}
+	state.ResourceVersions["default"] = "12345"
+	state.RestartCounts["uid"] = map[string]int32{"app": 3}
+	if err := state.save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := loadState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, state) {
+		t.Errorf("loaded state = %+v, want %+v", loaded, state)
+	}
+
+	// the temporary file is renamed
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("%d files in the state directory, want 1", len(entries))
+	}
+}
+
+func TestLoadInvalidState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(path, []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadState(path); err == nil {
+		t.Error("loading an invalid state succeeded")
+	}
+}
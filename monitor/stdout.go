@@ -0,0 +1,27 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+const outputJSON = "json"
+
+// StreamSink writes one JSON object per restart to a stream, e.g. stdout for -output=json.
+type StreamSink struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+}
+
+func NewStreamSink(w io.Writer) *StreamSink {
+	return &StreamSink{encoder: json.NewEncoder(w)}
+}
+
+func (s *StreamSink) Notify(ctx context.Context, info *RestartInfo) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// os.Stdout is unbuffered, so every line is written out immediately
+	return s.encoder.Encode(info)
+}
@@ -0,0 +1,65 @@
+package monitor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestStreamSinkSchema(t *testing.T) {
+	var buf bytes.Buffer
+	s := NewStreamSink(&buf)
+	info := newTestRestartInfo()
+	info.PodUID = "0b6f0e6c"
+	info.Labels = map[string]string{"app": "web"}
+	info.OwnerReferences = []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "web-7d4b9c8f6", UID: "owner"}}
+	info.OwnerKind = "Deployment"
+	info.OwnerName = "web"
+	info.Image = "app:1.0"
+	info.ImageID = "docker-pullable://app@sha256:0123"
+	info.EventType = "Warning"
+	for i := 0; i < 2; i++ {
+		if err := s.Notify(context.Background(), info); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("%d lines, want one per restart", len(lines))
+	}
+	var record map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for key := range record {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	expectedKeys := []string{
+		"container", "delta", "eventReason", "eventType", "exitCode", "image", "imageID", "labels", "message",
+		"namespace", "ownerKind", "ownerName", "ownerReferences", "pod", "podUID", "restartCount",
+		"terminationMessage", "terminationReason", "timestamp",
+	}
+	if !reflect.DeepEqual(keys, expectedKeys) {
+		t.Errorf("fields %v, want %v", keys, expectedKeys)
+	}
+
+	var decoded RestartInfo
+	if err := json.Unmarshal([]byte(lines[1]), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded.Labels, info.Labels) || !reflect.DeepEqual(decoded.OwnerReferences, info.OwnerReferences) {
+		t.Errorf("labels %v and owner references %v, want %v and %v", decoded.Labels, decoded.OwnerReferences, info.Labels, info.OwnerReferences)
+	}
+	if !decoded.Timestamp.Equal(&info.Timestamp) {
+		t.Errorf("timestamp %v, want %v", decoded.Timestamp, info.Timestamp)
+	}
+}
@@ -0,0 +1,117 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	restartStormEventReason = "RestartStorm"
+	restartStormEventAction = "Detected"
+	// number of namespaces listed in the storm event
+	restartStormTopNamespaces = 5
+)
+
+type stormRestarts struct {
+	time      time.Time
+	namespace string
+	count     int32
+}
+
+// stormDetector counts the reported restarts across all pods within a sliding window. Once at least threshold of them
+// happen within it, a single RestartStorm event is emitted and individual reports are suppressed until the
+// count drops below threshold again. It is only used from the main loop.
+type stormDetector struct {
+	threshold int
+	window    time.Duration
+	// reports a detected storm
+	detected func(msg string)
+
+	restarts []stormRestarts
+	total    int
+	active   bool
+}
+
+// record counts delta restarts in the namespace and reports whether a storm is going on.
+func (d *stormDetector) record(namespace string, delta int32, now time.Time) bool {
+	if d.threshold <= 0 {
+		return false
+	}
+	d.restarts = append(d.restarts, stormRestarts{now, namespace, delta})
+	d.total += int(delta)
+	d.expire(now)
+
+	switch {
+	case !d.active && d.total >= d.threshold:
+		d.active = true
+		restartStormsTotal.Inc()
+		restartStormActive.Set(1)
+		msg := d.message()
+		slog.Warn("Restart storm detected", "restarts", d.total, "window", d.window, "message", msg)
+		d.detected(msg)
+	case d.active && d.total < d.threshold:
+		d.end()
+	}
+	return d.active
+}
+
+// expire drops restarts older than the window, also ending a storm that calmed down
+// without further restarts.
+func (d *stormDetector) expire(now time.Time) {
+	i := 0
+	for ; i < len(d.restarts) && now.Sub(d.restarts[i].time) > d.window; i++ {
+		d.total -= int(d.restarts[i].count)
+	}
+	d.restarts = d.restarts[i:]
+	if d.active && d.total < d.threshold {
+		d.end()
+	}
+}
+
+// reportStorm emits the storm event on the monitor's own pod, if known. It is written by a worker, not to block
+// the main loop.
+func (m *Monitor) reportStorm(msg string) {
+	if m.selfObject != nil {
+		m.restartWorkers.submit(restartStormEventReason, func() {
+			m.writeSelfEvent(context.Background(), v1.EventTypeWarning, restartStormEventReason, restartStormEventAction, msg)
+		})
+	}
+}
+
+func (d *stormDetector) end() {
+	d.active = false
+	restartStormActive.Set(0)
+	slog.Info("Restart storm ended", "restarts", d.total, "window", d.window)
+}
+
+func (d *stormDetector) message() string {
+	byNamespace := make(map[string]int)
+	for _, r := range d.restarts {
+		byNamespace[r.namespace] += int(r.count)
+	}
+	namespaces := make([]string, 0, len(byNamespace))
+	for namespace := range byNamespace {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Slice(namespaces, func(i, j int) bool {
+		a, b := namespaces[i], namespaces[j]
+		return byNamespace[a] > byNamespace[b] || byNamespace[a] == byNamespace[b] && a < b
+	})
+
+	top := make([]string, 0, restartStormTopNamespaces)
+	for i, namespace := range namespaces {
+		if i == restartStormTopNamespaces {
+			top = append(top, fmt.Sprintf("%d more", len(namespaces)-i))
+			break
+		}
+		top = append(top, fmt.Sprintf("%s (%d)", namespace, byNamespace[namespace]))
+	}
+	return fmt.Sprintf("%d container restarts within %v, individual restarts are not reported meanwhile. Top namespaces: %s.",
+		d.total, d.window, strings.Join(top, ", "))
+}
@@ -0,0 +1,93 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestStormDetectedOnce(t *testing.T) {
+	var detected []string
+	d := &stormDetector{threshold: 5, window: time.Minute, detected: func(msg string) {
+		detected = append(detected, msg)
+	}}
+	start := time.Date(2021, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	for i, namespace := range []string{"a", "b", "a", "c"} {
+		if d.record(namespace, 1, start.Add(time.Duration(i)*time.Second)) {
+			t.Fatalf("storm after %d restarts, want none below the threshold", i+1)
+		}
+	}
+	if !d.record("a", 1, start.Add(4*time.Second)) {
+		t.Fatal("no storm at the threshold")
+	}
+	for i := 0; i < 10; i++ {
+		if !d.record("d", 1, start.Add(5*time.Second)) {
+			t.Fatal("storm ended while restarts go on")
+		}
+	}
+	if len(detected) != 1 {
+		t.Fatalf("storm detected %d times, want once", len(detected))
+	}
+	if expected := "5 container restarts within 1m0s, individual restarts are not reported meanwhile. Top namespaces: a (3), b (1), c (1)."; detected[0] != expected {
+		t.Errorf("message = %q, want %q", detected[0], expected)
+	}
+
+	// ends once the restarts leave the window, and a new storm is detected again
+	d.expire(start.Add(2 * time.Minute))
+	if d.active {
+		t.Error("storm is active after the window")
+	}
+	for i := 0; i < 5; i++ {
+		d.record("e", 1, start.Add(3*time.Minute))
+	}
+	if len(detected) != 2 {
+		t.Errorf("storm detected %d times after it ended, want twice", len(detected))
+	}
+}
+
+func TestStormMessageTopNamespaces(t *testing.T) {
+	var detected string
+	d := &stormDetector{threshold: 28, window: time.Minute, detected: func(msg string) {
+		detected = msg
+	}}
+	now := time.Now()
+	for i := 1; i <= 7; i++ {
+		d.record(fmt.Sprintf("ns-%d", i), int32(i), now)
+	}
+	if expected := "28 container restarts within 1m0s, individual restarts are not reported meanwhile. Top namespaces: ns-7 (7), ns-6 (6), ns-5 (5), ns-4 (4), ns-3 (3), 2 more."; detected != expected {
+		t.Errorf("message = %q, want %q", detected, expected)
+	}
+}
+
+func TestStormDetectionDisabled(t *testing.T) {
+	d := &stormDetector{window: time.Minute}
+	for i := 0; i < 100; i++ {
+		if d.record("default", 1, time.Now()) {
+			t.Fatal("storm detected without a threshold")
+		}
+	}
+}
+
+func TestStormCountsOnlyReportedRestarts(t *testing.T) {
+	opts := DefaultOptions()
+	opts.StormThreshold = 2
+	opts.MuteSchedule = "00:00-24:00"
+	m, _ := newTestMonitor(t, opts)
+
+	for i := 0; i < 5; i++ {
+		pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: fmt.Sprintf("web-%d", i), UID: types.UID(fmt.Sprintf("web-%d", i))}}
+		containerStatus := &v1.ContainerStatus{Name: "app", RestartCount: 1}
+		// not notified, then muted
+		m.handleContainerRestart(context.Background(), pod, regularContainer, containerStatus, 1, false, false)
+		m.handleContainerRestart(context.Background(), pod, regularContainer, containerStatus, 1, true, false)
+	}
+	if m.storms.active || m.storms.total != 0 {
+		t.Errorf("storm of %d restarts that are not reported", m.storms.total)
+	}
+}
@@ -0,0 +1,45 @@
+//go:build !windows && !plan9
+
+package monitor
+
+import (
+	"context"
+	"log/syslog"
+)
+
+// SyslogSink sends restart messages to a syslog server. It connects on the first restart and
+// after failed connection attempts, and the writer reconnects on failed writes, so syslog outages
+// only fail the notifications.
+type SyslogSink struct {
+	network string
+	addr    string
+	writer  *syslog.Writer
+}
+
+func NewSyslogSink(network, addr string) (*SyslogSink, error) {
+	return &SyslogSink{network: network, addr: addr}, nil
+}
+
+func (s *SyslogSink) Notify(ctx context.Context, info *RestartInfo) error {
+	if s.writer == nil {
+		writer, err := syslog.Dial(s.network, s.addr, syslog.LOG_WARNING|syslog.LOG_DAEMON, "kube-restart-monitor")
+		if err != nil {
+			return err
+		}
+		s.writer = writer
+	}
+
+	switch restartSeverity(info) {
+	case severityCritical:
+		return s.writer.Err(info.Message)
+	case severityError:
+		return s.writer.Warning(info.Message)
+	}
+	return s.writer.Notice(info.Message)
+}
+
+func (s *SyslogSink) Close() {
+	if s.writer != nil {
+		s.writer.Close()
+	}
+}
@@ -0,0 +1,80 @@
+//go:build !windows && !plan9
+
+package monitor
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSyslogSink(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	s, err := NewSyslogSink("udp", conn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// the daemon facility is 3
+	for _, tc := range []struct {
+		exitCode int32
+		reason   string
+		priority string
+	}{
+		{137, oomKilledReason, "<27>"},
+		{1, "Error", "<28>"},
+		{0, "Completed", "<29>"},
+	} {
+		info := &RestartInfo{
+			ExitCode:          tc.exitCode,
+			TerminationReason: tc.reason,
+			Message:           "Container app in pod default/web restarted.",
+		}
+		if err := s.Notify(context.Background(), info); err != nil {
+			t.Fatal(err)
+		}
+
+		buf := make([]byte, 1024)
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		line := string(buf[:n])
+		if !strings.HasPrefix(line, tc.priority) {
+			t.Errorf("exit code %d: message %q, want priority %s", tc.exitCode, line, tc.priority)
+		}
+		if !strings.Contains(line, " kube-restart-monitor[") || !strings.HasSuffix(strings.TrimSuffix(line, "\n"), "]: "+info.Message) {
+			t.Errorf("exit code %d: message %q, want tag and message %q", tc.exitCode, line, info.Message)
+		}
+	}
+}
+
+func TestSyslogSinkUnavailable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	s, err := NewSyslogSink("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	// fails the notification only, and is retried on the next one
+	for i := 0; i < 2; i++ {
+		if err := s.Notify(context.Background(), newTestRestartInfo()); err == nil {
+			t.Error("notification succeeded without a syslog server")
+		}
+	}
+}
@@ -0,0 +1,20 @@
+//go:build windows || plan9
+
+package monitor
+
+import (
+	"context"
+	"errors"
+)
+
+type SyslogSink struct{}
+
+func NewSyslogSink(network, addr string) (*SyslogSink, error) {
+	return nil, errors.New("syslog is not supported on this platform")
+}
+
+func (s *SyslogSink) Notify(ctx context.Context, info *RestartInfo) error {
+	return nil
+}
+
+func (s *SyslogSink) Close() {}
@@ -0,0 +1,96 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"time"
+)
+
+// well below the 28kB limit of a Teams webhook payload, which the escaping of the message may grow
+const teamsMaxTextLength = 4096
+
+var teamsThemeColors = map[severity]string{
+	severityCritical: "A80000",
+	severityError:    "E81123",
+	severityWarning:  "FFB900",
+}
+
+type teamsMessageCard struct {
+	Type       string         `json:"@type"`
+	Context    string         `json:"@context"`
+	ThemeColor string         `json:"themeColor"`
+	Summary    string         `json:"summary"`
+	Title      string         `json:"title"`
+	Text       string         `json:"text"`
+	Sections   []teamsSection `json:"sections"`
+}
+
+type teamsSection struct {
+	Facts []teamsFact `json:"facts"`
+}
+
+type teamsFact struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// TeamsSink sends MessageCards to a Microsoft Teams incoming webhook, coalescing restarts of the same container.
+type TeamsSink struct {
+	*coalescingSink
+	url    string
+	client *http.Client
+}
+
+func NewTeamsSink(url string, timeout time.Duration) *TeamsSink {
+	s := &TeamsSink{
+		url:    url,
+		client: &http.Client{Timeout: timeout},
+	}
+	s.coalescingSink = newCoalescingSink("teams", s.send)
+	return s
+}
+
+func (s *TeamsSink) send(ctx context.Context, notification *chatNotification) error {
+	body, err := json.Marshal(newTeamsMessageCard(notification))
+	if err != nil {
+		return err
+	}
+	return postJSON(ctx, s.client, s.url, body)
+}
+
+func newTeamsMessageCard(notification *chatNotification) *teamsMessageCard {
+	info := notification.info
+	title := chatTitle(info)
+
+	// MessageCard text is markdown, keep the message lines, escaped so that the termination message
+	// cannot close the <pre> or inject markup
+	text := "<pre>" + html.EscapeString(truncateText(info.Message, teamsMaxTextLength)) + "</pre>"
+	if notification.restarts > 1 {
+		text += fmt.Sprintf("\n\n%d restarts within %v", notification.restarts, chatCoalesceWindow)
+	}
+
+	facts := []teamsFact{
+		{Name: "Namespace", Value: info.Namespace},
+		{Name: "Pod", Value: info.PodName},
+		{Name: "Container", Value: info.Container},
+	}
+	if info.terminated() {
+		facts = append(facts,
+			teamsFact{Name: "Exit code", Value: formatExitCode(info.ExitCode)},
+			teamsFact{Name: "Reason", Value: info.TerminationReason},
+		)
+	}
+
+	return &teamsMessageCard{
+		Type:       "MessageCard",
+		Context:    "https://schema.org/extensions",
+		ThemeColor: teamsThemeColors[restartSeverity(info)],
+		Summary:    title,
+		Title:      title,
+		Text:       text,
+		Sections:   []teamsSection{{Facts: facts}},
+	}
+}
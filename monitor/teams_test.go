@@ -0,0 +1,104 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTeamsMessageCardEscapesMessage(t *testing.T) {
+	info := &RestartInfo{
+		Namespace: "default",
+		PodName:   "web",
+		Container: "app",
+		Message:   "Container app in pod default/web restarted.\nMessage: </pre><script>alert(1)</script> & more",
+	}
+	card := newTeamsMessageCard(&chatNotification{info: info, restarts: 1})
+
+	expected := "<pre>Container app in pod default/web restarted.\nMessage: &lt;/pre&gt;&lt;script&gt;alert(1)&lt;/script&gt; &amp; more</pre>"
+	if card.Text != expected {
+		t.Errorf("text = %q, want %q", card.Text, expected)
+	}
+}
+
+func TestTeamsMessageCardTruncatesMessage(t *testing.T) {
+	info := &RestartInfo{Message: strings.Repeat("x", 2*teamsMaxTextLength)}
+	card := newTeamsMessageCard(&chatNotification{info: info, restarts: 1})
+
+	if length := len(card.Text) - len("<pre></pre>"); length != teamsMaxTextLength {
+		t.Errorf("message length = %d, want %d", length, teamsMaxTextLength)
+	}
+}
+
+func TestTeamsSink(t *testing.T) {
+	receiver := newTestReceiver(t, http.StatusOK)
+	s := NewTeamsSink(receiver.URL, time.Second)
+	stop := runChatSink(t, s.coalescingSink)
+
+	// coalesced into one card
+	for i := 0; i < 2; i++ {
+		if err := s.Notify(context.Background(), newTestRestartInfo()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stop()
+
+	requests, bodies := receiver.received()
+	if len(bodies) != 1 {
+		t.Fatalf("%d cards, want 1", len(bodies))
+	}
+	if contentType := requests[0].Header.Get("Content-Type"); contentType != "application/json" {
+		t.Errorf("content type = %q, want application/json", contentType)
+	}
+	var card teamsMessageCard
+	if err := json.Unmarshal(bodies[0], &card); err != nil {
+		t.Fatal(err)
+	}
+	if card.Type != "MessageCard" || card.Context != "https://schema.org/extensions" {
+		t.Errorf("card type %q, context %q, want a MessageCard", card.Type, card.Context)
+	}
+	title := "Container app in pod default/web restarted"
+	if card.Title != title || card.Summary != title {
+		t.Errorf("title %q, summary %q, want %q", card.Title, card.Summary, title)
+	}
+	if card.ThemeColor != teamsThemeColors[severityCritical] {
+		t.Errorf("theme color = %q, want %q of an OOM kill", card.ThemeColor, teamsThemeColors[severityCritical])
+	}
+	if expected := "<pre>Container app in pod default/web restarted.</pre>\n\n2 restarts within 10s"; card.Text != expected {
+		t.Errorf("text = %q, want %q", card.Text, expected)
+	}
+	if len(card.Sections) != 1 {
+		t.Fatalf("%d sections, want 1", len(card.Sections))
+	}
+	expectedFacts := []teamsFact{
+		{Name: "Namespace", Value: "default"},
+		{Name: "Pod", Value: "web"},
+		{Name: "Container", Value: "app"},
+		{Name: "Exit code", Value: "137 (SIGKILL)"},
+		{Name: "Reason", Value: oomKilledReason},
+	}
+	if !reflect.DeepEqual(card.Sections[0].Facts, expectedFacts) {
+		t.Errorf("facts = %+v, want %+v", card.Sections[0].Facts, expectedFacts)
+	}
+}
+
+func TestTeamsThemeColors(t *testing.T) {
+	for _, tc := range []struct {
+		exitCode int32
+		reason   string
+		expected string
+	}{
+		{137, oomKilledReason, "A80000"},
+		{1, "Error", "E81123"},
+		{0, "Completed", "FFB900"},
+	} {
+		info := &RestartInfo{ExitCode: tc.exitCode, TerminationReason: tc.reason}
+		if color := newTeamsMessageCard(&chatNotification{info: info, restarts: 1}).ThemeColor; color != tc.expected {
+			t.Errorf("theme color of exit code %d (%s) = %q, want %q", tc.exitCode, tc.reason, color, tc.expected)
+		}
+	}
+}
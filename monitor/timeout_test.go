@@ -0,0 +1,75 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// newHangingMonitor returns a monitor with a client of an apiserver which never responds.
+func newHangingMonitor(t *testing.T, opts Options) *Monitor {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	t.Cleanup(server.Close)
+	client, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := New(client, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestAPICallsReturnWhenCancelled(t *testing.T) {
+	m := newHangingMonitor(t, DefaultOptions())
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"}}
+
+	for name, call := range map[string]func() error{
+		"list": func() error {
+			_, err := m.listPods(ctx, "", metav1.ListOptions{})
+			return err
+		},
+		"logs": func() error {
+			_, err := m.fetchPreviousLogs(ctx, pod, "app")
+			return err
+		},
+	} {
+		start := time.Now()
+		err := call()
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("%s error = %v, want context canceled", name, err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("%s returned after %v", name, elapsed)
+		}
+	}
+}
+
+func TestAPICallTimeout(t *testing.T) {
+	opts := DefaultOptions()
+	opts.APITimeout = 100 * time.Millisecond
+	m := newHangingMonitor(t, opts)
+
+	start := time.Now()
+	_, err := m.listPods(context.Background(), "", metav1.ListOptions{})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error = %v, want deadline exceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("list returned after %v, want the 100ms api timeout", elapsed)
+	}
+}
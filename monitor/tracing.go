@@ -0,0 +1,26 @@
+package monitor
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// a no-op tracer unless a tracer provider is set with otel.SetTracerProvider
+var tracer = otel.Tracer("github.com/smpio/kube-restart-monitor")
+
+func podAttributes(pod *v1.Pod) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("k8s.namespace.name", pod.Namespace),
+		attribute.String("k8s.pod.name", pod.Name),
+		attribute.String("k8s.pod.uid", string(pod.UID)),
+	}
+}
+
+func startPodSpan(ctx context.Context, name string, pod *v1.Pod, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithAttributes(append(podAttributes(pod), attributes...)...))
+}
@@ -0,0 +1,200 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	v1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+)
+
+func TestIsExpired(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{"expired", apierrs.NewResourceExpired("too old resource version: 1 (2)"), true},
+		{"gone", apierrs.NewGone("the resourceVersion is gone"), true},
+		{"bare 410 status", apierrs.FromObject(&metav1.Status{Status: metav1.StatusFailure, Code: http.StatusGone}), true},
+		{"wrapped", fmt.Errorf("unable to list pods: %w", apierrs.NewResourceExpired("too old resource version: 1 (2)")), true},
+		{"message only", errors.New("too old resource version: 1 (2)"), true},
+		{"not found", apierrs.NewNotFound(schema.GroupResource{Resource: "pods"}, "web"), false},
+		{"internal error", apierrs.NewInternalError(errors.New("etcd is unavailable")), false},
+		{"too many requests", apierrs.NewTooManyRequests("slow down", 1), false},
+		{"cancelled", context.Canceled, false},
+		{"nil", nil, false},
+	} {
+		if actual := isExpired(tc.err); actual != tc.expected {
+			t.Errorf("%s: isExpired(%v) = %v, want %v", tc.name, tc.err, actual, tc.expected)
+		}
+	}
+}
+
+func TestWatchTimeout(t *testing.T) {
+	opts := DefaultOptions()
+	opts.MinWatchTimeout = 2 * time.Minute
+	m, err := New(fake.NewSimpleClientset(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range []float64{0, 0.25, 0.5, 0.999} {
+		if timeout := m.watchTimeout(r); timeout < 2*time.Minute || timeout >= 4*time.Minute {
+			t.Errorf("watch timeout for %v = %v, want between 2m and 4m", r, timeout)
+		}
+	}
+	if timeout := m.watchTimeout(0.5); timeout != 3*time.Minute {
+		t.Errorf("watch timeout for 0.5 = %v, want 3m", timeout)
+	}
+
+	opts.MinWatchTimeout = 500 * time.Millisecond
+	if _, err := New(fake.NewSimpleClientset(), opts); err == nil || !strings.Contains(err.Error(), "-min-watch-timeout") {
+		t.Errorf("error = %v, want -min-watch-timeout must be at least 1s", err)
+	}
+}
+
+func TestWatchJitterFactor(t *testing.T) {
+	for _, tc := range []struct {
+		jitterFactor float64
+		max          time.Duration
+	}{
+		{0, time.Minute},
+		{0.5, 90 * time.Second},
+		{3, 4 * time.Minute},
+		// clamped
+		{-1, time.Minute},
+		{maxWatchJitterFactor + 5, (1 + maxWatchJitterFactor) * time.Minute},
+	} {
+		opts := DefaultOptions()
+		opts.MinWatchTimeout = time.Minute
+		opts.WatchJitterFactor = tc.jitterFactor
+		m, err := New(fake.NewSimpleClientset(), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, r := range []float64{0, 0.5, 0.999} {
+			if timeout := m.watchTimeout(r); timeout < time.Minute || timeout > tc.max {
+				t.Errorf("jitter factor %v: watch timeout for %v = %v, want between 1m and %v", tc.jitterFactor, r, timeout, tc.max)
+			}
+		}
+		if timeout := m.watchTimeout(1); timeout != tc.max {
+			t.Errorf("jitter factor %v: longest watch timeout = %v, want %v", tc.jitterFactor, timeout, tc.max)
+		}
+	}
+}
+
+func TestWatchTimeoutSeconds(t *testing.T) {
+	timeouts := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("watch") != "true" {
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"kind":"PodList","apiVersion":"v1","metadata":{"resourceVersion":"1"},"items":[]}`)
+			return
+		}
+		timeouts <- r.URL.Query().Get("timeoutSeconds")
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+	client, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := DefaultOptions()
+	opts.MinWatchTimeout = 2 * time.Minute
+	m, err := New(client, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	informer, err := m.newPodInformer(ctx, "", "", make(chan WatchEvent, 10), func(err error) {
+		t.Errorf("watch failed: %v", err)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go m.runPodInformer(ctx, "", informer)
+
+	select {
+	case timeout := <-timeouts:
+		seconds, err := strconv.Atoi(timeout)
+		if err != nil {
+			t.Fatalf("invalid watch timeout %q: %v", timeout, err)
+		}
+		if seconds < 120 || seconds >= 240 {
+			t.Errorf("watch timeout %ds, want between 120s and 240s", seconds)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("pods are not watched")
+	}
+}
+
+func TestWatchEventChannelBuffer(t *testing.T) {
+	for _, size := range []int{0, 1, 512} {
+		opts := DefaultOptions()
+		opts.ChannelBuffer = size
+		m, err := New(fake.NewSimpleClientset(), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if capacity := cap(m.newWatchEventChannel()); capacity != size {
+			t.Errorf("channel buffer %d, want %d", capacity, size)
+		}
+	}
+
+	opts := DefaultOptions()
+	opts.ChannelBuffer = -1
+	if _, err := New(fake.NewSimpleClientset(), opts); err == nil || !strings.Contains(err.Error(), "-channel-buffer") {
+		t.Errorf("error = %v, want -channel-buffer must not be negative", err)
+	}
+}
+
+func TestSendWatchEventBlocked(t *testing.T) {
+	c := make(chan WatchEvent, 2)
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"}}
+	blocked := testutil.ToFloat64(watchEventChannelBlockedTotal)
+
+	// fills the buffer without blocking
+	for i := 0; i < 2; i++ {
+		sendWatchEvent(context.Background(), c, watch.Modified, pod, nil)
+	}
+	if depth := testutil.ToFloat64(watchEventChannelDepth); depth != 2 {
+		t.Errorf("channel depth %v, want 2", depth)
+	}
+	if count := testutil.ToFloat64(watchEventChannelBlockedTotal) - blocked; count != 0 {
+		t.Errorf("blocked %v times with free buffer, want 0", count)
+	}
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		<-c
+	}()
+	sendWatchEvent(context.Background(), c, watch.Modified, pod, nil)
+	if count := testutil.ToFloat64(watchEventChannelBlockedTotal) - blocked; count != 1 {
+		t.Errorf("blocked %v times with a full buffer, want 1", count)
+	}
+
+	// gives up when stopped
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	sendWatchEvent(ctx, c, watch.Modified, pod, nil)
+	if len(c) != 2 {
+		t.Errorf("%d events buffered, want 2", len(c))
+	}
+}
@@ -0,0 +1,221 @@
+package monitor
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	neturl "net/url"
+	"os"
+	"strconv"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const webhookAttempts = 3
+
+type webhookPayload struct {
+	Namespace          string      `json:"namespace"`
+	Pod                string      `json:"pod"`
+	Container          string      `json:"container"`
+	RestartCount       int32       `json:"restartCount"`
+	ExitCode           int32       `json:"exitCode"`
+	Reason             string      `json:"reason"`
+	TerminationMessage string      `json:"terminationMessage"`
+	Timestamp          metav1.Time `json:"timestamp"`
+	// tell restarts from image pull errors
+	EventReason string   `json:"eventReason"`
+	EventType   string   `json:"eventType"`
+	Severity    severity `json:"severity"`
+}
+
+func newWebhookPayload(info *RestartInfo) *webhookPayload {
+	return &webhookPayload{
+		Namespace:          info.Namespace,
+		Pod:                info.PodName,
+		Container:          info.Container,
+		RestartCount:       info.RestartCount,
+		ExitCode:           info.ExitCode,
+		Reason:             info.TerminationReason,
+		TerminationMessage: info.TerminationMessage,
+		Timestamp:          info.Timestamp,
+		EventReason:        info.EventReason,
+		EventType:          info.EventType,
+		Severity:           restartSeverity(info),
+	}
+}
+
+// WebhookSink POSTs restart notifications as JSON. With a secret, requests are signed: the X-Signature header
+// is "sha256=" followed by the hex encoded HMAC-SHA256 of the body.
+type WebhookSink struct {
+	url    string
+	secret []byte
+	client *http.Client
+}
+
+// NewWebhookSink creates the sink. tlsConfig is optional.
+func NewWebhookSink(url, secret string, tlsConfig *tls.Config, timeout time.Duration) *WebhookSink {
+	client := &http.Client{Timeout: timeout}
+	if tlsConfig != nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = tlsConfig
+		client.Transport = transport
+	}
+	return &WebhookSink{
+		url:    url,
+		secret: []byte(secret),
+		client: client,
+	}
+}
+
+// loadTLSConfig loads a client certificate and a CA bundle to verify the server with,
+// either may be empty. It returns nil if none are set.
+func loadTLSConfig(certFile, keyFile, caFile string) (*tls.Config, error) {
+	if certFile == "" && keyFile == "" && caFile == "" {
+		return nil, nil
+	}
+	config := &tls.Config{}
+	if certFile != "" || keyFile != "" {
+		if certFile == "" || keyFile == "" {
+			return nil, fmt.Errorf("both client certificate and key are needed")
+		}
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	if caFile != "" {
+		ca, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found in %s", caFile)
+		}
+	}
+	return config, nil
+}
+
+func (s *WebhookSink) post(ctx context.Context, body []byte) error {
+	var header http.Header
+	if len(s.secret) > 0 {
+		header = http.Header{"X-Signature": {signBody(s.secret, body)}}
+	}
+	return postJSONWithHeader(ctx, s.client, s.url, body, header)
+}
+
+func signBody(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *WebhookSink) Notify(ctx context.Context, info *RestartInfo) error {
+	body, err := json.Marshal(newWebhookPayload(info))
+	if err != nil {
+		return err
+	}
+	return s.post(ctx, body)
+}
+
+// NotifyBatch POSTs a JSON array of restarts.
+func (s *WebhookSink) NotifyBatch(ctx context.Context, infos []*RestartInfo) error {
+	payloads := make([]*webhookPayload, len(infos))
+	for i, info := range infos {
+		payloads[i] = newWebhookPayload(info)
+	}
+	body, err := json.Marshal(payloads)
+	if err != nil {
+		return err
+	}
+	return s.post(ctx, body)
+}
+
+// postJSON POSTs body to url, retrying with backoff on network errors, 5xx and 429 responses.
+// Retry-After header of 429 responses is honored.
+func postJSON(ctx context.Context, client *http.Client, url string, body []byte) error {
+	return postJSONWithHeader(ctx, client, url, body, nil)
+}
+
+// postJSONWithHeader is postJSON sending additional header fields.
+func postJSONWithHeader(ctx context.Context, client *http.Client, url string, body []byte, header http.Header) error {
+	backoff := time.Second
+	for attempt := 1; ; attempt++ {
+		err := post(ctx, client, url, body, header)
+		if err == nil {
+			return nil
+		}
+		retryErr, retryable := err.(retryableError)
+		if !retryable || attempt == webhookAttempts {
+			return err
+		}
+
+		delay := backoff
+		if retryErr.retryAfter > 0 {
+			delay = retryErr.retryAfter
+		}
+		slog.Warn("Request failed, retrying", "url", redactURL(url), "err", err, "delay", delay)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		backoff *= 2
+	}
+}
+
+func post(ctx context.Context, client *http.Client, url string, body []byte, header http.Header) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return retryableError{error: err}
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		// fractional seconds are sent e.g. by Discord
+		retryAfter, _ := strconv.ParseFloat(resp.Header.Get("Retry-After"), 64)
+		return retryableError{
+			error:      fmt.Errorf("server returned %s", resp.Status),
+			retryAfter: time.Duration(retryAfter * float64(time.Second)),
+		}
+	case resp.StatusCode >= 500:
+		return retryableError{error: fmt.Errorf("server returned %s", resp.Status)}
+	case resp.StatusCode >= 300:
+		return fmt.Errorf("server returned %s", resp.Status)
+	}
+	return nil
+}
+
+// redactURL strips path and query, which often contain secret tokens (e.g. Slack webhook URLs).
+func redactURL(rawURL string) string {
+	u, err := neturl.Parse(rawURL)
+	if err != nil {
+		return "webhook"
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+type retryableError struct {
+	error
+	retryAfter time.Duration
+}
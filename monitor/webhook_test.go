@@ -0,0 +1,293 @@
+package monitor
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestNewFailsOnInvalidWebhookTLSConfig(t *testing.T) {
+	for _, tc := range []struct {
+		name                  string
+		cert, key, caCertFile string
+	}{
+		{name: "certificate without key", cert: "client.crt"},
+		{name: "missing key pair", cert: filepath.Join(t.TempDir(), "client.crt"), key: filepath.Join(t.TempDir(), "client.key")},
+		{name: "missing CA bundle", caCertFile: filepath.Join(t.TempDir(), "ca.crt")},
+	} {
+		opts := DefaultOptions()
+		opts.WebhookURL = "https://example.com/restarts"
+		opts.WebhookClientCert = tc.cert
+		opts.WebhookClientKey = tc.key
+		opts.WebhookCACert = tc.caCertFile
+		if _, err := New(fake.NewSimpleClientset(), opts); err == nil || !strings.Contains(err.Error(), "invalid webhook TLS configuration") {
+			t.Errorf("%s: error = %v, want invalid webhook TLS configuration", tc.name, err)
+		}
+	}
+}
+
+// testReceiver is a test server recording the requests it receives, responding with status.
+type testReceiver struct {
+	*httptest.Server
+	status int
+
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func newTestReceiver(t *testing.T, status int) *testReceiver {
+	r := &testReceiver{status: status}
+	r.Server = httptest.NewServer(r)
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *testReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.mu.Lock()
+	r.requests = append(r.requests, req)
+	r.bodies = append(r.bodies, body)
+	status := r.status
+	r.mu.Unlock()
+	w.WriteHeader(status)
+}
+
+// received returns the requests received so far and their bodies.
+func (r *testReceiver) received() ([]*http.Request, [][]byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*http.Request(nil), r.requests...), append([][]byte(nil), r.bodies...)
+}
+
+func newTestRestartInfo() *RestartInfo {
+	return &RestartInfo{
+		Namespace:          "default",
+		PodName:            "web",
+		Container:          "app",
+		RestartCount:       3,
+		Delta:              1,
+		ExitCode:           137,
+		TerminationReason:  oomKilledReason,
+		TerminationMessage: "out of memory",
+		Timestamp:          metav1.NewTime(time.Date(2021, 5, 1, 12, 0, 0, 0, time.UTC)),
+		EventReason:        "ContainerOOMKilled",
+		EventType:          "Warning",
+		Message:            "Container app in pod default/web restarted.",
+	}
+}
+
+func TestWebhookSinkPayload(t *testing.T) {
+	receiver := newTestReceiver(t, http.StatusOK)
+	s := NewWebhookSink(receiver.URL, "", nil, time.Second)
+	if err := s.Notify(context.Background(), newTestRestartInfo()); err != nil {
+		t.Fatal(err)
+	}
+
+	requests, bodies := receiver.received()
+	if len(requests) != 1 {
+		t.Fatalf("%d requests, want 1", len(requests))
+	}
+	request := requests[0]
+	if request.Method != http.MethodPost || request.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("%s request with content type %q, want POST of application/json", request.Method, request.Header.Get("Content-Type"))
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal(bodies[0], &payload); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"namespace":          "default",
+		"pod":                "web",
+		"container":          "app",
+		"restartCount":       float64(3),
+		"exitCode":           float64(137),
+		"reason":             oomKilledReason,
+		"terminationMessage": "out of memory",
+		"timestamp":          "2021-05-01T12:00:00Z",
+		"eventReason":        "ContainerOOMKilled",
+		"eventType":          "Warning",
+		"severity":           "critical",
+	}
+	if !reflect.DeepEqual(payload, expected) {
+		t.Errorf("payload = %v, want %v", payload, expected)
+	}
+}
+
+func TestWebhookPayloadOfImagePullError(t *testing.T) {
+	info := &RestartInfo{
+		Namespace:   "default",
+		PodName:     "web",
+		Container:   "app",
+		EventReason: "ContainerImagePullError",
+		EventType:   "Warning",
+		EventAction: imagePullEventAction,
+	}
+	payload := newWebhookPayload(info)
+	if payload.EventReason != "ContainerImagePullError" || payload.EventType != "Warning" || payload.Severity != severityWarning {
+		t.Errorf("event reason, type, severity = %q, %q, %q, want the image pull error", payload.EventReason, payload.EventType, payload.Severity)
+	}
+}
+
+func TestSignBody(t *testing.T) {
+	// the example of the GitHub webhook documentation
+	signature := signBody([]byte("It's a Secret to Everybody"), []byte("Hello, World!"))
+	if expected := "sha256=757107ea0eb2509fc211221cce984b8a37570b6d7586c22c46f4379c8b043e17"; signature != expected {
+		t.Errorf("signature = %q, want %q", signature, expected)
+	}
+}
+
+func TestWebhookSinkSignature(t *testing.T) {
+	receiver := newTestReceiver(t, http.StatusOK)
+	s := NewWebhookSink(receiver.URL, "s3cret", nil, time.Second)
+	if err := s.Notify(context.Background(), newTestRestartInfo()); err != nil {
+		t.Fatal(err)
+	}
+	unsigned := NewWebhookSink(receiver.URL, "", nil, time.Second)
+	if err := unsigned.Notify(context.Background(), newTestRestartInfo()); err != nil {
+		t.Fatal(err)
+	}
+
+	requests, bodies := receiver.received()
+	if len(requests) != 2 {
+		t.Fatalf("%d requests, want 2", len(requests))
+	}
+	// verified like a receiver would
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write(bodies[0])
+	expected := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	if signature := requests[0].Header.Get("X-Signature"); !hmac.Equal([]byte(signature), []byte(expected)) {
+		t.Errorf("signature = %q, want %q", signature, expected)
+	}
+	if signature, ok := requests[1].Header["X-Signature"]; ok {
+		t.Errorf("request without secret signed with %q", signature)
+	}
+}
+
+// writeClientCert writes a CA certificate and a client certificate with its key signed by it into dir,
+// returning the CA pool and the file paths.
+func writeClientCert(t *testing.T, dir string) (pool *x509.CertPool, certFile, keyFile string) {
+	t.Helper()
+	newKey := func() *ecdsa.PrivateKey {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+	caKey, clientKey := newKey(), newKey()
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "kube-restart-monitor"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	clientDER, err := x509.CreateCertificate(rand.Reader, clientTemplate, ca, &clientKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(clientKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile = filepath.Join(dir, "client.crt")
+	keyFile = filepath.Join(dir, "client.key")
+	writePEM(t, certFile, "CERTIFICATE", clientDER)
+	writePEM(t, keyFile, "EC PRIVATE KEY", keyDER)
+	pool = x509.NewCertPool()
+	pool.AddCert(ca)
+	return pool, certFile, keyFile
+}
+
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWebhookSinkClientCertificate(t *testing.T) {
+	dir := t.TempDir()
+	clientCAs, certFile, keyFile := writeClientCert(t, dir)
+	receiver := &testReceiver{status: http.StatusOK}
+	receiver.Server = httptest.NewUnstartedServer(receiver)
+	receiver.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	receiver.StartTLS()
+	defer receiver.Close()
+	// the CA bundle verifies the test server certificate
+	caFile := filepath.Join(dir, "ca.crt")
+	writePEM(t, caFile, "CERTIFICATE", receiver.Certificate().Raw)
+
+	tlsConfig, err := loadTLSConfig(certFile, keyFile, caFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewWebhookSink(receiver.URL, "", tlsConfig, time.Second)
+	if err := s.Notify(context.Background(), newTestRestartInfo()); err != nil {
+		t.Fatal(err)
+	}
+	requests, _ := receiver.received()
+	if len(requests) != 1 {
+		t.Fatalf("%d requests, want 1", len(requests))
+	}
+	if certs := requests[0].TLS.PeerCertificates; len(certs) == 0 || certs[0].Subject.CommonName != "kube-restart-monitor" {
+		t.Errorf("client certificates %v, want kube-restart-monitor", certs)
+	}
+
+	// rejected without the client certificate
+	tlsConfig, err = loadTLSConfig("", "", caFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	if err := NewWebhookSink(receiver.URL, "", tlsConfig, time.Second).Notify(ctx, newTestRestartInfo()); err == nil {
+		t.Error("request without client certificate succeeded")
+	}
+	if requests, _ := receiver.received(); len(requests) != 1 {
+		t.Errorf("%d requests, want only the one with client certificate", len(requests))
+	}
+}
@@ -0,0 +1,48 @@
+package monitor
+
+import (
+	"hash/fnv"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const workerQueueSize = 16
+
+// workerPool runs tasks concurrently, but tasks with the same key (pod UID) run in submission order on one worker.
+// Tasks are submitted from one goroutine, which closes the pool once done.
+type workerPool struct {
+	queues []chan func()
+	wg     sync.WaitGroup
+}
+
+func startWorkerPool(workers int) *workerPool {
+	p := &workerPool{queues: make([]chan func(), workers)}
+	for i := range p.queues {
+		queue := make(chan func(), workerQueueSize)
+		p.queues[i] = queue
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			for task := range queue {
+				task()
+			}
+		}()
+	}
+	return p
+}
+
+// submit blocks while the worker of the key is busy with a full queue.
+func (p *workerPool) submit(key types.UID, task func()) {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	p.queues[h.Sum32()%uint32(len(p.queues))] <- task
+}
+
+// close blocks until the submitted tasks are done.
+func (p *workerPool) close() {
+	for _, queue := range p.queues {
+		close(queue)
+	}
+	p.wg.Wait()
+}